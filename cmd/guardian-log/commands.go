@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/eiladin/guardian-log/internal/rules"
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+const usage = `Usage:
+  guardian-log                                 Run the service
+  guardian-log rules test [-dir DIR] FILE      Evaluate rules against sample queries
+  guardian-log baselines export [-db PATH] [-format json|csv] [-client ID] [-o FILE]
+                                               Export learned baselines
+  guardian-log baselines import [-db PATH] [-format json|csv] [-mode merge|replace] FILE
+                                               Import baselines (stop the service first)
+  guardian-log events backfill [-server URL] [-since DURATION | -from TIME] [-to TIME]
+                                               Queue unanalyzed first-seen events for LLM analysis
+`
+
+// runCommand runs a CLI subcommand and returns the process exit code
+func runCommand(args []string) int {
+	// Load .env file if it exists, as the service does
+	_ = godotenv.Load()
+
+	switch args[0] {
+	case "rules":
+		return runRulesCommand(args[1:])
+	case "baselines":
+		return runBaselinesCommand(args[1:])
+	case "events":
+		return runEventsCommand(args[1:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n\n%s", args[0], usage)
+		return 2
+	}
+}
+
+// runRulesCommand handles the rules subcommands
+func runRulesCommand(args []string) int {
+	if len(args) == 0 || args[0] != "test" {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	defaultDir := os.Getenv("RULES_DIR")
+	if defaultDir == "" {
+		defaultDir = "./rules"
+	}
+
+	fs := flag.NewFlagSet("rules test", flag.ContinueOnError)
+	dir := fs.String("dir", defaultDir, "directory containing rule files")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	loaded, err := rules.LoadDir(*dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load rules: %v\n", err)
+		return 1
+	}
+
+	queries, err := readSampleQueries(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read queries: %v\n", err)
+		return 1
+	}
+
+	// Evaluate in chronological order, as the poller does
+	sort.SliceStable(queries, func(i, j int) bool {
+		return queries[i].Timestamp.Before(queries[j].Timestamp)
+	})
+
+	fmt.Printf("Loaded %d rules from %s, evaluating %d queries\n\n", len(loaded), *dir, len(queries))
+
+	engine := rules.NewEngineWithRules(loaded)
+	fired := make(map[string]int)
+	for _, query := range queries {
+		for _, result := range engine.Evaluate(query) {
+			fired[result.Rule.Name]++
+			fmt.Printf("%s  %-30s %-8s %-5s client=%s domain=%s count=%d\n",
+				query.Timestamp.Format("2006-01-02 15:04:05"), result.Rule.Name,
+				result.Rule.Severity, result.Rule.Action, query.ClientID, query.Domain, result.Count)
+		}
+	}
+
+	fmt.Println()
+	for _, rule := range loaded {
+		state := ""
+		if !rule.IsEnabled() {
+			state = " (disabled)"
+		}
+		fmt.Printf("%-30s fired %d time(s)%s\n", rule.Name, fired[rule.Name], state)
+	}
+
+	return 0
+}
+
+// readSampleQueries reads DNS queries from a JSON array or JSON lines file
+func readSampleQueries(file string) ([]storage.DNSQuery, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		var queries []storage.DNSQuery
+		if err := json.Unmarshal(trimmed, &queries); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+		return queries, nil
+	}
+
+	var queries []storage.DNSQuery
+	reader := bufio.NewReader(bytes.NewReader(trimmed))
+	for line := 1; ; line++ {
+		text, err := reader.ReadString('\n')
+		if text = strings.TrimSpace(text); text != "" {
+			var query storage.DNSQuery
+			if err := json.Unmarshal([]byte(text), &query); err != nil {
+				return nil, fmt.Errorf("line %d: failed to parse JSON: %w", line, err)
+			}
+			queries = append(queries, query)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return queries, nil
+}
+
+// runBaselinesCommand handles the baselines export and import subcommands
+func runBaselinesCommand(args []string) int {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "import") {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	defaultDB := os.Getenv("DB_PATH")
+	if defaultDB == "" {
+		defaultDB = "./data/guardian.db"
+	}
+
+	fs := flag.NewFlagSet("baselines "+args[0], flag.ContinueOnError)
+	dbPath := fs.String("db", defaultDB, "path to the Guardian-Log database")
+	format := fs.String("format", "", "file format: json or csv (default from file extension, else json)")
+	client := fs.String("client", "", "export only this client ID")
+	output := fs.String("o", "", "write the export to this file instead of stdout")
+	mode := fs.String("mode", storage.ImportModeMerge, "import mode: merge or replace")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	store, err := storage.NewBoltStore(*dbPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open database (is the service running? use the API instead): %v\n", err)
+		return 1
+	}
+	defer store.Close()
+
+	if args[0] == "export" {
+		return exportBaselines(store, *output, formatFor(*format, *output), *client)
+	}
+
+	if fs.NArg() != 1 {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+	return importBaselines(store, fs.Arg(0), formatFor(*format, fs.Arg(0)), *mode)
+}
+
+// exportBaselines writes all (or one client's) baselines to a file or stdout
+func exportBaselines(store *storage.BoltStore, output, format, clientID string) int {
+	baselines, err := store.GetAllBaselines()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read baselines: %v\n", err)
+		return 1
+	}
+
+	if clientID != "" {
+		filtered := baselines[:0]
+		for _, baseline := range baselines {
+			if baseline.ClientID == clientID {
+				filtered = append(filtered, baseline)
+			}
+		}
+		baselines = filtered
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		file, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to create %s: %v\n", output, err)
+			return 1
+		}
+		defer file.Close()
+		w = file
+	}
+
+	if err := storage.WriteBaselines(w, baselines, format); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to write baselines: %v\n", err)
+		return 1
+	}
+
+	if output != "" {
+		fmt.Fprintf(os.Stderr, "Exported %d baselines to %s\n", len(baselines), output)
+	}
+	return 0
+}
+
+// importBaselines loads a baseline export into the database
+func importBaselines(store *storage.BoltStore, input, format, mode string) int {
+	file, err := os.Open(input)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open %s: %v\n", input, err)
+		return 1
+	}
+	defer file.Close()
+
+	baselines, err := storage.ReadBaselines(file, format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read baselines: %v\n", err)
+		return 1
+	}
+
+	result, err := store.ImportBaselines(baselines, mode)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to import baselines: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("Imported %d clients (%s): %d domains\n", result.Clients, result.Mode, result.DomainsAdded)
+	return 0
+}
+
+// formatFor returns the explicit format, or guesses it from the file extension
+func formatFor(format, file string) string {
+	if format != "" {
+		return format
+	}
+	if strings.EqualFold(filepath.Ext(file), ".csv") {
+		return storage.BaselineFormatCSV
+	}
+	return storage.BaselineFormatJSON
+}
+
+// runEventsCommand handles the events subcommands. The backfill runs inside the
+// service, so this calls the running server's API instead of opening the database.
+func runEventsCommand(args []string) int {
+	if len(args) == 0 || args[0] != "backfill" {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("events backfill", flag.ContinueOnError)
+	server := fs.String("server", "http://localhost:8080", "URL of the running Guardian-Log service")
+	since := fs.Duration("since", 0, "backfill events from this long ago (e.g. 24h)")
+	from := fs.String("from", "", "backfill events detected at or after this RFC3339 time")
+	to := fs.String("to", "", "backfill events detected before this RFC3339 time")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 || (*since > 0 && *from != "") {
+		fmt.Fprint(os.Stderr, usage)
+		return 2
+	}
+
+	params := url.Values{}
+	if *since > 0 {
+		params.Set("from", time.Now().Add(-*since).UTC().Format(time.RFC3339))
+	}
+	for name, value := range map[string]string{"from": *from, "to": *to} {
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid -%s time (expected RFC3339): %v\n", name, err)
+			return 2
+		}
+		params.Set(name, value)
+	}
+
+	endpoint := strings.TrimSuffix(*server, "/") + "/api/events/backfill"
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(endpoint, "application/json", nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to reach %s: %v\n", *server, err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Queued int    `json:"queued"`
+		Error  string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to parse response (status %s): %v\n", resp.Status, err)
+		return 1
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		fmt.Fprintf(os.Stderr, "Backfill failed: %s\n", result.Error)
+		return 1
+	}
+
+	fmt.Printf("Queued %d unanalyzed first-seen events for analysis\n", result.Queued)
+	return 0
+}
@@ -2,10 +2,12 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
@@ -15,12 +17,22 @@ import (
 	"github.com/eiladin/guardian-log/internal/enrichment"
 	"github.com/eiladin/guardian-log/internal/ingestor"
 	"github.com/eiladin/guardian-log/internal/llm"
+	"github.com/eiladin/guardian-log/internal/llm/providers/anthropic"
 	"github.com/eiladin/guardian-log/internal/llm/providers/gemini"
+	"github.com/eiladin/guardian-log/internal/llm/providers/ollama"
+	"github.com/eiladin/guardian-log/internal/llm/providers/openai"
+	"github.com/eiladin/guardian-log/internal/rules"
 	"github.com/eiladin/guardian-log/internal/storage"
+	"github.com/eiladin/guardian-log/internal/threatintel"
 	"github.com/eiladin/guardian-log/webfs"
 )
 
 func main() {
+	// Dispatch subcommands before loading the service configuration
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
@@ -49,44 +61,172 @@ func main() {
 
 	// Initialize baseline analyzer
 	baselineAnalyzer := analyzer.NewBaselineAnalyzer(store)
+	baselineAnalyzer.SetActivityConfig(analyzer.ActivityConfig{
+		MinQueries: cfg.OffHoursMinQueries,
+		MinShare:   cfg.OffHoursMinShare,
+	})
 
 	// Initialize poller
 	poller := ingestor.NewPoller(adguardClient, baselineAnalyzer, cfg.PollInterval)
 
+	// Initialize beaconing detection if enabled
+	if cfg.BeaconEnabled {
+		poller.SetBeaconDetector(analyzer.NewBeaconDetector(analyzer.BeaconConfig{
+			Window:      cfg.BeaconWindow,
+			MinSamples:  cfg.BeaconMinSamples,
+			MaxJitter:   cfg.BeaconMaxJitter,
+			MinInterval: cfg.BeaconMinInterval,
+		}))
+		log.Printf("📡 Beaconing detection: Enabled (window: %s, min samples: %d, max jitter: %.0f%%)",
+			cfg.BeaconWindow, cfg.BeaconMinSamples, cfg.BeaconMaxJitter*100)
+	}
+
+	// Initialize volume spike detection if enabled
+	if cfg.VolumeEnabled {
+		volumeDetector, err := analyzer.NewVolumeDetector(store, analyzer.VolumeConfig{
+			ZThreshold:    cfg.VolumeZThreshold,
+			MinQueries:    cfg.VolumeMinQueries,
+			WarmupMinutes: cfg.VolumeWarmupMinutes,
+		})
+		if err != nil {
+			log.Fatalf("Failed to initialize volume detector: %v", err)
+		}
+		poller.SetVolumeDetector(volumeDetector)
+		log.Printf("📈 Volume detection: Enabled (z-score: %.1f, min queries: %d/min, warmup: %d min)",
+			cfg.VolumeZThreshold, cfg.VolumeMinQueries, cfg.VolumeWarmupMinutes)
+	}
+
+	// Initialize typosquatting detection if enabled
+	if cfg.TyposquatEnabled {
+		poller.SetTyposquatDetector(analyzer.NewTyposquatDetector(store, analyzer.TyposquatConfig{
+			BrandDomains: cfg.TyposquatBrands,
+			BaselineTopN: cfg.TyposquatBaselineTopN,
+			TLDSwap:      cfg.TyposquatTLDSwap,
+		}))
+		log.Printf("🎭 Typosquatting detection: Enabled (%d extra brands, top %d baseline domains)",
+			len(cfg.TyposquatBrands), cfg.TyposquatBaselineTopN)
+	}
+
+	// Initialize declarative rule engine if a rules directory exists
+	var ruleEngine *rules.Engine
+	if _, err := os.Stat(cfg.RulesDir); err == nil {
+		ruleEngine, err = rules.NewEngine(cfg.RulesDir)
+		if err != nil {
+			log.Fatalf("Failed to load rules: %v", err)
+		}
+		poller.SetRuleEngine(ruleEngine)
+		log.Printf("📜 Rule engine: Enabled (%d rules from %s, send SIGHUP to reload)",
+			len(ruleEngine.Rules()), cfg.RulesDir)
+	} else {
+		log.Printf("Rule engine: Disabled (no rules directory at %s)", cfg.RulesDir)
+	}
+
+	// Initialize threat-intel feed matching if feeds are configured
+	var threatIntel *threatintel.Service
+	if len(cfg.ThreatIntelFeeds) > 0 {
+		feeds, err := threatintel.ParseFeeds(cfg.ThreatIntelFeeds)
+		if err != nil {
+			log.Fatalf("Invalid THREATINTEL_FEEDS: %v", err)
+		}
+		threatIntel = threatintel.NewService(feeds, cfg.ThreatIntelRefresh)
+		poller.SetThreatIntel(threatIntel)
+		log.Printf("🛡️  Threat-intel feeds: Enabled (%d feeds, %d domains, refresh: %s)",
+			len(feeds), threatIntel.IndexSize(), cfg.ThreatIntelRefresh)
+	}
+
+	// Load the top-sites list used to skip LLM analysis of popular domains
+	var popularity *enrichment.PopularityList
+	if cfg.TopSitesFile != "" {
+		var err error
+		popularity, err = enrichment.LoadPopularityList(cfg.TopSitesFile, cfg.TopSitesMaxRank)
+		if err != nil {
+			log.Fatalf("Failed to load top-sites list: %v", err)
+		}
+		poller.SetPopularityList(popularity)
+		log.Printf("⭐ Top-sites allowlist: Enabled (%d domains ranked within top %d)",
+			popularity.Len(), cfg.TopSitesMaxRank)
+	}
+
+	// Initialize WHOIS service (shared by the domain age check and LLM analysis)
+	whoisService := enrichment.NewWHOISService(store)
+
+	// Initialize newly registered domain check if enabled
+	if cfg.NRDEnabled {
+		domainAgeChecker := analyzer.NewDomainAgeChecker(baselineAnalyzer, whoisService,
+			time.Duration(cfg.NRDMaxAgeDays)*24*time.Hour)
+		poller.SetDomainAgeChecker(domainAgeChecker)
+		defer domainAgeChecker.Stop()
+		log.Printf("🆕 Newly registered domain check: Enabled (max age: %d days)", cfg.NRDMaxAgeDays)
+	}
+
 	// Initialize LLM analysis if enabled
 	var llmAnalyzer *llm.Analyzer
+	var usageMeter *llm.Meter
 	if cfg.LLMEnabled {
 		log.Printf("🤖 LLM Analysis: Enabled (provider: %s)", cfg.LLMProvider)
 
-		// Initialize WHOIS service
-		whoisService := enrichment.NewWHOISService(store)
-
-		// Initialize LLM provider based on configuration
-		var provider llm.Provider
-		switch cfg.LLMProvider {
-		case "gemini":
-			provider, err = gemini.NewProvider(cfg.GeminiAPIKey, cfg.GeminiModel, cfg.LLMTimeout)
+		// Load prompt templates, falling back to the embedded defaults
+		if cfg.LLMPromptDir != "" {
+			versions, err := llm.LoadPrompts(cfg.LLMPromptDir)
 			if err != nil {
-				log.Fatalf("Failed to initialize Gemini provider: %v", err)
+				log.Fatalf("Failed to load prompt templates: %v", err)
 			}
-			log.Printf("Gemini provider initialized (model: %s)", cfg.GeminiModel)
+			log.Printf("📝 Prompt templates: %s (from %s)", strings.Join(versions, ", "), cfg.LLMPromptDir)
+		}
 
-		// Future providers can be added here
-		// case "openai": ...
-		// case "anthropic": ...
-		// case "ollama": ...
+		// Record token usage and cost, pausing paid providers over budget
+		prices, err := llm.ParsePrices(cfg.LLMPrices)
+		if err != nil {
+			log.Fatalf("Invalid LLM_PRICES: %v", err)
+		}
+		usageMeter, err = llm.NewMeter(store, prices, llm.Budget{
+			Daily:   cfg.LLMDailyBudget,
+			Monthly: cfg.LLMMonthlyBudget,
+		})
+		if err != nil {
+			log.Fatalf("LLM setup failed: %v", err)
+		}
+		if cfg.LLMDailyBudget > 0 || cfg.LLMMonthlyBudget > 0 {
+			log.Printf("💰 LLM budget: $%.2f/day, $%.2f/month (0 = no limit)", cfg.LLMDailyBudget, cfg.LLMMonthlyBudget)
+		}
 
-		default:
-			log.Fatalf("Unsupported LLM provider: %s", cfg.LLMProvider)
+		// Initialize LLM providers in failover order
+		provider, err := newProviderChain(cfg.LLMProviders, cfg, usageMeter)
+		if err != nil {
+			log.Fatalf("LLM setup failed: %v", err)
 		}
 
 		// Initialize LLM analyzer with configured batch settings
-		llmAnalyzer = llm.NewAnalyzer(provider, whoisService, store, cfg.LLMBatchSize, cfg.LLMBatchTimeout, cfg.LLMBatchDelay)
+		llmAnalyzer = llm.NewAnalyzer(provider, whoisService, store, cfg.LLMBatchSize, cfg.LLMBatchTimeout)
+		llmAnalyzer.SetMaxAttempts(cfg.LLMMaxAttempts)
+		llmAnalyzer.SetVerdictCache(cfg.LLMCacheTTL, cfg.LLMCacheByQueryType)
+		if popularity != nil {
+			llmAnalyzer.SetPopularityList(popularity)
+		}
+		if cfg.LLMCacheTTL > 0 {
+			log.Printf("💾 Verdict cache: Enabled (TTL: %s, per query type: %v)", cfg.LLMCacheTTL, cfg.LLMCacheByQueryType)
+		}
+
+		// Tiered analysis: escalate suspicious first-stage verdicts to a stronger model
+		if len(cfg.LLMEscalationProviders) > 0 {
+			escalation, err := newProviderChain(cfg.LLMEscalationProviders, cfg, usageMeter)
+			if err != nil {
+				log.Fatalf("LLM setup failed: %v", err)
+			}
+			llmAnalyzer.SetEscalation(escalation, llm.EscalationCriteria{
+				Classifications: cfg.LLMEscalateClassifications,
+				MinRiskScore:    cfg.LLMEscalateMinRiskScore,
+			})
+			log.Printf("⬆️  Tiered analysis: Enabled (%s -> %s, classifications: %v, min risk: %d)",
+				provider.Name(), escalation.Name(), cfg.LLMEscalateClassifications, cfg.LLMEscalateMinRiskScore)
+		}
+		// Prune comparisons even with tiers disabled, so earlier ones still expire
+		llmAnalyzer.SetTierRetention(cfg.LLMTierRetention)
 		poller.SetLLMAnalyzer(llmAnalyzer)
 		defer llmAnalyzer.Stop()
 
-		log.Printf("LLM analyzer initialized (batch: %d domains, timeout: %s, delay: %s)",
-			cfg.LLMBatchSize, cfg.LLMBatchTimeout, cfg.LLMBatchDelay)
+		log.Printf("LLM analyzer initialized (batch: up to %d domains, timeout: %s)",
+			cfg.LLMBatchSize, cfg.LLMBatchTimeout)
 	} else {
 		log.Println("LLM Analysis: Disabled")
 	}
@@ -95,6 +235,35 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Correlate anomalies into incidents if enabled
+	var incidentCorrelator *analyzer.IncidentCorrelator
+	if cfg.IncidentEnabled {
+		incidentCorrelator = analyzer.NewIncidentCorrelator(store, analyzer.IncidentConfig{
+			Window:       cfg.IncidentWindow,
+			MinAnomalies: cfg.IncidentMinAnomalies,
+		})
+		go incidentCorrelator.Start(ctx)
+		log.Printf("🧩 Incident correlation: Enabled (window: %s, min anomalies: %d)",
+			cfg.IncidentWindow, cfg.IncidentMinAnomalies)
+	}
+
+	// Expire old first-seen events
+	if cfg.EventRetention > 0 {
+		go analyzer.NewEventPruner(store, cfg.EventRetention).Start(ctx)
+		log.Printf("🗂️  First-seen events: Keeping %s", cfg.EventRetention)
+	}
+
+	// Snapshot baselines periodically so bad approvals can be rolled back
+	if cfg.SnapshotInterval > 0 {
+		go analyzer.NewBaselineSnapshotter(store, cfg.SnapshotInterval, cfg.SnapshotRetain).Start(ctx)
+		log.Printf("📸 Baseline snapshots: Every %s (keeping %d)", cfg.SnapshotInterval, cfg.SnapshotRetain)
+	}
+
+	// Keep threat-intel feeds refreshed
+	if threatIntel != nil {
+		go threatIntel.Start(ctx)
+	}
+
 	// Get embedded web filesystem
 	webFS, err := webfs.GetFS()
 	if err != nil {
@@ -104,6 +273,18 @@ func main() {
 
 	// Initialize and start API server
 	apiServer := api.NewServer(store, cfg, adguardClient, llmAnalyzer, webFS)
+	if ruleEngine != nil {
+		apiServer.SetRuleEngine(ruleEngine)
+	}
+	if threatIntel != nil {
+		apiServer.SetThreatIntel(threatIntel)
+	}
+	if incidentCorrelator != nil {
+		apiServer.SetIncidentCorrelator(incidentCorrelator)
+	}
+	if usageMeter != nil {
+		apiServer.SetUsageMeter(usageMeter)
+	}
 
 	// Start API server in a goroutine
 	go func() {
@@ -117,6 +298,19 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
+	// Reload rules on SIGHUP
+	if ruleEngine != nil {
+		hupChan := make(chan os.Signal, 1)
+		signal.Notify(hupChan, syscall.SIGHUP)
+		go func() {
+			for range hupChan {
+				if err := ruleEngine.Reload(); err != nil {
+					log.Printf("⚠️  Failed to reload rules, keeping previous rules: %v", err)
+				}
+			}
+		}()
+	}
+
 	// Start poller in a goroutine
 	errChan := make(chan error, 1)
 	go func() {
@@ -146,3 +340,146 @@ func main() {
 		}
 	}
 }
+
+// newProviderChain initializes the named LLM providers, wrapping them in a
+// failover chain when more than one is given. Only the primary provider must
+// initialize; fallbacks that fail (e.g. an Ollama server that is down) are skipped.
+func newProviderChain(names []string, cfg *config.Config, meter *llm.Meter) (llm.Provider, error) {
+	var providers []llm.Provider
+	for i, name := range names {
+		provider, err := newProvider(name, cfg)
+		if err != nil {
+			if i == 0 {
+				return nil, fmt.Errorf("failed to initialize %s provider: %w", name, err)
+			}
+			log.Printf("⚠️  Skipping fallback %s provider, failed to initialize: %v", name, err)
+			continue
+		}
+
+		// Pace requests to the provider's RPM/TPM budgets
+		if limits := cfg.LLMRateLimits[name]; limits.RPM > 0 || limits.TPM > 0 {
+			provider = llm.NewLimitedProvider(provider, llm.NewRateLimiter(llm.RateLimits{
+				RequestsPerMinute: limits.RPM,
+				TokensPerMinute:   limits.TPM,
+			}))
+			log.Printf("🚦 %s rate limit: %d RPM, %d TPM", name, limits.RPM, limits.TPM)
+		} else {
+			log.Printf("🚦 %s rate limit: unlimited", name)
+		}
+
+		// Record usage and pause paid models while the budget is spent
+		// Cloud providers are always paid; local servers only when given a price
+		model := providerModel(name, cfg)
+		price := meter.Price(model)
+		priced := price.Prompt > 0 || price.Completion > 0
+		paid := priced || !isLocalProvider(name)
+		if paid && !priced {
+			if meter.HasBudget() {
+				return nil, fmt.Errorf("no price for %s model %s, set LLM_PRICES so the budget can be enforced", name, model)
+			}
+			log.Printf("⚠️  No price for %s model %s, its usage will be recorded at $0 (set LLM_PRICES)", name, model)
+		}
+		provider = llm.NewMeteredProvider(provider, model, paid, meter)
+		providers = append(providers, provider)
+	}
+
+	if len(providers) == 1 {
+		return providers[0], nil
+	}
+
+	chain := llm.NewChain(providers, cfg.LLMFailoverCooldown, cfg.LLMFailoverMaxErrors)
+	log.Printf("🔀 LLM failover chain: %s (cooldown: %s, max errors: %d)",
+		chain.Name(), cfg.LLMFailoverCooldown, cfg.LLMFailoverMaxErrors)
+	return chain, nil
+}
+
+// isLocalProvider returns true for providers that usually run on a local server
+func isLocalProvider(name string) bool {
+	return name == "ollama" || name == "openai-compatible"
+}
+
+// providerModel returns the model configured for a provider
+func providerModel(name string, cfg *config.Config) string {
+	switch name {
+	case "gemini":
+		return cfg.GeminiModel
+	case "openai":
+		return cfg.OpenAIModel
+	case "openai-compatible":
+		return cfg.OpenAICompatModel
+	case "anthropic":
+		return cfg.AnthropicModel
+	case "ollama":
+		return cfg.OllamaModel
+	default:
+		return ""
+	}
+}
+
+// newProvider initializes a single LLM provider by name
+func newProvider(name string, cfg *config.Config) (llm.Provider, error) {
+	switch name {
+	case "gemini":
+		provider, err := gemini.NewProvider(cfg.GeminiAPIKey, cfg.GeminiModel, cfg.LLMTimeout)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Gemini provider initialized (model: %s)", cfg.GeminiModel)
+		return provider, nil
+
+	case "openai":
+		provider, err := openai.NewProvider(cfg.OpenAIAPIKey, cfg.OpenAIModel, cfg.LLMTimeout)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("OpenAI provider initialized (model: %s)", cfg.OpenAIModel)
+		return provider, nil
+
+	case "openai-compatible":
+		provider, err := openai.NewCompatibleProvider(cfg.OpenAICompatURL, cfg.OpenAICompatAPIKey, cfg.OpenAICompatModel, cfg.LLMTimeout)
+		if err != nil {
+			return nil, err
+		}
+		headers, err := openai.ParseHeaders(cfg.OpenAICompatHeaders)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OPENAI_COMPAT_HEADERS: %w", err)
+		}
+		provider.SetHeaders(headers)
+
+		// Use the strictest JSON output the server supports
+		mode, err := provider.DetectOutputMode(context.Background())
+		if err != nil {
+			log.Printf("⚠️  Could not detect response_format support at %s, using prompt-only JSON: %v", cfg.OpenAICompatURL, err)
+		}
+		log.Printf("OpenAI-compatible provider initialized (model: %s, url: %s, output: %s)", cfg.OpenAICompatModel, cfg.OpenAICompatURL, mode)
+		return provider, nil
+
+	case "anthropic":
+		provider, err := anthropic.NewProvider(cfg.AnthropicAPIKey, cfg.AnthropicModel, cfg.LLMTimeout)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("Anthropic provider initialized (model: %s)", cfg.AnthropicModel)
+		return provider, nil
+
+	case "ollama":
+		provider, err := ollama.NewProvider(cfg.OllamaURL, cfg.OllamaModel, cfg.LLMTimeout)
+		if err != nil {
+			return nil, err
+		}
+		provider.SetContextWindow(cfg.OllamaNumCtx)
+		provider.SetKeepAlive(cfg.OllamaKeepAlive)
+
+		// Fail fast if the server is unreachable or the model has not been pulled
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.LLMTimeout)
+		defer cancel()
+		if err := provider.CheckModel(ctx); err != nil {
+			return nil, err
+		}
+		log.Printf("Ollama provider initialized (model: %s, url: %s, num_ctx: %d)", cfg.OllamaModel, cfg.OllamaURL, cfg.OllamaNumCtx)
+		return provider, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported LLM provider: %s", name)
+	}
+}
@@ -0,0 +1,75 @@
+package analyzer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+// ActivityConfig controls when a query is considered outside a client's normal active hours
+type ActivityConfig struct {
+	MinQueries int     // Queries a profile needs before off-hours flagging starts
+	MinShare   float64 // Fraction of the uniform share below which an hour or weekday is unusual
+}
+
+// TrackActivity reports whether the query happened outside the client's normal
+// active hours and then records it in the client's hourly and weekday histogram.
+// Profiles are kept in memory and written to storage by FlushActivity.
+func (a *BaselineAnalyzer) TrackActivity(query storage.DNSQuery) (bool, error) {
+	a.activityMu.Lock()
+	defer a.activityMu.Unlock()
+
+	profile, ok := a.profiles[query.ClientID]
+	if !ok {
+		var err error
+		profile, err = a.store.GetActivityProfile(query.ClientID)
+		if err != nil {
+			return false, fmt.Errorf("failed to load activity profile: %w", err)
+		}
+		a.profiles[query.ClientID] = profile
+	}
+
+	local := query.Timestamp.Local()
+	hour := local.Hour()
+	weekday := int(local.Weekday())
+
+	// Judge the query against the profile before it includes the query itself
+	offHours := false
+	if profile.Total >= int64(a.activity.MinQueries) {
+		total := float64(profile.Total)
+		hourShare := float64(profile.Hourly[hour]) / total
+		weekdayShare := float64(profile.Weekday[weekday]) / total
+		offHours = hourShare < a.activity.MinShare/24 || weekdayShare < a.activity.MinShare/7
+	}
+
+	profile.Hourly[hour]++
+	profile.Weekday[weekday]++
+	profile.Total++
+	profile.UpdatedAt = time.Now()
+	a.dirtyProfiles[query.ClientID] = true
+
+	return offHours, nil
+}
+
+// FlushActivity persists activity profiles that changed since the last flush
+func (a *BaselineAnalyzer) FlushActivity() error {
+	a.activityMu.Lock()
+	defer a.activityMu.Unlock()
+
+	if len(a.dirtyProfiles) == 0 {
+		return nil
+	}
+
+	profiles := make([]*storage.ActivityProfile, 0, len(a.dirtyProfiles))
+	for clientID := range a.dirtyProfiles {
+		profiles = append(profiles, a.profiles[clientID])
+	}
+
+	if err := a.store.SaveActivityProfiles(profiles); err != nil {
+		return fmt.Errorf("failed to save activity profiles: %w", err)
+	}
+
+	a.dirtyProfiles = make(map[string]bool)
+	return nil
+}
@@ -3,6 +3,8 @@ package analyzer
 import (
 	"fmt"
 	"log"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/eiladin/guardian-log/internal/storage"
@@ -11,18 +13,50 @@ import (
 // BaselineAnalyzer handles anomaly detection based on client baselines
 type BaselineAnalyzer struct {
 	store *storage.BoltStore
+
+	// Time-of-day activity profiles
+	activity      ActivityConfig
+	activityMu    sync.Mutex
+	profiles      map[string]*storage.ActivityProfile
+	dirtyProfiles map[string]bool
 }
 
 // NewBaselineAnalyzer creates a new baseline analyzer
 func NewBaselineAnalyzer(store *storage.BoltStore) *BaselineAnalyzer {
 	return &BaselineAnalyzer{
 		store: store,
+		activity: ActivityConfig{
+			MinQueries: 1000,
+			MinShare:   0.2,
+		},
+		profiles:      make(map[string]*storage.ActivityProfile),
+		dirtyProfiles: make(map[string]bool),
 	}
 }
 
+// SetActivityConfig overrides the off-hours detection thresholds
+func (a *BaselineAnalyzer) SetActivityConfig(config ActivityConfig) {
+	a.activityMu.Lock()
+	defer a.activityMu.Unlock()
+
+	a.activity = config
+}
+
 // ProcessQuery analyzes a DNS query and determines if it's an anomaly
 // Returns true if this is a first-seen (anomalous) query
 func (a *BaselineAnalyzer) ProcessQuery(query storage.DNSQuery) (bool, error) {
+	isNew, err := a.MarkQueryNew(query)
+	if err != nil || !isNew {
+		// Already processed this query, skip it
+		return false, err
+	}
+
+	return a.IsFirstSeen(query)
+}
+
+// MarkQueryNew records a query as processed
+// Returns true if the query had not been processed before
+func (a *BaselineAnalyzer) MarkQueryNew(query storage.DNSQuery) (bool, error) {
 	// Check if we've already processed this exact query
 	queryID := query.QueryID()
 	seen, err := a.store.HasSeenQuery(queryID)
@@ -31,7 +65,6 @@ func (a *BaselineAnalyzer) ProcessQuery(query storage.DNSQuery) (bool, error) {
 	}
 
 	if seen {
-		// Already processed this query, skip it
 		return false, nil
 	}
 
@@ -40,30 +73,65 @@ func (a *BaselineAnalyzer) ProcessQuery(query storage.DNSQuery) (bool, error) {
 		return false, fmt.Errorf("failed to mark query as processed: %w", err)
 	}
 
-	// Check if domain is in baseline
+	return true, nil
+}
+
+// IsFirstSeen returns true if the query's domain is not in the client's baseline
+func (a *BaselineAnalyzer) IsFirstSeen(query storage.DNSQuery) (bool, error) {
 	inBaseline, err := a.store.HasDomainInBaseline(query.ClientID, query.Domain)
 	if err != nil {
 		return false, fmt.Errorf("failed to check baseline: %w", err)
 	}
 
-	if !inBaseline {
-		// This is a first-seen domain for this client
-		return true, nil
-	}
-
-	return false, nil
+	return !inBaseline, nil
 }
 
 // LogAnomaly logs an anomaly event to stdout
 func (a *BaselineAnalyzer) LogAnomaly(query storage.DNSQuery) {
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
-	log.Printf("[FIRST-SEEN] Client: %s (%s) | Domain: %s | Type: %s | Time: %s",
+	offHours := ""
+	if query.OffHours {
+		offHours = " | 🌙 Off-hours"
+	}
+	log.Printf("[FIRST-SEEN] Client: %s (%s) | Domain: %s | Type: %s | Time: %s%s",
 		query.ClientName,
 		query.ClientID,
 		query.Domain,
 		query.QueryType,
 		timestamp,
+		offHours,
+	)
+}
+
+// RecordFirstSeen logs a first-seen query and stores it as a first-seen event,
+// so the dashboard has a feed even without LLM analysis
+func (a *BaselineAnalyzer) RecordFirstSeen(query storage.DNSQuery) error {
+	a.LogAnomaly(query)
+
+	event := storage.AnomalyEvent{
+		Query:      query,
+		DetectedAt: time.Now(),
+	}
+	if err := a.store.SaveEvent(&event); err != nil {
+		return fmt.Errorf("failed to save first-seen event: %w", err)
+	}
+	return nil
+}
+
+// RecordAnomaly stores an anomaly raised by one of the detectors
+func (a *BaselineAnalyzer) RecordAnomaly(anomaly *storage.Anomaly) error {
+	if err := a.store.SaveAnomaly(anomaly); err != nil {
+		return fmt.Errorf("failed to save anomaly: %w", err)
+	}
+
+	log.Printf("🚨 [%s] Client: %s (%s) | Domain: %s | %s",
+		strings.ToUpper(anomaly.Type),
+		anomaly.ClientName,
+		anomaly.ClientID,
+		anomaly.Domain,
+		anomaly.Explanation,
 	)
+	return nil
 }
 
 // ApproveAnomaly adds a domain to the client's baseline (for future use)
@@ -0,0 +1,188 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+// BeaconConfig controls how periodic query timing is detected
+type BeaconConfig struct {
+	Window      time.Duration // Rolling window of timestamps kept per (client, domain)
+	MinSamples  int           // Minimum number of queries before timing is evaluated
+	MaxJitter   float64       // Maximum jitter (stddev / mean interval) considered periodic
+	MinInterval time.Duration // Intervals shorter than this are treated as bursts, not beacons
+}
+
+// BeaconStats describes the timing of queries for a single (client, domain) pair
+type BeaconStats struct {
+	Samples      int           `json:"samples"`
+	MeanInterval time.Duration `json:"mean_interval"`
+	Jitter       float64       `json:"jitter"`      // Coefficient of variation of the intervals
+	Periodicity  float64       `json:"periodicity"` // Fraction of intervals within tolerance of the median
+}
+
+// BeaconDetector keeps per (client, domain) query timestamps over a rolling
+// window and flags domains that are queried at regular intervals
+type BeaconDetector struct {
+	config BeaconConfig
+
+	mu        sync.Mutex
+	history   map[string][]time.Time
+	lastAlert map[string]time.Time
+}
+
+// NewBeaconDetector creates a new beaconing detector
+func NewBeaconDetector(config BeaconConfig) *BeaconDetector {
+	if config.Window <= 0 {
+		config.Window = time.Hour // Default
+	}
+	if config.MinSamples < 3 {
+		config.MinSamples = 3 // Need at least two intervals to measure jitter
+	}
+	if config.MaxJitter <= 0 {
+		config.MaxJitter = 0.1 // Default
+	}
+
+	return &BeaconDetector{
+		config:    config,
+		history:   make(map[string][]time.Time),
+		lastAlert: make(map[string]time.Time),
+	}
+}
+
+// Observe records a query and returns a beaconing anomaly if the query timing
+// for this client and domain has become periodic. Returns nil otherwise.
+func (d *BeaconDetector) Observe(query storage.DNSQuery) *storage.Anomaly {
+	key := query.ClientID + "|" + query.Domain
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Record the timestamp, keeping the history sorted and free of duplicates
+	timestamps := d.history[key]
+	idx := sort.Search(len(timestamps), func(i int) bool {
+		return !timestamps[i].Before(query.Timestamp)
+	})
+	if idx < len(timestamps) && timestamps[idx].Equal(query.Timestamp) {
+		return nil // Already recorded
+	}
+	timestamps = append(timestamps, time.Time{})
+	copy(timestamps[idx+1:], timestamps[idx:])
+	timestamps[idx] = query.Timestamp
+
+	// Drop timestamps that fell out of the rolling window
+	cutoff := timestamps[len(timestamps)-1].Add(-d.config.Window)
+	start := sort.Search(len(timestamps), func(i int) bool {
+		return timestamps[i].After(cutoff)
+	})
+	timestamps = timestamps[start:]
+	d.history[key] = timestamps
+
+	if len(timestamps) < d.config.MinSamples {
+		return nil
+	}
+
+	// Only alert once per window for the same client and domain. Query time is
+	// used like the window itself, so bursty polling neither suppresses nor repeats alerts.
+	if last, ok := d.lastAlert[key]; ok && query.Timestamp.Sub(last) < d.config.Window {
+		return nil
+	}
+
+	stats := computeBeaconStats(timestamps)
+	if stats.MeanInterval < d.config.MinInterval || stats.Jitter > d.config.MaxJitter {
+		return nil
+	}
+
+	d.lastAlert[key] = query.Timestamp
+
+	return &storage.Anomaly{
+		Type:           storage.AnomalyTypeBeaconing,
+		Domain:         query.Domain,
+		ClientID:       query.ClientID,
+		ClientName:     query.ClientName,
+		QueryType:      query.QueryType,
+		Classification: "Suspicious",
+		RiskScore:      6,
+		Explanation: fmt.Sprintf("Queried every ~%s with %.1f%% jitter over the last %d queries, consistent with automated beaconing",
+			stats.MeanInterval.Round(time.Second), stats.Jitter*100, stats.Samples),
+		SuggestedAction: "Investigate",
+		DetectedAt:      time.Now(),
+		Details: map[string]string{
+			"interval":         stats.MeanInterval.Round(time.Second).String(),
+			"interval_seconds": fmt.Sprintf("%.1f", stats.MeanInterval.Seconds()),
+			"jitter":           fmt.Sprintf("%.3f", stats.Jitter),
+			"periodicity":      fmt.Sprintf("%.2f", stats.Periodicity),
+			"samples":          fmt.Sprintf("%d", stats.Samples),
+		},
+	}
+}
+
+// Prune removes histories that have no queries inside the rolling window
+func (d *BeaconDetector) Prune(now time.Time) {
+	cutoff := now.Add(-d.config.Window)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for key, timestamps := range d.history {
+		if len(timestamps) == 0 || timestamps[len(timestamps)-1].Before(cutoff) {
+			delete(d.history, key)
+		}
+	}
+	for key, last := range d.lastAlert {
+		if last.Before(cutoff) {
+			delete(d.lastAlert, key)
+		}
+	}
+}
+
+// computeBeaconStats measures the mean interval, jitter and periodicity of sorted timestamps
+func computeBeaconStats(timestamps []time.Time) BeaconStats {
+	stats := BeaconStats{Samples: len(timestamps)}
+	if len(timestamps) < 3 {
+		return stats
+	}
+
+	intervals := make([]float64, 0, len(timestamps)-1)
+	sum := 0.0
+	for i := 1; i < len(timestamps); i++ {
+		interval := timestamps[i].Sub(timestamps[i-1]).Seconds()
+		intervals = append(intervals, interval)
+		sum += interval
+	}
+
+	mean := sum / float64(len(intervals))
+	if mean <= 0 {
+		return stats
+	}
+
+	variance := 0.0
+	for _, interval := range intervals {
+		variance += (interval - mean) * (interval - mean)
+	}
+	variance /= float64(len(intervals))
+
+	// Periodicity: share of intervals within 10% of the median interval
+	sorted := make([]float64, len(intervals))
+	copy(sorted, intervals)
+	sort.Float64s(sorted)
+	median := sorted[len(sorted)/2]
+
+	regular := 0
+	for _, interval := range intervals {
+		if math.Abs(interval-median) <= median*0.1 {
+			regular++
+		}
+	}
+
+	stats.MeanInterval = time.Duration(mean * float64(time.Second))
+	stats.Jitter = math.Sqrt(variance) / mean
+	stats.Periodicity = float64(regular) / float64(len(intervals))
+
+	return stats
+}
@@ -0,0 +1,116 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/enrichment"
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+// DomainAgeChecker flags first-seen domains whose WHOIS creation date is more
+// recent than a configurable age. It runs independently of the LLM.
+type DomainAgeChecker struct {
+	baseline *BaselineAnalyzer
+	whois    *enrichment.WHOISService
+	maxAge   time.Duration
+
+	queue  chan storage.DNSQuery
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewDomainAgeChecker creates a new domain age checker and starts its worker
+func NewDomainAgeChecker(baseline *BaselineAnalyzer, whois *enrichment.WHOISService, maxAge time.Duration) *DomainAgeChecker {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if maxAge <= 0 {
+		maxAge = 30 * 24 * time.Hour // Default
+	}
+
+	c := &DomainAgeChecker{
+		baseline: baseline,
+		whois:    whois,
+		maxAge:   maxAge,
+		queue:    make(chan storage.DNSQuery, 500), // WHOIS lookups are slow, buffer bursts
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	c.wg.Add(1)
+	go c.worker()
+
+	return c
+}
+
+// CheckAsync queues a first-seen query for a domain age check
+func (c *DomainAgeChecker) CheckAsync(query storage.DNSQuery) {
+	select {
+	case c.queue <- query:
+	default:
+		log.Printf("⚠️  [DomainAge] Queue full, skipping age check for %s", query.Domain)
+	}
+}
+
+// worker performs queued WHOIS lookups one at a time
+func (c *DomainAgeChecker) worker() {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case query := <-c.queue:
+			if err := c.check(query); err != nil {
+				log.Printf("⚠️  [DomainAge] Check failed for %s: %v", query.Domain, err)
+			}
+		}
+	}
+}
+
+// check looks up the registrable domain and records an anomaly if it is too young
+func (c *DomainAgeChecker) check(query storage.DNSQuery) error {
+	registrable := enrichment.RegistrableDomain(query.Domain)
+
+	whois, err := c.whois.Lookup(registrable)
+	if err != nil {
+		return fmt.Errorf("WHOIS lookup failed: %w", err)
+	}
+
+	ageDays, ok := whois.AgeDays(time.Now())
+	if !ok || time.Since(whois.CreatedAt) >= c.maxAge {
+		return nil
+	}
+
+	anomaly := &storage.Anomaly{
+		Type:            storage.AnomalyTypeNewDomain,
+		Domain:          query.Domain,
+		ClientID:        query.ClientID,
+		ClientName:      query.ClientName,
+		QueryType:       query.QueryType,
+		Classification:  "Suspicious",
+		RiskScore:       8,
+		Explanation:     fmt.Sprintf("%s was registered %d days ago (%s); newly registered domains are frequently used for phishing and malware", registrable, ageDays, whois.CreatedAt.Format("2006-01-02")),
+		SuggestedAction: "Investigate",
+		DetectedAt:      time.Now(),
+		OffHours:        query.OffHours,
+		DomainCreatedAt: whois.CreatedAt,
+		Details: map[string]string{
+			"registrable_domain": registrable,
+			"age_days":           fmt.Sprintf("%d", ageDays),
+			"registrar":          whois.Registrar,
+		},
+	}
+
+	return c.baseline.RecordAnomaly(anomaly)
+}
+
+// Stop shuts down the worker
+func (c *DomainAgeChecker) Stop() {
+	c.cancel()
+	c.wg.Wait()
+}
@@ -0,0 +1,47 @@
+package analyzer
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+// eventPruneInterval is how often expired first-seen events are removed
+const eventPruneInterval = time.Hour
+
+// EventPruner periodically deletes first-seen events older than the retention period
+type EventPruner struct {
+	store     *storage.BoltStore
+	retention time.Duration
+}
+
+// NewEventPruner creates a pruner that keeps events for the given retention period
+func NewEventPruner(store *storage.BoltStore, retention time.Duration) *EventPruner {
+	return &EventPruner{
+		store:     store,
+		retention: retention,
+	}
+}
+
+// Start prunes events on a fixed interval until ctx is cancelled
+func (p *EventPruner) Start(ctx context.Context) {
+	ticker := time.NewTicker(eventPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := p.store.CleanOldEvents(p.retention)
+		if err != nil {
+			log.Printf("⚠️  [Events] Failed to prune first-seen events: %v", err)
+		} else if deleted > 0 {
+			log.Printf("🧹 [Events] Pruned %d first-seen events older than %s", deleted, p.retention)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
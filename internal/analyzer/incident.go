@@ -0,0 +1,314 @@
+package analyzer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+const (
+	// incidentInterval is how often anomalies are correlated into incidents
+	incidentInterval = time.Minute
+
+	// incidentLookback limits correlation to recent anomalies
+	incidentLookback = 7 * 24 * time.Hour
+)
+
+// incidentTransitions lists the statuses each incident status may move to
+var incidentTransitions = map[string][]string{
+	storage.IncidentStatusOpen:          {storage.IncidentStatusInvestigating, storage.IncidentStatusResolved, storage.IncidentStatusDismissed},
+	storage.IncidentStatusInvestigating: {storage.IncidentStatusOpen, storage.IncidentStatusResolved, storage.IncidentStatusDismissed},
+	storage.IncidentStatusResolved:      {storage.IncidentStatusOpen},
+	storage.IncidentStatusDismissed:     {storage.IncidentStatusOpen},
+}
+
+// IncidentConfig controls how anomalies are grouped into incidents
+type IncidentConfig struct {
+	Window       time.Duration // Maximum gap between anomalies of the same incident
+	MinAnomalies int           // Anomalies a client needs within the window to open an incident
+}
+
+// IncidentCorrelator periodically groups anomalies from the same client that
+// happened within a time window into incidents
+type IncidentCorrelator struct {
+	store  *storage.BoltStore
+	config IncidentConfig
+
+	mu sync.Mutex // Serializes correlation runs and status changes
+}
+
+// incidentEvent is an anomaly as seen by one affected client
+type incidentEvent struct {
+	time       time.Time
+	clientName string
+	anomaly    storage.Anomaly
+}
+
+// NewIncidentCorrelator creates a new incident correlator
+func NewIncidentCorrelator(store *storage.BoltStore, config IncidentConfig) *IncidentCorrelator {
+	if config.Window <= 0 {
+		config.Window = 10 * time.Minute // Default
+	}
+	if config.MinAnomalies <= 0 {
+		config.MinAnomalies = 2 // Default
+	}
+
+	return &IncidentCorrelator{
+		store:  store,
+		config: config,
+	}
+}
+
+// Start correlates anomalies on a fixed interval until ctx is cancelled
+func (c *IncidentCorrelator) Start(ctx context.Context) {
+	ticker := time.NewTicker(incidentInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := c.Correlate(); err != nil {
+			log.Printf("⚠️  [Incidents] Correlation failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Correlate groups recent anomalies into incidents, extending existing
+// incidents when new anomalies fall inside their window
+func (c *IncidentCorrelator) Correlate() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	anomalies, err := c.store.GetAllAnomalies("")
+	if err != nil {
+		return fmt.Errorf("failed to load anomalies: %w", err)
+	}
+	incidents, err := c.store.GetAllIncidents("")
+	if err != nil {
+		return fmt.Errorf("failed to load incidents: %w", err)
+	}
+
+	byID := make(map[string]storage.Anomaly, len(anomalies))
+	for _, anomaly := range anomalies {
+		byID[anomaly.ID] = anomaly
+	}
+
+	// Index existing incidents by client and anomaly
+	owner := make(map[string]*storage.Incident)
+	for i := range incidents {
+		for _, anomalyID := range incidents[i].AnomalyIDs {
+			owner[incidents[i].ClientID+"|"+anomalyID] = &incidents[i]
+		}
+	}
+
+	// Collect recent events per client; grouped anomalies count once per affected client
+	cutoff := time.Now().Add(-incidentLookback)
+	events := make(map[string][]incidentEvent)
+	for _, anomaly := range anomalies {
+		if anomaly.Status == "approved" {
+			continue // Reviewed as benign
+		}
+		for _, client := range anomaly.Clients() {
+			at := client.FirstSeen
+			if at.IsZero() {
+				at = anomaly.DetectedAt
+			}
+			if at.Before(cutoff) {
+				continue
+			}
+			events[client.ClientID] = append(events[client.ClientID], incidentEvent{
+				time:       at,
+				clientName: client.ClientName,
+				anomaly:    anomaly,
+			})
+		}
+	}
+
+	for clientID, clientEvents := range events {
+		sort.Slice(clientEvents, func(i, j int) bool {
+			return clientEvents[i].time.Before(clientEvents[j].time)
+		})
+
+		// Split the client's events wherever the gap exceeds the window
+		start := 0
+		for i := 1; i <= len(clientEvents); i++ {
+			if i < len(clientEvents) && clientEvents[i].time.Sub(clientEvents[i-1].time) <= c.config.Window {
+				continue
+			}
+			if err := c.updateIncident(clientID, clientEvents[start:i], owner, byID); err != nil {
+				return err
+			}
+			start = i
+		}
+	}
+
+	return nil
+}
+
+// updateIncident creates or extends the incident covering a cluster of events
+func (c *IncidentCorrelator) updateIncident(clientID string, cluster []incidentEvent, owner map[string]*storage.Incident, byID map[string]storage.Anomaly) error {
+	// Find incidents that already hold any of the cluster's anomalies
+	var existing []*storage.Incident
+	seen := make(map[string]bool)
+	for _, event := range cluster {
+		if incident, ok := owner[clientID+"|"+event.anomaly.ID]; ok && !seen[incident.ID] {
+			seen[incident.ID] = true
+			existing = append(existing, incident)
+		}
+	}
+
+	if len(existing) == 0 && len(cluster) < c.config.MinAnomalies {
+		return nil
+	}
+
+	now := time.Now()
+	var incident *storage.Incident
+	if len(existing) > 0 {
+		sort.Slice(existing, func(i, j int) bool {
+			return existing[i].StartedAt.Before(existing[j].StartedAt)
+		})
+		incident = existing[0]
+	} else {
+		incident = &storage.Incident{
+			ID:         fmt.Sprintf("%s|%s", clientID, cluster[0].time.UTC().Format(time.RFC3339)),
+			ClientID:   clientID,
+			ClientName: cluster[0].clientName,
+			Status:     storage.IncidentStatusOpen,
+			CreatedAt:  now,
+		}
+	}
+
+	// Union of anomalies already on the incident(s) and in the cluster
+	ids := make(map[string]time.Time)
+	for _, inc := range existing {
+		for _, event := range inc.Timeline {
+			ids[event.AnomalyID] = event.Time
+		}
+	}
+	added := 0
+	for _, event := range cluster {
+		if _, ok := ids[event.anomaly.ID]; !ok {
+			added++
+		}
+		ids[event.anomaly.ID] = event.time
+	}
+
+	if added == 0 && len(existing) == 1 {
+		return nil // Nothing new
+	}
+
+	// Rebuild the timeline in chronological order
+	incident.Timeline = incident.Timeline[:0]
+	for anomalyID, at := range ids {
+		event := storage.IncidentEvent{Time: at, AnomalyID: anomalyID}
+		if anomaly, ok := byID[anomalyID]; ok {
+			event.Type = storage.NormalizeAnomalyType(anomaly.Type)
+			event.Domain = anomaly.Domain
+			event.Classification = anomaly.Classification
+			event.RiskScore = anomaly.RiskScore
+			event.Explanation = anomaly.Explanation
+		}
+		incident.Timeline = append(incident.Timeline, event)
+	}
+	sort.Slice(incident.Timeline, func(i, j int) bool {
+		if !incident.Timeline[i].Time.Equal(incident.Timeline[j].Time) {
+			return incident.Timeline[i].Time.Before(incident.Timeline[j].Time)
+		}
+		return incident.Timeline[i].AnomalyID < incident.Timeline[j].AnomalyID
+	})
+
+	incident.AnomalyIDs = make([]string, 0, len(incident.Timeline))
+	for _, event := range incident.Timeline {
+		incident.AnomalyIDs = append(incident.AnomalyIDs, event.AnomalyID)
+	}
+	incident.StartedAt = incident.Timeline[0].Time
+	incident.LastSeenAt = incident.Timeline[len(incident.Timeline)-1].Time
+	incident.Severity, incident.MaxRiskScore = incidentSeverity(incident.Timeline)
+	incident.UpdatedAt = now
+
+	// New activity reopens a resolved incident; dismissed incidents stay dismissed
+	if added > 0 && incident.Status == storage.IncidentStatusResolved {
+		incident.Status = storage.IncidentStatusOpen
+		log.Printf("🔁 [Incidents] Reopened %s: %d new anomalies", incident.ID, added)
+	}
+
+	if err := c.store.SaveIncident(incident); err != nil {
+		return fmt.Errorf("failed to save incident: %w", err)
+	}
+
+	// Incidents bridged by the cluster are merged into the earliest one
+	for _, other := range existing[min(1, len(existing)):] {
+		if err := c.store.DeleteIncident(other.ID); err != nil {
+			return fmt.Errorf("failed to delete merged incident: %w", err)
+		}
+		log.Printf("🔗 [Incidents] Merged %s into %s", other.ID, incident.ID)
+	}
+
+	for _, anomalyID := range incident.AnomalyIDs {
+		owner[clientID+"|"+anomalyID] = incident
+	}
+
+	if len(existing) == 0 {
+		log.Printf("🧩 [Incidents] New %s incident for %s: %d anomalies starting %s",
+			incident.Severity, clientID, len(incident.Timeline), incident.StartedAt.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// SetStatus moves an incident to a new status, enforcing the status workflow
+func (c *IncidentCorrelator) SetStatus(id, status string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	incident, err := c.store.GetIncidentByID(id)
+	if err != nil {
+		return err
+	}
+
+	allowed := false
+	for _, next := range incidentTransitions[incident.Status] {
+		if next == status {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("cannot change incident from %s to %s", incident.Status, status)
+	}
+
+	return c.store.UpdateIncidentStatus(id, status)
+}
+
+// incidentSeverity aggregates the anomalies on a timeline into an incident
+// severity and returns the highest risk score
+func incidentSeverity(timeline []storage.IncidentEvent) (string, int) {
+	maxRisk := 0
+	malicious := 0
+	for _, event := range timeline {
+		maxRisk = max(maxRisk, event.RiskScore)
+		if event.Classification == "Malicious" {
+			malicious++
+		}
+	}
+
+	switch {
+	case maxRisk >= 9 || malicious >= 2:
+		return "critical", maxRisk
+	case maxRisk >= 8 || malicious == 1 || (maxRisk >= 6 && len(timeline) >= 3):
+		return "high", maxRisk
+	case maxRisk >= 6 || len(timeline) >= 3:
+		return "medium", maxRisk
+	default:
+		return "low", maxRisk
+	}
+}
@@ -0,0 +1,47 @@
+package analyzer
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+// BaselineSnapshotter takes periodic snapshots of all client baselines
+type BaselineSnapshotter struct {
+	store    *storage.BoltStore
+	interval time.Duration
+	retain   int
+}
+
+// NewBaselineSnapshotter creates a snapshotter that keeps the newest retain snapshots
+func NewBaselineSnapshotter(store *storage.BoltStore, interval time.Duration, retain int) *BaselineSnapshotter {
+	return &BaselineSnapshotter{
+		store:    store,
+		interval: interval,
+		retain:   retain,
+	}
+}
+
+// Start snapshots the baselines on the configured interval until ctx is cancelled
+func (s *BaselineSnapshotter) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			snapshot, err := s.store.CreateBaselineSnapshot(storage.SnapshotReasonScheduled, s.retain)
+			if err != nil {
+				log.Printf("⚠️  [Snapshots] Failed to snapshot baselines: %v", err)
+				continue
+			}
+			summary := snapshot.Summary()
+			log.Printf("📸 [Snapshots] Saved baseline snapshot %s (%d clients, %d domains)",
+				summary.ID, summary.Clients, summary.Domains)
+		}
+	}
+}
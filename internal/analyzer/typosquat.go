@@ -0,0 +1,407 @@
+package analyzer
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/net/publicsuffix"
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/eiladin/guardian-log/internal/enrichment"
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+// defaultBrandDomains are commonly impersonated domains that are always protected
+var defaultBrandDomains = []string{
+	"google.com", "gmail.com", "youtube.com", "apple.com", "icloud.com",
+	"microsoft.com", "live.com", "outlook.com", "office.com", "amazon.com",
+	"paypal.com", "facebook.com", "instagram.com", "whatsapp.com", "netflix.com",
+	"linkedin.com", "twitter.com", "x.com", "dropbox.com", "github.com",
+}
+
+// confusables maps look-alike characters to the ASCII letter they imitate.
+// Accents are handled separately by Unicode decomposition.
+var confusables = map[rune]rune{
+	// Cyrillic
+	'а': 'a', 'в': 'b', 'е': 'e', 'ё': 'e', 'һ': 'h', 'і': 'i', 'ї': 'i', 'ј': 'j',
+	'к': 'k', 'ӏ': 'l', 'м': 'm', 'о': 'o', 'р': 'p', 'ԛ': 'q', 'ѕ': 's', 'т': 't',
+	'ц': 'u', 'ԝ': 'w', 'х': 'x', 'у': 'y', 'ԁ': 'd', 'ɡ': 'g', 'ь': 'b', 'п': 'n',
+	// Greek
+	'α': 'a', 'β': 'b', 'ε': 'e', 'η': 'n', 'ι': 'i', 'κ': 'k', 'ν': 'v', 'ο': 'o',
+	'ρ': 'p', 'τ': 't', 'υ': 'u', 'χ': 'x', 'ω': 'w',
+	// Latin look-alikes
+	'ı': 'i', 'ł': 'l', 'ſ': 's', 'ø': 'o', 'đ': 'd', 'ħ': 'h',
+	// ASCII digits commonly swapped for letters
+	'0': 'o', '1': 'l', '3': 'e', '5': 's',
+}
+
+// asciiConfusables are multi-letter ASCII sequences that render like a single letter
+var asciiConfusables = strings.NewReplacer("rn", "m", "vv", "w", "cl", "d")
+
+// keyboardRows is the QWERTY layout used to detect fat-finger typos
+var keyboardRows = []struct {
+	keys   string
+	offset float64
+}{
+	{"1234567890-", 0},
+	{"qwertyuiop", 0.5},
+	{"asdfghjkl", 0.75},
+	{"zxcvbnm", 1.25},
+}
+
+// TyposquatConfig controls typosquatting and homograph detection
+type TyposquatConfig struct {
+	BrandDomains []string // Extra protected domains, e.g. banks
+	BaselineTopN int      // Number of top registrable domains protected from each client's baseline
+	TLDSwap      bool     // Flag same-name/different-TLD domains for every protected domain, not just BrandDomains
+}
+
+// typosquatMatch describes a protected domain a candidate imitates
+type typosquatMatch struct {
+	protected string
+	technique string
+	distance  int
+}
+
+// protectedSet is a cached list of protected registrable domains for a client
+type protectedSet struct {
+	domains  []string
+	loadedAt time.Time
+}
+
+// TyposquatDetector compares first-seen domains against a protected list made
+// of brand domains and the most used domains from the client's own baseline
+type TyposquatDetector struct {
+	store  *storage.BoltStore
+	brands []string
+	topN   int
+
+	// Big brands own their name under many TLDs (google.de, facebook.net), so
+	// TLD swaps are only flagged for the configured brands unless tldSwapAll is set
+	tldSwap    map[string]bool
+	tldSwapAll bool
+
+	mu    sync.Mutex
+	cache map[string]*protectedSet
+}
+
+// NewTyposquatDetector creates a new typosquatting detector
+func NewTyposquatDetector(store *storage.BoltStore, config TyposquatConfig) *TyposquatDetector {
+	if config.BaselineTopN < 0 {
+		config.BaselineTopN = 0
+	}
+
+	seen := make(map[string]bool)
+	brands := make([]string, 0, len(defaultBrandDomains)+len(config.BrandDomains))
+	for _, domain := range append(defaultBrandDomains, config.BrandDomains...) {
+		domain = enrichment.RegistrableDomain(strings.TrimSpace(domain))
+		if domain != "" && !seen[domain] {
+			seen[domain] = true
+			brands = append(brands, domain)
+		}
+	}
+
+	tldSwap := make(map[string]bool, len(config.BrandDomains))
+	for _, domain := range config.BrandDomains {
+		if domain = enrichment.RegistrableDomain(strings.TrimSpace(domain)); domain != "" {
+			tldSwap[domain] = true
+		}
+	}
+
+	return &TyposquatDetector{
+		store:      store,
+		brands:     brands,
+		topN:       config.BaselineTopN,
+		tldSwap:    tldSwap,
+		tldSwapAll: config.TLDSwap,
+		cache:      make(map[string]*protectedSet),
+	}
+}
+
+// Check compares a first-seen query against the client's protected domains
+// Returns an anomaly naming the impersonated domain, or nil if there is no match
+func (d *TyposquatDetector) Check(query storage.DNSQuery) (*storage.Anomaly, error) {
+	// Work on the Unicode form so homographs hidden behind punycode are visible
+	unicodeDomain := query.UnicodeDomain
+	if unicodeDomain == "" {
+		decoded, err := idna.ToUnicode(query.Domain)
+		if err != nil {
+			decoded = query.Domain
+		}
+		unicodeDomain = decoded
+	}
+	unicodeDomain = strings.ToLower(strings.TrimSuffix(unicodeDomain, "."))
+
+	candidate := enrichment.RegistrableDomain(unicodeDomain)
+
+	protected, err := d.protectedDomains(query.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nothing to report if the candidate is itself protected
+	for _, domain := range protected {
+		if candidate == domain || enrichment.RegistrableDomain(query.Domain) == domain {
+			return nil, nil
+		}
+	}
+
+	var best *typosquatMatch
+	for _, domain := range protected {
+		if match := compareDomains(candidate, domain, d.tldSwapAll || d.tldSwap[domain]); match != nil {
+			if best == nil || match.distance < best.distance {
+				best = match
+			}
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+
+	classification := "Suspicious"
+	riskScore := 7
+	suggestedAction := "Investigate"
+	if best.technique == "homograph" {
+		// Look-alike Unicode characters have no legitimate reason to mimic a brand
+		classification = "Malicious"
+		riskScore = 9
+		suggestedAction = "Block"
+	}
+
+	displayed := query.Domain
+	if unicodeDomain != query.Domain {
+		displayed = fmt.Sprintf("%s (%s)", unicodeDomain, query.Domain)
+	}
+
+	return &storage.Anomaly{
+		Type:            storage.AnomalyTypeTyposquat,
+		Domain:          query.Domain,
+		ClientID:        query.ClientID,
+		ClientName:      query.ClientName,
+		QueryType:       query.QueryType,
+		Classification:  classification,
+		RiskScore:       riskScore,
+		Explanation:     fmt.Sprintf("%s appears to impersonate %s (%s)", displayed, best.protected, techniqueDescription(best.technique)),
+		SuggestedAction: suggestedAction,
+		DetectedAt:      time.Now(),
+		OffHours:        query.OffHours,
+		Details: map[string]string{
+			"impersonates":   best.protected,
+			"technique":      best.technique,
+			"unicode_domain": unicodeDomain,
+		},
+	}, nil
+}
+
+// protectedDomains returns the brand domains plus the client's top baseline domains
+func (d *TyposquatDetector) protectedDomains(clientID string) ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if cached, ok := d.cache[clientID]; ok && time.Since(cached.loadedAt) < 10*time.Minute {
+		return cached.domains, nil
+	}
+
+	domains := append([]string(nil), d.brands...)
+
+	if d.topN > 0 {
+		baseline, err := d.store.GetClientBaseline(clientID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get baseline: %w", err)
+		}
+
+		// Rank registrable domains by how many of their hostnames the client uses
+		counts := make(map[string]int)
+		for _, domain := range baseline.Domains {
+			counts[enrichment.RegistrableDomain(domain)]++
+		}
+		ranked := make([]string, 0, len(counts))
+		for domain := range counts {
+			ranked = append(ranked, domain)
+		}
+		sort.Slice(ranked, func(i, j int) bool {
+			if counts[ranked[i]] != counts[ranked[j]] {
+				return counts[ranked[i]] > counts[ranked[j]]
+			}
+			return ranked[i] < ranked[j]
+		})
+		if len(ranked) > d.topN {
+			ranked = ranked[:d.topN]
+		}
+		domains = append(domains, ranked...)
+	}
+
+	d.cache[clientID] = &protectedSet{domains: domains, loadedAt: time.Now()}
+	return domains, nil
+}
+
+// compareDomains checks whether candidate imitates protected, trying the most
+// specific technique first. TLD swaps are only reported with tldSwap set.
+func compareDomains(candidate, protected string, tldSwap bool) *typosquatMatch {
+	candidateName, candidateSuffix := splitRegistrable(candidate)
+	protectedName, protectedSuffix := splitRegistrable(protected)
+
+	// Very short names produce too many accidental matches
+	if len([]rune(protectedName)) < 4 {
+		return nil
+	}
+
+	// IDN homograph: non-ASCII characters that collapse to the protected name
+	if !isASCII(candidateName) && skeleton(candidateName) == skeleton(protectedName) {
+		return &typosquatMatch{protected: protected, technique: "homograph", distance: 0}
+	}
+
+	// ASCII look-alikes such as "paypa1" or "rnicrosoft"
+	if candidateName != protectedName && skeleton(candidateName) == skeleton(protectedName) {
+		return &typosquatMatch{protected: protected, technique: "lookalike", distance: 0}
+	}
+
+	// Same name under a different TLD
+	if tldSwap && candidateName == protectedName && candidateSuffix != protectedSuffix {
+		return &typosquatMatch{protected: protected, technique: "tld_swap", distance: 0}
+	}
+
+	if candidateSuffix != protectedSuffix {
+		return nil
+	}
+
+	// Single adjacent-key substitution
+	if isKeyboardTypo(candidateName, protectedName) {
+		return &typosquatMatch{protected: protected, technique: "keyboard", distance: 1}
+	}
+
+	// Small edit distance, scaled with the length of the protected name
+	maxDistance := 1
+	if len([]rune(protectedName)) >= 10 {
+		maxDistance = 2
+	}
+	if distance := editDistance(candidateName, protectedName); distance > 0 && distance <= maxDistance {
+		return &typosquatMatch{protected: protected, technique: "edit_distance", distance: distance}
+	}
+
+	return nil
+}
+
+// techniqueDescription returns a human readable description of a matching technique
+func techniqueDescription(technique string) string {
+	switch technique {
+	case "homograph":
+		return "IDN homograph using look-alike Unicode characters"
+	case "lookalike":
+		return "look-alike character substitution"
+	case "tld_swap":
+		return "same name under a different TLD"
+	case "keyboard":
+		return "adjacent keyboard key typo"
+	default:
+		return "small spelling difference"
+	}
+}
+
+// splitRegistrable splits a registrable domain into its name and public suffix
+func splitRegistrable(domain string) (string, string) {
+	suffix, _ := publicsuffix.PublicSuffix(domain)
+	name := strings.TrimSuffix(strings.TrimSuffix(domain, suffix), ".")
+	return name, suffix
+}
+
+// skeleton reduces a label to the ASCII characters it visually resembles
+func skeleton(label string) string {
+	var sb strings.Builder
+	for _, r := range norm.NFD.String(label) {
+		if unicode.Is(unicode.Mn, r) {
+			continue // Drop accents and other combining marks
+		}
+		if mapped, ok := confusables[r]; ok {
+			r = mapped
+		}
+		sb.WriteRune(r)
+	}
+	return asciiConfusables.Replace(sb.String())
+}
+
+// isASCII returns true if s only contains ASCII characters
+func isASCII(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII {
+			return false
+		}
+	}
+	return true
+}
+
+// isKeyboardTypo returns true if a and b differ by exactly one pair of adjacent QWERTY keys
+func isKeyboardTypo(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	diffs := 0
+	var x, y byte
+	for i := 0; i < len(a); i++ {
+		if a[i] != b[i] {
+			diffs++
+			x, y = a[i], b[i]
+		}
+	}
+	if diffs != 1 {
+		return false
+	}
+
+	xRow, xCol, okX := keyPosition(x)
+	yRow, yCol, okY := keyPosition(y)
+	if !okX || !okY {
+		return false
+	}
+
+	rowDiff := math.Abs(float64(xRow - yRow))
+	colDiff := math.Abs(xCol - yCol)
+	return (rowDiff == 0 && colDiff == 1) || (rowDiff == 1 && colDiff <= 0.75)
+}
+
+// keyPosition returns the row and staggered column of a key on a QWERTY keyboard
+func keyPosition(key byte) (int, float64, bool) {
+	for row, r := range keyboardRows {
+		if col := strings.IndexByte(r.keys, key); col >= 0 {
+			return row, float64(col) + r.offset, true
+		}
+	}
+	return 0, 0, false
+}
+
+// editDistance returns the optimal string alignment distance between a and b
+// (Levenshtein distance that also counts adjacent transpositions as one edit)
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	rows, cols := len(ra)+1, len(rb)+1
+
+	dist := make([][]int, rows)
+	for i := range dist {
+		dist[i] = make([]int, cols)
+		dist[i][0] = i
+	}
+	for j := 0; j < cols; j++ {
+		dist[0][j] = j
+	}
+
+	for i := 1; i < rows; i++ {
+		for j := 1; j < cols; j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			dist[i][j] = min(dist[i-1][j]+1, dist[i][j-1]+1, dist[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				dist[i][j] = min(dist[i][j], dist[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return dist[rows-1][cols-1]
+}
@@ -0,0 +1,304 @@
+package analyzer
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+const (
+	// volumeAlpha is the EWMA smoothing factor applied once per minute
+	volumeAlpha = 0.1
+
+	// volumeMaxIdleMinutes caps how many idle minutes are folded into the baseline at once
+	volumeMaxIdleMinutes = 60
+
+	// volumeAlertCooldown prevents repeated alerts for the same client and metric
+	volumeAlertCooldown = 15 * time.Minute
+
+	// Minimum standard deviations so a perfectly flat baseline doesn't alert on noise
+	minQPMStdDev   = 1.0
+	minRatioStdDev = 0.1
+)
+
+// VolumeConfig controls per-client volume spike detection
+type VolumeConfig struct {
+	ZThreshold    float64 // Z-score above the EWMA baseline that raises an anomaly
+	MinQueries    int     // Minimum queries in a minute before it can raise an anomaly
+	WarmupMinutes int     // Minutes of history required before alerting
+}
+
+// volumeBucket counts the queries of a single client within one minute
+type volumeBucket struct {
+	start    time.Time
+	total    int
+	nxdomain int
+	blocked  int
+	domains  map[string]int
+	nxNames  map[string]int
+	blkNames map[string]int
+	offHours bool // At least one query was outside the client's active hours
+}
+
+// volumeState is the in-memory state kept per client
+type volumeState struct {
+	stats     storage.ClientStats
+	bucket    *volumeBucket
+	lastAlert map[string]time.Time
+}
+
+// VolumeDetector keeps rolling per-client statistics (queries per minute,
+// NXDOMAIN ratio and blocked ratio) and raises an anomaly when a minute
+// deviates sharply from the client's EWMA baseline
+type VolumeDetector struct {
+	store  *storage.BoltStore
+	config VolumeConfig
+
+	mu      sync.Mutex
+	clients map[string]*volumeState
+}
+
+// NewVolumeDetector creates a new volume detector, restoring baselines from storage
+func NewVolumeDetector(store *storage.BoltStore, config VolumeConfig) (*VolumeDetector, error) {
+	if config.ZThreshold <= 0 {
+		config.ZThreshold = 3.0 // Default
+	}
+	if config.MinQueries <= 0 {
+		config.MinQueries = 20 // Default
+	}
+	if config.WarmupMinutes < 0 {
+		config.WarmupMinutes = 0
+	}
+
+	d := &VolumeDetector{
+		store:   store,
+		config:  config,
+		clients: make(map[string]*volumeState),
+	}
+
+	existing, err := store.GetAllClientStats()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client stats: %w", err)
+	}
+	for _, stats := range existing {
+		d.clients[stats.ClientID] = &volumeState{
+			stats:     stats,
+			lastAlert: make(map[string]time.Time),
+		}
+	}
+
+	return d, nil
+}
+
+// Observe counts a query towards its client's current minute. When the query
+// starts a new minute, the previous minute is compared to the baseline and any
+// resulting anomalies are returned.
+func (d *VolumeDetector) Observe(query storage.DNSQuery) []*storage.Anomaly {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state, ok := d.clients[query.ClientID]
+	if !ok {
+		state = &volumeState{
+			stats: storage.ClientStats{
+				ClientID: query.ClientID,
+			},
+			lastAlert: make(map[string]time.Time),
+		}
+		d.clients[query.ClientID] = state
+	}
+	state.stats.ClientName = query.ClientName
+
+	var anomalies []*storage.Anomaly
+
+	minute := query.Timestamp.Truncate(time.Minute)
+	if state.bucket != nil && minute.After(state.bucket.start) {
+		anomalies = d.closeBucket(state, minute)
+		state.bucket = nil
+	}
+
+	if state.bucket == nil {
+		state.bucket = &volumeBucket{
+			start:    minute,
+			domains:  make(map[string]int),
+			nxNames:  make(map[string]int),
+			blkNames: make(map[string]int),
+		}
+	}
+
+	// Late queries from an earlier minute are counted towards the current one
+	bucket := state.bucket
+	bucket.total++
+	bucket.domains[query.Domain]++
+	if query.IsNXDomain() {
+		bucket.nxdomain++
+		bucket.nxNames[query.Domain]++
+	}
+	if query.IsBlocked() {
+		bucket.blocked++
+		bucket.blkNames[query.Domain]++
+	}
+	bucket.offHours = bucket.offHours || query.OffHours
+
+	return anomalies
+}
+
+// closeBucket scores the finished minute against the baseline, folds it into
+// the EWMA and persists the updated statistics
+func (d *VolumeDetector) closeBucket(state *volumeState, next time.Time) []*storage.Anomaly {
+	bucket := state.bucket
+	stats := &state.stats
+
+	qpm := float64(bucket.total)
+	nxRatio := float64(bucket.nxdomain) / qpm
+	blockedRatio := float64(bucket.blocked) / qpm
+
+	// Score against the baseline before it absorbs this minute
+	var anomalies []*storage.Anomaly
+	if stats.MinutesObserved >= d.config.WarmupMinutes && bucket.total >= d.config.MinQueries {
+		if z := zScore(qpm, stats.QPMMean, stats.QPMStdDev, minQPMStdDev); z >= d.config.ZThreshold {
+			if a := d.newAnomaly(state, "queries_per_minute", z, bucket.domains,
+				fmt.Sprintf("%d queries in one minute vs. a baseline of %.1f/min (z-score %.1f)",
+					bucket.total, stats.QPMMean, z)); a != nil {
+				anomalies = append(anomalies, a)
+			}
+		}
+		if z := zScore(nxRatio, stats.NXDomainMean, stats.NXDomainStdDev, minRatioStdDev); z >= d.config.ZThreshold {
+			if a := d.newAnomaly(state, "nxdomain_ratio", z, bucket.nxNames,
+				fmt.Sprintf("%.0f%% of %d queries returned NXDOMAIN vs. a baseline of %.0f%% (z-score %.1f), typical of DGA malware",
+					nxRatio*100, bucket.total, stats.NXDomainMean*100, z)); a != nil {
+				anomalies = append(anomalies, a)
+			}
+		}
+		if z := zScore(blockedRatio, stats.BlockedMean, stats.BlockedStdDev, minRatioStdDev); z >= d.config.ZThreshold {
+			if a := d.newAnomaly(state, "blocked_ratio", z, bucket.blkNames,
+				fmt.Sprintf("%.0f%% of %d queries were blocked by AdGuard Home vs. a baseline of %.0f%% (z-score %.1f)",
+					blockedRatio*100, bucket.total, stats.BlockedMean*100, z)); a != nil {
+				anomalies = append(anomalies, a)
+			}
+		}
+	}
+
+	// Fold the minute into the EWMA baselines
+	first := stats.MinutesObserved == 0
+	stats.QPMMean, stats.QPMStdDev = updateEWMA(stats.QPMMean, stats.QPMStdDev, qpm, first)
+	stats.NXDomainMean, stats.NXDomainStdDev = updateEWMA(stats.NXDomainMean, stats.NXDomainStdDev, nxRatio, first)
+	stats.BlockedMean, stats.BlockedStdDev = updateEWMA(stats.BlockedMean, stats.BlockedStdDev, blockedRatio, first)
+	stats.MinutesObserved++
+
+	// Minutes without any queries count as zero volume (ratios are undefined and skipped)
+	idle := int(next.Sub(bucket.start)/time.Minute) - 1
+	if idle > volumeMaxIdleMinutes {
+		idle = volumeMaxIdleMinutes
+	}
+	for i := 0; i < idle; i++ {
+		stats.QPMMean, stats.QPMStdDev = updateEWMA(stats.QPMMean, stats.QPMStdDev, 0, false)
+	}
+
+	stats.LastMinute = bucket.start
+	stats.QueriesPerMinute = bucket.total
+	stats.NXDomainRatio = nxRatio
+	stats.BlockedRatio = blockedRatio
+	stats.TotalQueries += int64(bucket.total)
+	stats.NXDomainQueries += int64(bucket.nxdomain)
+	stats.BlockedQueries += int64(bucket.blocked)
+	stats.UpdatedAt = time.Now()
+
+	if err := d.store.SaveClientStats(stats); err != nil {
+		log.Printf("⚠️  [Volume] Failed to save stats for %s: %v", stats.ClientID, err)
+	}
+
+	return anomalies
+}
+
+// newAnomaly builds a volume anomaly for a metric, honoring the per-metric cooldown.
+// The cooldown is measured in query time, like the buckets, so replayed or
+// backlogged queries alert the same as live ones.
+func (d *VolumeDetector) newAnomaly(state *volumeState, metric string, z float64, domains map[string]int, explanation string) *storage.Anomaly {
+	if last, ok := state.lastAlert[metric]; ok && state.bucket.start.Sub(last) < volumeAlertCooldown {
+		return nil
+	}
+	state.lastAlert[metric] = state.bucket.start
+
+	top := topDomains(domains, 5)
+	domain := ""
+	if len(top) > 0 {
+		domain = top[0]
+	}
+
+	riskScore := 5
+	if metric == "nxdomain_ratio" {
+		riskScore = 7
+	}
+
+	minute := state.bucket.start.Format(time.RFC3339)
+
+	return &storage.Anomaly{
+		// Include the metric so spikes of several metrics in one minute keep separate anomalies
+		ID:              fmt.Sprintf("%s|%s|%s|%s", storage.AnomalyTypeVolume, metric, state.stats.ClientID, minute),
+		Type:            storage.AnomalyTypeVolume,
+		Domain:          domain,
+		ClientID:        state.stats.ClientID,
+		ClientName:      state.stats.ClientName,
+		Classification:  "Suspicious",
+		RiskScore:       riskScore,
+		Explanation:     explanation,
+		SuggestedAction: "Investigate",
+		DetectedAt:      time.Now(),
+		OffHours:        state.bucket.offHours,
+		Details: map[string]string{
+			"metric":      metric,
+			"minute":      minute,
+			"queries":     fmt.Sprintf("%d", state.bucket.total),
+			"z_score":     fmt.Sprintf("%.2f", z),
+			"top_domains": strings.Join(top, ","),
+		},
+	}
+}
+
+// zScore returns how many standard deviations value is above mean
+func zScore(value, mean, stdDev, minStdDev float64) float64 {
+	if stdDev < minStdDev {
+		stdDev = minStdDev
+	}
+	return (value - mean) / stdDev
+}
+
+// updateEWMA folds a sample into an exponentially weighted mean and standard deviation
+func updateEWMA(mean, stdDev, sample float64, first bool) (float64, float64) {
+	if first {
+		return sample, 0
+	}
+
+	variance := stdDev * stdDev
+	diff := sample - mean
+	incr := volumeAlpha * diff
+	mean += incr
+	variance = (1 - volumeAlpha) * (variance + diff*incr)
+
+	return mean, math.Sqrt(variance)
+}
+
+// topDomains returns up to n domains ordered by query count
+func topDomains(counts map[string]int, n int) []string {
+	domains := make([]string, 0, len(counts))
+	for domain := range counts {
+		domains = append(domains, domain)
+	}
+	sort.Slice(domains, func(i, j int) bool {
+		if counts[domains[i]] != counts[domains[j]] {
+			return counts[domains[i]] > counts[domains[j]]
+		}
+		return domains[i] < domains[j]
+	})
+	if len(domains) > n {
+		domains = domains[:n]
+	}
+	return domains
+}
@@ -2,12 +2,18 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/url"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/eiladin/guardian-log/internal/llm"
 	"github.com/eiladin/guardian-log/internal/storage"
 )
 
@@ -46,24 +52,293 @@ func (s *Server) handleAnomalies(w http.ResponseWriter, r *http.Request) {
 	// Convert to API response format
 	response := make([]AnomalyResponse, 0, len(anomalies))
 	for _, anomaly := range anomalies {
-		response = append(response, AnomalyResponse{
-			ID:              anomaly.ID,
-			Domain:          anomaly.Domain,
-			ClientID:        anomaly.ClientID,
-			ClientName:      anomaly.ClientName,
-			QueryType:       anomaly.QueryType,
-			Classification:  anomaly.Classification,
-			RiskScore:       anomaly.RiskScore,
-			Explanation:     anomaly.Explanation,
-			SuggestedAction: anomaly.SuggestedAction,
-			DetectedAt:      anomaly.DetectedAt,
-			Status:          anomaly.Status,
-		})
+		response = append(response, toAnomalyResponse(anomaly))
 	}
 
 	respondJSON(w, http.StatusOK, response)
 }
 
+// handleEvents handles GET /api/events?client=ID&before=CURSOR&limit=N
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	filter := storage.EventFilter{
+		ClientID: query.Get("client"),
+		Before:   query.Get("before"),
+	}
+	if analyzed := query.Get("analyzed"); analyzed != "" {
+		value, err := strconv.ParseBool(analyzed)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "analyzed must be true or false")
+			return
+		}
+		filter.Analyzed = &value
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			respondError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		filter.Limit = n
+	}
+
+	page, err := s.store.GetEvents(filter)
+	if err != nil {
+		log.Printf("Error retrieving first-seen events: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to retrieve first-seen events")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, page)
+}
+
+// handleEventsBackfill handles POST /api/events/backfill?from=RFC3339&to=RFC3339
+func (s *Server) handleEventsBackfill(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if s.llmAnalyzer == nil {
+		respondError(w, http.StatusConflict, "LLM analysis is disabled")
+		return
+	}
+
+	var response BackfillResponse
+	var from, to time.Time
+	for name, target := range map[string]*time.Time{"from": &from, "to": &to} {
+		value := r.URL.Query().Get(name)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, fmt.Sprintf("%s must be an RFC3339 time", name))
+			return
+		}
+		*target = parsed
+	}
+	if !from.IsZero() {
+		response.From = &from
+	}
+	if !to.IsZero() {
+		response.To = &to
+	}
+
+	queued, err := s.llmAnalyzer.Backfill(from, to)
+	if errors.Is(err, llm.ErrBackfillRunning) {
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Error starting backfill: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to start backfill")
+		return
+	}
+
+	response.Queued = queued
+	respondJSON(w, http.StatusAccepted, response)
+}
+
+// handleLLMQueue handles GET /api/llm/queue
+func (s *Server) handleLLMQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	pending, err := s.store.GetQueuedAnalyses()
+	if err != nil {
+		log.Printf("Error retrieving LLM queue: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to retrieve LLM queue")
+		return
+	}
+	dead, err := s.store.GetDeadLetters()
+	if err != nil {
+		log.Printf("Error retrieving LLM dead letters: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to retrieve LLM queue")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, LLMQueueResponse{Pending: pending, DeadLetters: dead})
+}
+
+// handleLLMProviders handles GET /api/llm/providers
+func (s *Server) handleLLMProviders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if s.llmAnalyzer == nil {
+		respondError(w, http.StatusConflict, "LLM analysis is disabled")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, LLMProvidersResponse{Providers: s.llmAnalyzer.ProviderStatus()})
+}
+
+// handleLLMTiers handles GET /api/llm/tiers?since=RFC3339&limit=N, reporting how
+// often the escalation provider agreed with the first-stage provider
+func (s *Server) handleLLMTiers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	var since time.Time
+	if value := query.Get("since"); value != "" {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "since must be an RFC3339 time")
+			return
+		}
+		since = parsed
+	}
+	limit := storage.DefaultTierComparisonLimit
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			respondError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		limit = n
+	}
+
+	agreement, err := s.store.GetTierAgreement(since)
+	if err != nil {
+		log.Printf("Error computing tier agreement: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to retrieve tier comparisons")
+		return
+	}
+	recent, err := s.store.GetTierComparisons(limit)
+	if err != nil {
+		log.Printf("Error retrieving tier comparisons: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to retrieve tier comparisons")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, LLMTiersResponse{Agreement: agreement, Recent: recent})
+}
+
+// defaultUsageDays is the number of days of LLM usage returned when no range is given
+const defaultUsageDays = 30
+
+// handleLLMUsage handles GET /api/llm/usage?from=YYYY-MM-DD&to=YYYY-MM-DD, reporting
+// LLM token usage and cost (the last 30 days by default) and the budget status
+func (s *Server) handleLLMUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	to := time.Now()
+	if value := query.Get("to"); value != "" {
+		parsed, err := time.ParseInLocation(storage.UsageDateFormat, value, time.Local)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "to must be a YYYY-MM-DD date")
+			return
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, 1-defaultUsageDays)
+	if value := query.Get("from"); value != "" {
+		parsed, err := time.ParseInLocation(storage.UsageDateFormat, value, time.Local)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "from must be a YYYY-MM-DD date")
+			return
+		}
+		from = parsed
+	}
+	if from.After(to) {
+		respondError(w, http.StatusBadRequest, "from must not be after to")
+		return
+	}
+
+	days, err := s.store.GetUsage(from, to)
+	if err != nil {
+		log.Printf("Error retrieving LLM usage: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to retrieve LLM usage")
+		return
+	}
+
+	response := LLMUsageResponse{
+		From: from.Format(storage.UsageDateFormat),
+		To:   to.Format(storage.UsageDateFormat),
+		Days: days,
+		Total: storage.DailyUsage{
+			Providers: []storage.ProviderUsage{},
+			Clients:   []storage.ClientUsage{},
+		},
+	}
+	for _, day := range days {
+		response.Total.Merge(day)
+	}
+	storage.SortUsage(&response.Total)
+	if s.usageMeter != nil {
+		status := s.usageMeter.Status()
+		response.Budget = &status
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleLLMQueueRetry handles POST /api/llm/queue/retry, requeueing all dead-lettered analyses
+func (s *Server) handleLLMQueueRetry(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	retried, err := s.store.RetryDeadLetters()
+	if err != nil {
+		log.Printf("Error retrying LLM dead letters: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to retry dead letters")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("Requeued %d dead-lettered analyses", retried),
+	})
+}
+
+// toAnomalyResponse converts a stored anomaly to its API representation
+func toAnomalyResponse(anomaly storage.Anomaly) AnomalyResponse {
+	response := AnomalyResponse{
+		ID:              anomaly.ID,
+		Type:            storage.NormalizeAnomalyType(anomaly.Type),
+		Domain:          anomaly.Domain,
+		ClientID:        anomaly.ClientID,
+		ClientName:      anomaly.ClientName,
+		QueryType:       anomaly.QueryType,
+		Classification:  anomaly.Classification,
+		RiskScore:       anomaly.RiskScore,
+		Explanation:     anomaly.Explanation,
+		SuggestedAction: anomaly.SuggestedAction,
+		DetectedAt:      anomaly.DetectedAt,
+		Status:          anomaly.Status,
+		OffHours:        anomaly.OffHours,
+		Details:         anomaly.Details,
+		AffectedClients: anomaly.Clients(),
+	}
+	response.ClientCount = len(response.AffectedClients)
+
+	if !anomaly.DomainCreatedAt.IsZero() {
+		createdAt := anomaly.DomainCreatedAt
+		ageDays := int(time.Since(createdAt).Hours() / 24)
+		response.DomainCreatedAt = &createdAt
+		response.DomainAgeDays = &ageDays
+	}
+
+	return response
+}
+
 // handleAnomalyAction handles POST /api/anomalies/{id}/approve and /api/anomalies/{id}/block
 func (s *Server) handleAnomalyAction(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -112,7 +387,7 @@ func (s *Server) handleAnomalyAction(w http.ResponseWriter, r *http.Request) {
 			respondError(w, http.StatusInternalServerError, "Failed to approve anomaly")
 			return
 		}
-		log.Printf("✅ Anomaly approved: %s (domain: %s, client: %s)", anomalyID, anomaly.Domain, anomaly.ClientID)
+		log.Printf("✅ Anomaly approved: %s (domain: %s, clients: %d)", anomalyID, anomaly.Domain, len(anomaly.Clients()))
 
 	case "block":
 		if err := s.blockAnomaly(anomaly); err != nil {
@@ -120,7 +395,7 @@ func (s *Server) handleAnomalyAction(w http.ResponseWriter, r *http.Request) {
 			respondError(w, http.StatusInternalServerError, "Failed to block anomaly")
 			return
 		}
-		log.Printf("🚫 Anomaly blocked: %s (domain: %s, client: %s)", anomalyID, anomaly.Domain, anomaly.ClientID)
+		log.Printf("🚫 Anomaly blocked: %s (domain: %s, clients: %d)", anomalyID, anomaly.Domain, len(anomaly.Clients()))
 	}
 
 	respondJSON(w, http.StatusOK, SuccessResponse{
@@ -129,11 +404,13 @@ func (s *Server) handleAnomalyAction(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// approveAnomaly approves an anomaly by adding the domain to the baseline
+// approveAnomaly approves an anomaly by adding the domain to the baseline of every affected client
 func (s *Server) approveAnomaly(anomaly *storage.Anomaly) error {
-	// Add domain to baseline
-	if err := s.store.AddDomainToBaseline(anomaly.ClientID, anomaly.ClientName, anomaly.Domain); err != nil {
-		return fmt.Errorf("failed to add domain to baseline: %w", err)
+	// Add domain to baselines
+	for _, client := range anomaly.Clients() {
+		if err := s.store.AddDomainToBaseline(client.ClientID, client.ClientName, anomaly.Domain); err != nil {
+			return fmt.Errorf("failed to add domain to baseline: %w", err)
+		}
 	}
 
 	// Update anomaly status
@@ -202,6 +479,12 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	if val, ok := stats["suspicious_count"].(int); ok {
 		llmStats.SuspiciousCount = val
 	}
+	if val, ok := stats["llm_calls_saved"].(int); ok {
+		llmStats.LLMCallsSaved = int64(val)
+	}
+	if val, ok := stats["first_seen_events"].(int); ok {
+		llmStats.FirstSeenEvents = int64(val)
+	}
 
 	if s.llmAnalyzer != nil {
 		analyzerStats := s.llmAnalyzer.GetStats()
@@ -214,11 +497,415 @@ func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 		if val, ok := analyzerStats["failed_analyses"].(int); ok {
 			llmStats.LLMAnalysesFailed = int64(val)
 		}
+		if val, ok := analyzerStats["cache_hits"].(int); ok {
+			llmStats.LLMCacheHits = int64(val)
+		}
+		if val, ok := analyzerStats["cache_misses"].(int); ok {
+			llmStats.LLMCacheMisses = int64(val)
+		}
 	}
 
 	respondJSON(w, http.StatusOK, llmStats)
 }
 
+// handleClientStats handles GET /api/stats/clients
+func (s *Server) handleClientStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	clientStats, err := s.store.GetAllClientStats()
+	if err != nil {
+		log.Printf("Error retrieving client stats: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to retrieve client statistics")
+		return
+	}
+
+	// Busiest clients first
+	sort.Slice(clientStats, func(i, j int) bool {
+		return clientStats[i].QPMMean > clientStats[j].QPMMean
+	})
+
+	if clientStats == nil {
+		clientStats = []storage.ClientStats{}
+	}
+
+	respondJSON(w, http.StatusOK, clientStats)
+}
+
+// handleRules handles GET /api/rules
+func (s *Server) handleRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if s.ruleEngine == nil {
+		respondError(w, http.StatusNotFound, "Rule engine not enabled")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, RulesResponse{
+		LoadedAt: s.ruleEngine.LoadedAt(),
+		Rules:    s.ruleEngine.Rules(),
+	})
+}
+
+// handleRulesReload handles POST /api/rules/reload
+func (s *Server) handleRulesReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if s.ruleEngine == nil {
+		respondError(w, http.StatusNotFound, "Rule engine not enabled")
+		return
+	}
+
+	if err := s.ruleEngine.Reload(); err != nil {
+		log.Printf("Error reloading rules: %v", err)
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Failed to reload rules: %v", err))
+		return
+	}
+
+	respondJSON(w, http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("Reloaded %d rules", len(s.ruleEngine.Rules())),
+	})
+}
+
+// handleThreatIntelFeeds handles GET /api/threatintel/feeds
+func (s *Server) handleThreatIntelFeeds(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if s.threatIntel == nil {
+		respondError(w, http.StatusNotFound, "Threat-intel feeds not configured")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, s.threatIntel.Status())
+}
+
+// maxImportSize limits the size of uploaded baseline imports
+const maxImportSize = 64 << 20
+
+// handleBaselineExport handles GET /api/baselines/export?format=json|csv&client=ID
+func (s *Server) handleBaselineExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = storage.BaselineFormatJSON
+	}
+	if format != storage.BaselineFormatJSON && format != storage.BaselineFormatCSV {
+		respondError(w, http.StatusBadRequest, "Invalid format. Must be 'json' or 'csv'")
+		return
+	}
+
+	baselines, err := s.store.GetAllBaselines()
+	if err != nil {
+		log.Printf("Error retrieving baselines: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to retrieve baselines")
+		return
+	}
+
+	// Optionally export a single client
+	if clientID := r.URL.Query().Get("client"); clientID != "" {
+		filtered := baselines[:0]
+		for _, baseline := range baselines {
+			if baseline.ClientID == clientID {
+				filtered = append(filtered, baseline)
+			}
+		}
+		baselines = filtered
+	}
+
+	contentType := "application/json"
+	if format == storage.BaselineFormatCSV {
+		contentType = "text/csv"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"baselines.%s\"", format))
+
+	if err := storage.WriteBaselines(w, baselines, format); err != nil {
+		log.Printf("Error writing baseline export: %v", err)
+	}
+}
+
+// handleBaselineImport handles POST /api/baselines/import?mode=merge|replace&format=json|csv
+func (s *Server) handleBaselineImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Detect the format from the Content-Type unless given explicitly
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = storage.BaselineFormatJSON
+		if strings.Contains(r.Header.Get("Content-Type"), "csv") {
+			format = storage.BaselineFormatCSV
+		}
+	}
+
+	baselines, err := storage.ReadBaselines(http.MaxBytesReader(w, r.Body, maxImportSize), format)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Invalid baseline import: %v", err))
+		return
+	}
+
+	result, err := s.store.ImportBaselines(baselines, r.URL.Query().Get("mode"))
+	if err != nil {
+		log.Printf("Error importing baselines: %v", err)
+		respondError(w, http.StatusBadRequest, fmt.Sprintf("Failed to import baselines: %v", err))
+		return
+	}
+
+	log.Printf("📥 Imported baselines (%s): %d clients, %d domains", result.Mode, result.Clients, result.DomainsAdded)
+	respondJSON(w, http.StatusOK, result)
+}
+
+// handleSnapshots handles GET and POST /api/baselines/snapshots
+func (s *Server) handleSnapshots(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		summaries, err := s.store.ListBaselineSnapshots()
+		if err != nil {
+			log.Printf("Error listing snapshots: %v", err)
+			respondError(w, http.StatusInternalServerError, "Failed to list snapshots")
+			return
+		}
+		if summaries == nil {
+			summaries = []storage.SnapshotSummary{}
+		}
+		respondJSON(w, http.StatusOK, summaries)
+
+	case http.MethodPost:
+		snapshot, err := s.store.CreateBaselineSnapshot(storage.SnapshotReasonManual, s.config.SnapshotRetain)
+		if err != nil {
+			log.Printf("Error creating snapshot: %v", err)
+			respondError(w, http.StatusInternalServerError, "Failed to create snapshot")
+			return
+		}
+		log.Printf("📸 Baseline snapshot created: %s", snapshot.ID)
+		respondJSON(w, http.StatusCreated, snapshot.Summary())
+
+	default:
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleSnapshot handles GET /api/baselines/snapshots/{id} and
+// POST /api/baselines/snapshots/{id}/rollback?client=ID
+func (s *Server) handleSnapshot(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/baselines/snapshots/")
+	parts := strings.Split(path, "/")
+
+	snapshotID, err := url.PathUnescape(parts[0])
+	if err != nil || snapshotID == "" {
+		respondError(w, http.StatusBadRequest, "Invalid snapshot ID")
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		snapshot, err := s.store.GetBaselineSnapshot(snapshotID)
+		if err != nil {
+			respondError(w, http.StatusNotFound, "Snapshot not found")
+			return
+		}
+		respondJSON(w, http.StatusOK, snapshot)
+
+	case len(parts) == 2 && parts[1] == "rollback" && r.Method == http.MethodPost:
+		clientID := r.URL.Query().Get("client")
+		if clientID == "" {
+			respondError(w, http.StatusBadRequest, "client query parameter is required")
+			return
+		}
+		if _, err := s.store.GetBaselineSnapshot(snapshotID); err != nil {
+			respondError(w, http.StatusNotFound, "Snapshot not found")
+			return
+		}
+
+		diff, err := s.store.RollbackClientBaseline(snapshotID, clientID, s.config.SnapshotRetain)
+		if err != nil {
+			log.Printf("Error rolling back baseline for %s: %v", clientID, err)
+			respondError(w, http.StatusInternalServerError, "Failed to roll back baseline")
+			return
+		}
+		log.Printf("⏪ Rolled back baseline for %s to snapshot %s (%d added, %d removed)",
+			clientID, snapshotID, len(diff.Added), len(diff.Removed))
+		respondJSON(w, http.StatusOK, diff)
+
+	case len(parts) == 2 && parts[1] != "rollback":
+		respondError(w, http.StatusBadRequest, "Invalid action. Must be 'rollback'")
+
+	default:
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// handleBaselineDiff handles GET /api/baselines/diff?client=ID&from=SNAPSHOT&to=SNAPSHOT|current
+func (s *Server) handleBaselineDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	clientID, from, to := query.Get("client"), query.Get("from"), query.Get("to")
+	if clientID == "" || from == "" {
+		respondError(w, http.StatusBadRequest, "client and from query parameters are required")
+		return
+	}
+	if to == "" {
+		to = "current"
+	}
+
+	fromBaseline, err := s.snapshotBaseline(from, clientID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	toBaseline, err := s.snapshotBaseline(to, clientID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	respondJSON(w, http.StatusOK, storage.DiffBaselines(clientID, from, to, fromBaseline, toBaseline))
+}
+
+// snapshotBaseline returns a client's baseline from a snapshot, or the live baseline for "current"
+func (s *Server) snapshotBaseline(snapshotID, clientID string) (*storage.Baseline, error) {
+	if snapshotID == "current" {
+		return s.store.GetClientBaseline(clientID)
+	}
+
+	snapshot, err := s.store.GetBaselineSnapshot(snapshotID)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot.ClientBaseline(clientID), nil
+}
+
+// incidentActions maps incident action URLs to the resulting status
+var incidentActions = map[string]string{
+	"investigate": storage.IncidentStatusInvestigating,
+	"resolve":     storage.IncidentStatusResolved,
+	"dismiss":     storage.IncidentStatusDismissed,
+	"reopen":      storage.IncidentStatusOpen,
+}
+
+// handleIncidents handles GET /api/incidents
+func (s *Server) handleIncidents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	incidents, err := s.store.GetAllIncidents(r.URL.Query().Get("status"))
+	if err != nil {
+		log.Printf("Error retrieving incidents: %v", err)
+		respondError(w, http.StatusInternalServerError, "Failed to retrieve incidents")
+		return
+	}
+
+	// Most recently active first
+	sort.Slice(incidents, func(i, j int) bool {
+		return incidents[i].LastSeenAt.After(incidents[j].LastSeenAt)
+	})
+
+	response := make([]IncidentResponse, 0, len(incidents))
+	for _, incident := range incidents {
+		response = append(response, IncidentResponse{Incident: incident})
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// handleIncident handles GET /api/incidents/{id} and POST /api/incidents/{id}/{action}
+func (s *Server) handleIncident(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/incidents/")
+	parts := strings.Split(path, "/")
+
+	incidentID, err := url.PathUnescape(parts[0])
+	if err != nil || incidentID == "" {
+		respondError(w, http.StatusBadRequest, "Invalid incident ID")
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodGet:
+		s.getIncident(w, incidentID)
+	case len(parts) == 2 && r.Method == http.MethodPost:
+		s.updateIncidentStatus(w, incidentID, parts[1])
+	case len(parts) > 2:
+		respondError(w, http.StatusBadRequest, "Invalid URL format. Expected: /api/incidents/{id} or /api/incidents/{id}/{action}")
+	default:
+		respondError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// getIncident responds with an incident and its anomalies
+func (s *Server) getIncident(w http.ResponseWriter, incidentID string) {
+	incident, err := s.store.GetIncidentByID(incidentID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "Incident not found")
+		return
+	}
+
+	response := IncidentResponse{Incident: *incident}
+	for _, anomalyID := range incident.AnomalyIDs {
+		anomaly, err := s.store.GetAnomalyByID(anomalyID)
+		if err != nil {
+			continue // Anomaly no longer stored
+		}
+		response.Anomalies = append(response.Anomalies, toAnomalyResponse(*anomaly))
+	}
+
+	respondJSON(w, http.StatusOK, response)
+}
+
+// updateIncidentStatus applies an incident workflow action
+func (s *Server) updateIncidentStatus(w http.ResponseWriter, incidentID, action string) {
+	status, ok := incidentActions[action]
+	if !ok {
+		respondError(w, http.StatusBadRequest, "Invalid action. Must be 'investigate', 'resolve', 'dismiss' or 'reopen'")
+		return
+	}
+
+	if s.incidents == nil {
+		respondError(w, http.StatusNotFound, "Incident correlation not enabled")
+		return
+	}
+
+	if _, err := s.store.GetIncidentByID(incidentID); err != nil {
+		respondError(w, http.StatusNotFound, "Incident not found")
+		return
+	}
+
+	if err := s.incidents.SetStatus(incidentID, status); err != nil {
+		log.Printf("Error updating incident %s: %v", incidentID, err)
+		respondError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	log.Printf("🧩 Incident %s: %s", incidentID, status)
+	respondJSON(w, http.StatusOK, SuccessResponse{
+		Success: true,
+		Message: fmt.Sprintf("Incident marked %s", status),
+	})
+}
+
 // handleSettings handles GET and PUT /api/settings
 func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
@@ -241,7 +928,7 @@ func (s *Server) handleGetSettings(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Add provider-specific settings
-	if s.config.LLMProvider == "gemini" {
+	if slices.Contains(s.config.LLMProviders, "gemini") {
 		response.GeminiModel = s.config.GeminiModel
 		response.HasGeminiAPIKey = s.config.GeminiAPIKey != ""
 	}
@@ -1,10 +1,17 @@
 package api
 
-import "time"
+import (
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/llm"
+	"github.com/eiladin/guardian-log/internal/rules"
+	"github.com/eiladin/guardian-log/internal/storage"
+)
 
 // AnomalyResponse represents an anomaly in API responses
 type AnomalyResponse struct {
 	ID              string    `json:"id"`
+	Type            string    `json:"type"` // llm, beaconing
 	Domain          string    `json:"domain"`
 	ClientID        string    `json:"client_id"`
 	ClientName      string    `json:"client_name"`
@@ -15,6 +22,17 @@ type AnomalyResponse struct {
 	SuggestedAction string    `json:"suggested_action"`
 	DetectedAt      time.Time `json:"detected_at"`
 	Status          string    `json:"status"` // pending, approved, blocked
+	OffHours        bool      `json:"off_hours"`
+
+	// Domain age from WHOIS, when known
+	DomainCreatedAt *time.Time `json:"domain_created_at,omitempty"`
+	DomainAgeDays   *int       `json:"domain_age_days,omitempty"`
+
+	Details map[string]string `json:"details,omitempty"`
+
+	// Every client that queried the domain, with first-seen times
+	AffectedClients []storage.AffectedClient `json:"affected_clients"`
+	ClientCount     int                      `json:"client_count"`
 }
 
 // StatsResponse represents system statistics
@@ -30,6 +48,10 @@ type StatsResponse struct {
 	LLMAnalysesTotal   int64 `json:"llm_analyses_total"`
 	LLMAnalysesSuccess int64 `json:"llm_analyses_success"`
 	LLMAnalysesFailed  int64 `json:"llm_analyses_failed"`
+	LLMCallsSaved      int64 `json:"llm_calls_saved"`
+	LLMCacheHits       int64 `json:"llm_cache_hits"`
+	LLMCacheMisses     int64 `json:"llm_cache_misses"`
+	FirstSeenEvents    int64 `json:"first_seen_events"`
 }
 
 // SettingsResponse represents current settings (with sensitive data redacted)
@@ -53,6 +75,51 @@ type UpdateSettingsRequest struct {
 	GeminiModel     *string `json:"gemini_model,omitempty"`
 }
 
+// RulesResponse lists the loaded detection rules
+type RulesResponse struct {
+	LoadedAt time.Time     `json:"loaded_at"`
+	Rules    []*rules.Rule `json:"rules"`
+}
+
+// IncidentResponse is an incident together with its anomalies
+type IncidentResponse struct {
+	storage.Incident
+	Anomalies []AnomalyResponse `json:"anomalies,omitempty"`
+}
+
+// BackfillResponse reports how many first-seen events were queued for analysis
+type BackfillResponse struct {
+	Queued int        `json:"queued"`
+	From   *time.Time `json:"from,omitempty"`
+	To     *time.Time `json:"to,omitempty"`
+}
+
+// LLMQueueResponse lists pending and dead-lettered LLM analyses
+type LLMQueueResponse struct {
+	Pending     []storage.QueuedAnalysis `json:"pending"`
+	DeadLetters []storage.QueuedAnalysis `json:"dead_letters"`
+}
+
+// LLMProvidersResponse lists the configured LLM providers in failover order
+type LLMProvidersResponse struct {
+	Providers []llm.ProviderStatus `json:"providers"`
+}
+
+// LLMTiersResponse reports tier agreement and the most recent escalated verdicts
+type LLMTiersResponse struct {
+	Agreement *storage.TierAgreement   `json:"agreement"`
+	Recent    []storage.TierComparison `json:"recent"`
+}
+
+// LLMUsageResponse reports LLM token usage and cost per day, provider and client
+type LLMUsageResponse struct {
+	From   string               `json:"from"`
+	To     string               `json:"to"`
+	Total  storage.DailyUsage   `json:"total"`
+	Days   []storage.DailyUsage `json:"days"`
+	Budget *llm.BudgetStatus    `json:"budget,omitempty"`
+}
+
 // ErrorResponse represents an API error
 type ErrorResponse struct {
 	Error string `json:"error"`
@@ -8,10 +8,13 @@ import (
 	"strings"
 	"time"
 
+	"github.com/eiladin/guardian-log/internal/analyzer"
 	"github.com/eiladin/guardian-log/internal/config"
 	"github.com/eiladin/guardian-log/internal/ingestor"
 	"github.com/eiladin/guardian-log/internal/llm"
+	"github.com/eiladin/guardian-log/internal/rules"
 	"github.com/eiladin/guardian-log/internal/storage"
+	"github.com/eiladin/guardian-log/internal/threatintel"
 )
 
 // Server represents the HTTP API server
@@ -21,7 +24,11 @@ type Server struct {
 	adguardClient *ingestor.AdGuardClient
 	llmAnalyzer   *llm.Analyzer
 	httpServer    *http.Server
-	webFS         fs.FS // Optional embedded frontend filesystem
+	webFS         fs.FS                        // Optional embedded frontend filesystem
+	ruleEngine    *rules.Engine                // Optional declarative rule engine
+	threatIntel   *threatintel.Service         // Optional threat-intel feed matching
+	incidents     *analyzer.IncidentCorrelator // Optional incident correlation
+	usageMeter    *llm.Meter                   // Optional LLM budget enforcement
 }
 
 // NewServer creates a new API server
@@ -41,6 +48,26 @@ func NewServer(
 	}
 }
 
+// SetRuleEngine sets the optional rule engine exposed under /api/rules
+func (s *Server) SetRuleEngine(engine *rules.Engine) {
+	s.ruleEngine = engine
+}
+
+// SetThreatIntel sets the optional threat-intel service exposed under /api/threatintel
+func (s *Server) SetThreatIntel(service *threatintel.Service) {
+	s.threatIntel = service
+}
+
+// SetIncidentCorrelator sets the optional incident correlator used for status changes
+func (s *Server) SetIncidentCorrelator(correlator *analyzer.IncidentCorrelator) {
+	s.incidents = correlator
+}
+
+// SetUsageMeter sets the optional LLM usage meter whose budget is reported under /api/llm/usage
+func (s *Server) SetUsageMeter(meter *llm.Meter) {
+	s.usageMeter = meter
+}
+
 // Start starts the HTTP server
 func (s *Server) Start(addr string) error {
 	mux := http.NewServeMux()
@@ -48,8 +75,26 @@ func (s *Server) Start(addr string) error {
 	// API routes (registered first to take precedence)
 	mux.HandleFunc("/api/anomalies", s.handleAnomalies)
 	mux.HandleFunc("/api/anomalies/", s.handleAnomalyAction)
+	mux.HandleFunc("/api/events", s.handleEvents)
+	mux.HandleFunc("/api/events/backfill", s.handleEventsBackfill)
+	mux.HandleFunc("/api/llm/queue", s.handleLLMQueue)
+	mux.HandleFunc("/api/llm/queue/retry", s.handleLLMQueueRetry)
+	mux.HandleFunc("/api/llm/providers", s.handleLLMProviders)
+	mux.HandleFunc("/api/llm/tiers", s.handleLLMTiers)
+	mux.HandleFunc("/api/llm/usage", s.handleLLMUsage)
 	mux.HandleFunc("/api/stats", s.handleStats)
+	mux.HandleFunc("/api/stats/clients", s.handleClientStats)
 	mux.HandleFunc("/api/settings", s.handleSettings)
+	mux.HandleFunc("/api/rules", s.handleRules)
+	mux.HandleFunc("/api/rules/reload", s.handleRulesReload)
+	mux.HandleFunc("/api/threatintel/feeds", s.handleThreatIntelFeeds)
+	mux.HandleFunc("/api/incidents", s.handleIncidents)
+	mux.HandleFunc("/api/baselines/export", s.handleBaselineExport)
+	mux.HandleFunc("/api/baselines/import", s.handleBaselineImport)
+	mux.HandleFunc("/api/baselines/snapshots", s.handleSnapshots)
+	mux.HandleFunc("/api/baselines/snapshots/", s.handleSnapshot)
+	mux.HandleFunc("/api/baselines/diff", s.handleBaselineDiff)
+	mux.HandleFunc("/api/incidents/", s.handleIncident)
 	mux.HandleFunc("/api/health", s.handleHealth)
 
 	// Serve static files from embedded dist folder if available
@@ -3,6 +3,8 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -21,14 +23,42 @@ type Config struct {
 	LogLevel     string
 
 	// LLM settings
-	LLMEnabled  bool
-	LLMProvider string // gemini, ollama, openai, anthropic
-	LLMTimeout  time.Duration
+	LLMEnabled   bool
+	LLMProvider  string   // Raw LLM_PROVIDER value
+	LLMProviders []string // Providers in failover order: gemini, ollama, openai, openai-compatible, anthropic
+	LLMTimeout   time.Duration
 
 	// LLM Batching settings
 	LLMBatchSize    int
 	LLMBatchTimeout time.Duration
-	LLMBatchDelay   time.Duration
+
+	// Per-provider rate limits (keyed by provider name)
+	LLMRateLimits map[string]RateLimits
+
+	// LLM queue settings
+	LLMMaxAttempts int // Failed attempts before an analysis is dead-lettered
+
+	// LLM failover settings (when LLM_PROVIDER lists several providers)
+	LLMFailoverCooldown  time.Duration // How long a failing provider is skipped
+	LLMFailoverMaxErrors int           // Consecutive errors before a provider is skipped
+
+	// Tiered analysis settings (first stage is LLM_PROVIDER)
+	LLMEscalationProviders     []string      // Escalation providers in failover order; empty disables tiers
+	LLMEscalateClassifications []string      // First-stage classifications that are escalated
+	LLMEscalateMinRiskScore    int           // First-stage risk score that is escalated (0 disables)
+	LLMTierRetention           time.Duration // 0 keeps tier comparisons forever
+
+	// Verdict cache settings
+	LLMCacheTTL         time.Duration // How long a domain's verdict is reused for other clients (0 disables)
+	LLMCacheByQueryType bool          // Cache verdicts per domain and query type
+
+	// Usage accounting settings
+	LLMPrices        []string // model=prompt/completion entries in USD per million tokens
+	LLMDailyBudget   float64  // USD per day before paid providers pause (0 = no limit)
+	LLMMonthlyBudget float64  // USD per month before paid providers pause (0 = no limit)
+
+	// Prompt template settings
+	LLMPromptDir string // Directory of prompt templates overriding the embedded defaults
 
 	// Gemini settings
 	GeminiAPIKey string
@@ -38,13 +68,95 @@ type Config struct {
 	OpenAIAPIKey string
 	OpenAIModel  string
 
+	// OpenAI-compatible server settings (llama.cpp, vLLM, LM Studio, ...)
+	OpenAICompatURL     string
+	OpenAICompatAPIKey  string
+	OpenAICompatModel   string
+	OpenAICompatHeaders []string // Name=Value entries sent with every request
+
 	// Anthropic settings
 	AnthropicAPIKey string
 	AnthropicModel  string
 
 	// Ollama settings
-	OllamaURL   string
-	OllamaModel string
+	OllamaURL       string
+	OllamaModel     string
+	OllamaNumCtx    int    // Context window in tokens
+	OllamaKeepAlive string // How long the model stays loaded between calls
+
+	// Beaconing detection settings
+	BeaconEnabled     bool
+	BeaconWindow      time.Duration
+	BeaconMinSamples  int
+	BeaconMaxJitter   float64
+	BeaconMinInterval time.Duration
+
+	// Volume spike detection settings
+	VolumeEnabled       bool
+	VolumeZThreshold    float64
+	VolumeMinQueries    int
+	VolumeWarmupMinutes int
+
+	// Time-of-day profile settings
+	OffHoursMinQueries int
+	OffHoursMinShare   float64
+
+	// Newly registered domain settings
+	NRDEnabled    bool
+	NRDMaxAgeDays int
+
+	// Typosquatting detection settings
+	TyposquatEnabled      bool
+	TyposquatBrands       []string
+	TyposquatBaselineTopN int
+	TyposquatTLDSwap      bool
+
+	// Rule engine settings
+	RulesDir string
+
+	// Top-sites popularity list settings
+	TopSitesFile    string
+	TopSitesMaxRank int
+
+	// Incident correlation settings
+	IncidentEnabled      bool
+	IncidentWindow       time.Duration
+	IncidentMinAnomalies int
+
+	// First-seen event settings
+	EventRetention time.Duration // 0 keeps events forever
+
+	// Baseline snapshot settings
+	SnapshotInterval time.Duration // 0 disables scheduled snapshots
+	SnapshotRetain   int
+
+	// Threat-intel feed settings
+	ThreatIntelFeeds   []string // name=url or name=path entries
+	ThreatIntelRefresh time.Duration
+}
+
+// RateLimits are a provider's requests-per-minute and tokens-per-minute budgets (0 = unlimited)
+type RateLimits struct {
+	RPM int
+	TPM int
+}
+
+// providerEnvPrefix maps LLM provider names to their environment variable prefix
+var providerEnvPrefix = map[string]string{
+	"gemini":            "GEMINI",
+	"openai":            "OPENAI",
+	"openai-compatible": "OPENAI_COMPAT",
+	"anthropic":         "ANTHROPIC",
+	"ollama":            "OLLAMA",
+}
+
+// defaultRateLimits are the entry-level limits of each provider; local servers are unlimited
+var defaultRateLimits = map[string]RateLimits{
+	"gemini":            {RPM: 15, TPM: 250000},
+	"openai":            {RPM: 500, TPM: 200000},
+	"openai-compatible": {},
+	"anthropic":         {RPM: 50, TPM: 40000},
+	"ollama":            {},
 }
 
 // Load reads configuration from environment variables
@@ -57,6 +169,7 @@ func Load() (*Config, error) {
 		AdGuardUser:     getEnv("AGH_USER", ""),
 		AdGuardPassword: getEnv("AGH_PASS", ""),
 		DBPath:          getEnv("DB_PATH", "./data/guardian.db"),
+		RulesDir:        getEnv("RULES_DIR", "./rules"),
 		LogLevel:        getEnv("LOG_LEVEL", "info"),
 
 		// LLM settings
@@ -71,13 +184,21 @@ func Load() (*Config, error) {
 		OpenAIAPIKey: getEnv("OPENAI_API_KEY", ""),
 		OpenAIModel:  getEnv("OPENAI_MODEL", "gpt-4o-mini"),
 
+		// OpenAI-compatible server settings
+		OpenAICompatURL:     getEnv("OPENAI_COMPAT_URL", ""),
+		OpenAICompatAPIKey:  getEnv("OPENAI_COMPAT_API_KEY", ""),
+		OpenAICompatModel:   getEnv("OPENAI_COMPAT_MODEL", ""),
+		OpenAICompatHeaders: getListEnv("OPENAI_COMPAT_HEADERS"),
+
 		// Anthropic settings
 		AnthropicAPIKey: getEnv("ANTHROPIC_API_KEY", ""),
 		AnthropicModel:  getEnv("ANTHROPIC_MODEL", "claude-3-5-sonnet-20241022"),
 
 		// Ollama settings
-		OllamaURL:   getEnv("OLLAMA_URL", "http://localhost:11434"),
-		OllamaModel: getEnv("OLLAMA_MODEL", "llama3"),
+		OllamaURL:       getEnv("OLLAMA_URL", "http://localhost:11434"),
+		OllamaModel:     getEnv("OLLAMA_MODEL", "llama3"),
+		OllamaNumCtx:    getIntEnv("OLLAMA_NUM_CTX", 8192),
+		OllamaKeepAlive: getEnv("OLLAMA_KEEP_ALIVE", "5m"),
 	}
 
 	// Parse poll interval
@@ -107,12 +228,113 @@ func Load() (*Config, error) {
 	}
 	cfg.LLMBatchTimeout = batchTimeout
 
-	batchDelayStr := getEnv("LLM_BATCH_DELAY", "90s")
-	batchDelay, err := time.ParseDuration(batchDelayStr)
-	if err != nil {
-		return nil, fmt.Errorf("invalid LLM_BATCH_DELAY: %w", err)
+	// Parse per-provider rate limits
+	cfg.LLMRateLimits = make(map[string]RateLimits)
+	for provider, defaults := range defaultRateLimits {
+		prefix := providerEnvPrefix[provider]
+		cfg.LLMRateLimits[provider] = RateLimits{
+			RPM: getIntEnv(prefix+"_RPM", defaults.RPM),
+			TPM: getIntEnv(prefix+"_TPM", defaults.TPM),
+		}
+	}
+
+	// Parse LLM queue settings
+	cfg.LLMMaxAttempts = getIntEnv("LLM_MAX_ATTEMPTS", 5)
+
+	// Parse LLM provider chain settings
+	cfg.LLMProviders = getListEnv("LLM_PROVIDER")
+	if len(cfg.LLMProviders) == 0 {
+		cfg.LLMProviders = []string{"gemini"}
+	}
+	if cfg.LLMFailoverCooldown, err = getDurationEnv("LLM_FAILOVER_COOLDOWN", "10m"); err != nil {
+		return nil, err
+	}
+	cfg.LLMFailoverMaxErrors = getIntEnv("LLM_FAILOVER_MAX_ERRORS", 3)
+
+	// Parse tiered analysis settings
+	cfg.LLMEscalationProviders = getListEnv("LLM_ESCALATION_PROVIDER")
+	cfg.LLMEscalateClassifications = getListEnv("LLM_ESCALATE_CLASSIFICATIONS")
+	if os.Getenv("LLM_ESCALATE_CLASSIFICATIONS") == "" {
+		cfg.LLMEscalateClassifications = []string{"Suspicious", "Malicious"}
+	}
+	cfg.LLMEscalateMinRiskScore = getIntEnv("LLM_ESCALATE_MIN_RISK", 0)
+	if cfg.LLMTierRetention, err = getDurationEnv("LLM_TIER_RETENTION", "720h"); err != nil {
+		return nil, err
+	}
+
+	// Parse verdict cache settings
+	if cfg.LLMCacheTTL, err = getDurationEnv("LLM_CACHE_TTL", "168h"); err != nil {
+		return nil, err
+	}
+	cfg.LLMCacheByQueryType = getBoolEnv("LLM_CACHE_BY_QUERY_TYPE", false)
+
+	// Parse usage accounting settings
+	cfg.LLMPrices = getListEnv("LLM_PRICES")
+	cfg.LLMDailyBudget = getFloatEnv("LLM_DAILY_BUDGET", 0)
+	cfg.LLMMonthlyBudget = getFloatEnv("LLM_MONTHLY_BUDGET", 0)
+
+	// Parse prompt template settings
+	cfg.LLMPromptDir = getEnv("LLM_PROMPT_DIR", "")
+
+	// Parse beaconing detection settings
+	cfg.BeaconEnabled = getBoolEnv("BEACON_ENABLE", false)
+	cfg.BeaconMinSamples = getIntEnv("BEACON_MIN_SAMPLES", 6)
+	cfg.BeaconMaxJitter = getFloatEnv("BEACON_MAX_JITTER", 0.1)
+
+	if cfg.BeaconWindow, err = getDurationEnv("BEACON_WINDOW", "1h"); err != nil {
+		return nil, err
+	}
+	if cfg.BeaconMinInterval, err = getDurationEnv("BEACON_MIN_INTERVAL", "30s"); err != nil {
+		return nil, err
+	}
+
+	// Parse volume spike detection settings
+	cfg.VolumeEnabled = getBoolEnv("VOLUME_ENABLE", true)
+	cfg.VolumeZThreshold = getFloatEnv("VOLUME_Z_THRESHOLD", 3.0)
+	cfg.VolumeMinQueries = getIntEnv("VOLUME_MIN_QUERIES", 20)
+	cfg.VolumeWarmupMinutes = getIntEnv("VOLUME_WARMUP_MINUTES", 60)
+
+	// Parse time-of-day profile settings
+	cfg.OffHoursMinQueries = getIntEnv("OFFHOURS_MIN_QUERIES", 1000)
+	cfg.OffHoursMinShare = getFloatEnv("OFFHOURS_MIN_SHARE", 0.2)
+
+	// Parse newly registered domain settings
+	cfg.NRDEnabled = getBoolEnv("NRD_ENABLE", true)
+	cfg.NRDMaxAgeDays = getIntEnv("NRD_MAX_AGE_DAYS", 30)
+
+	// Parse typosquatting detection settings
+	cfg.TyposquatEnabled = getBoolEnv("TYPOSQUAT_ENABLE", true)
+	cfg.TyposquatBrands = getListEnv("TYPOSQUAT_BRANDS")
+	cfg.TyposquatBaselineTopN = getIntEnv("TYPOSQUAT_BASELINE_TOP", 50)
+	cfg.TyposquatTLDSwap = getBoolEnv("TYPOSQUAT_TLD_SWAP", false)
+
+	// Parse top-sites popularity list settings
+	cfg.TopSitesFile = getEnv("TOPSITES_FILE", "")
+	cfg.TopSitesMaxRank = getIntEnv("TOPSITES_MAX_RANK", 10000)
+
+	// Parse incident correlation settings
+	cfg.IncidentEnabled = getBoolEnv("INCIDENT_ENABLE", true)
+	cfg.IncidentMinAnomalies = getIntEnv("INCIDENT_MIN_ANOMALIES", 2)
+	if cfg.IncidentWindow, err = getDurationEnv("INCIDENT_WINDOW", "10m"); err != nil {
+		return nil, err
+	}
+
+	// Parse first-seen event settings
+	if cfg.EventRetention, err = getDurationEnv("EVENT_RETENTION", "720h"); err != nil {
+		return nil, err
+	}
+
+	// Parse baseline snapshot settings
+	cfg.SnapshotRetain = getIntEnv("SNAPSHOT_RETAIN", 30)
+	if cfg.SnapshotInterval, err = getDurationEnv("SNAPSHOT_INTERVAL", "24h"); err != nil {
+		return nil, err
+	}
+
+	// Parse threat-intel feed settings
+	cfg.ThreatIntelFeeds = getListEnv("THREATINTEL_FEEDS")
+	if cfg.ThreatIntelRefresh, err = getDurationEnv("THREATINTEL_REFRESH", "6h"); err != nil {
+		return nil, err
 	}
-	cfg.LLMBatchDelay = batchDelay
 
 	// Validate required fields
 	if err := cfg.Validate(); err != nil {
@@ -139,31 +361,65 @@ func (c *Config) Validate() error {
 
 	// Validate LLM configuration if enabled
 	if c.LLMEnabled {
-		switch c.LLMProvider {
-		case "gemini":
-			if c.GeminiAPIKey == "" {
-				return fmt.Errorf("GEMINI_API_KEY is required when LLM_PROVIDER=gemini")
+		seen := make(map[string]bool)
+		for _, provider := range c.LLMProviders {
+			if seen[provider] {
+				return fmt.Errorf("duplicate LLM_PROVIDER entry: %s", provider)
 			}
-		case "openai":
-			if c.OpenAIAPIKey == "" {
-				return fmt.Errorf("OPENAI_API_KEY is required when LLM_PROVIDER=openai")
+			seen[provider] = true
+			if err := c.validateProvider(provider); err != nil {
+				return err
 			}
-		case "anthropic":
-			if c.AnthropicAPIKey == "" {
-				return fmt.Errorf("ANTHROPIC_API_KEY is required when LLM_PROVIDER=anthropic")
+		}
+
+		for _, provider := range c.LLMEscalationProviders {
+			if err := c.validateProvider(provider); err != nil {
+				return fmt.Errorf("LLM_ESCALATION_PROVIDER: %w", err)
 			}
-		case "ollama":
-			if c.OllamaURL == "" {
-				return fmt.Errorf("OLLAMA_URL is required when LLM_PROVIDER=ollama")
+		}
+		for _, classification := range c.LLMEscalateClassifications {
+			if classification != "Safe" && classification != "Suspicious" && classification != "Malicious" {
+				return fmt.Errorf("invalid LLM_ESCALATE_CLASSIFICATIONS entry: %s (must be Safe, Suspicious or Malicious)", classification)
 			}
-		default:
-			return fmt.Errorf("invalid LLM_PROVIDER: %s (must be gemini, openai, anthropic, or ollama)", c.LLMProvider)
 		}
 	}
 
 	return nil
 }
 
+// validateProvider checks that the settings a single LLM provider needs are present
+func (c *Config) validateProvider(provider string) error {
+	switch provider {
+	case "gemini":
+		if c.GeminiAPIKey == "" {
+			return fmt.Errorf("GEMINI_API_KEY is required when LLM_PROVIDER=gemini")
+		}
+	case "openai":
+		if c.OpenAIAPIKey == "" {
+			return fmt.Errorf("OPENAI_API_KEY is required when LLM_PROVIDER=openai")
+		}
+	case "openai-compatible":
+		if c.OpenAICompatURL == "" {
+			return fmt.Errorf("OPENAI_COMPAT_URL is required when LLM_PROVIDER=openai-compatible")
+		}
+		if c.OpenAICompatModel == "" {
+			return fmt.Errorf("OPENAI_COMPAT_MODEL is required when LLM_PROVIDER=openai-compatible")
+		}
+	case "anthropic":
+		if c.AnthropicAPIKey == "" {
+			return fmt.Errorf("ANTHROPIC_API_KEY is required when LLM_PROVIDER=anthropic")
+		}
+	case "ollama":
+		if c.OllamaURL == "" {
+			return fmt.Errorf("OLLAMA_URL is required when LLM_PROVIDER=ollama")
+		}
+	default:
+		return fmt.Errorf("invalid LLM_PROVIDER: %s (must be gemini, openai, openai-compatible, anthropic, or ollama)", provider)
+	}
+
+	return nil
+}
+
 // getEnv retrieves an environment variable or returns a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -193,3 +449,36 @@ func getIntEnv(key string, defaultValue int) int {
 	}
 	return result
 }
+
+// getFloatEnv retrieves a float environment variable or returns a default value
+func getFloatEnv(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	result, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return defaultValue
+	}
+	return result
+}
+
+// getDurationEnv parses a duration environment variable, falling back to a default value
+func getDurationEnv(key, defaultValue string) (time.Duration, error) {
+	duration, err := time.ParseDuration(getEnv(key, defaultValue))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return duration, nil
+}
+
+// getListEnv retrieves a comma-separated environment variable as a list, skipping empty items
+func getListEnv(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,75 @@
+package enrichment
+
+import (
+	"strings"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+// whoisDateLayouts are the date formats commonly returned by WHOIS servers
+var whoisDateLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05Z",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04:05.0Z",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05 MST",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"2006.01.02 15:04:05",
+	"2006.01.02",
+	"2006/01/02 15:04:05",
+	"2006/01/02",
+	"02-Jan-2006 15:04:05 MST",
+	"02-Jan-2006",
+	"02-January-2006",
+	"02.01.2006 15:04:05",
+	"02.01.2006",
+	// Slashed day-first dates ("02/01/2006") are left out: registries also use
+	// MM/DD, and guessing wrong silently skews the domain's age
+	"January 2 2006",
+	"January 02 2006",
+	"Jan 2 2006",
+	"Mon Jan 2 15:04:05 MST 2006",
+	"Mon Jan 02 15:04:05 MST 2006",
+	"20060102",
+}
+
+// ParseWHOISDate parses a free-form WHOIS date string
+// Returns false if none of the known layouts match
+func ParseWHOISDate(value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	// Drop trailing annotations such as "2020-01-01 (YYYY-MM-DD)"
+	if idx := strings.Index(value, " ("); idx > 0 {
+		value = value[:idx]
+	}
+	value = strings.TrimSuffix(value, " UTC")
+	value = strings.ReplaceAll(value, ",", "")
+
+	for _, layout := range whoisDateLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed.UTC(), true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// parseDates fills the parsed date fields from the raw WHOIS strings when they are missing
+func parseDates(data *storage.WHOISData) {
+	if data.CreatedAt.IsZero() {
+		data.CreatedAt, _ = ParseWHOISDate(data.CreatedDate)
+	}
+	if data.UpdatedAt.IsZero() {
+		data.UpdatedAt, _ = ParseWHOISDate(data.UpdatedDate)
+	}
+	if data.ExpiresAt.IsZero() {
+		data.ExpiresAt, _ = ParseWHOISDate(data.ExpiryDate)
+	}
+}
@@ -0,0 +1,20 @@
+package enrichment
+
+import (
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// RegistrableDomain returns the registrable part of a domain (eTLD+1),
+// e.g. "cdn.assets.example.co.uk" -> "example.co.uk"
+// Falls back to the normalized domain if it has no registrable part
+func RegistrableDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return domain
+	}
+	return registrable
+}
@@ -0,0 +1,82 @@
+package enrichment
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PopularityList holds the top-ranked sites from a Tranco or Umbrella style
+// "rank,domain" CSV, keyed by registrable domain
+type PopularityList struct {
+	ranks   map[string]int
+	maxRank int
+}
+
+// LoadPopularityList reads a top-sites CSV, keeping only domains ranked at or above maxRank
+func LoadPopularityList(path string, maxRank int) (*PopularityList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open top-sites list: %w", err)
+	}
+	defer file.Close()
+
+	list := &PopularityList{
+		ranks:   make(map[string]int),
+		maxRank: maxRank,
+	}
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read top-sites list: %w", err)
+		}
+		if len(record) < 2 {
+			continue
+		}
+
+		rank, err := strconv.Atoi(strings.TrimSpace(record[0]))
+		if err != nil {
+			if line == 1 {
+				continue // Header row
+			}
+			return nil, fmt.Errorf("invalid rank on line %d: %q", line, record[0])
+		}
+		if maxRank > 0 && rank > maxRank {
+			continue
+		}
+
+		// Umbrella lists hostnames; rank each registrable domain by its best entry
+		domain := RegistrableDomain(strings.TrimSpace(record[1]))
+		if domain == "" {
+			continue
+		}
+		if existing, ok := list.ranks[domain]; !ok || rank < existing {
+			list.ranks[domain] = rank
+		}
+	}
+
+	return list, nil
+}
+
+// Rank returns the rank of the domain's registrable domain if it is in the list
+func (l *PopularityList) Rank(domain string) (int, bool) {
+	rank, ok := l.ranks[RegistrableDomain(domain)]
+	return rank, ok
+}
+
+// Len returns the number of registrable domains in the list
+func (l *PopularityList) Len() int {
+	return len(l.ranks)
+}
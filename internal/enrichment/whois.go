@@ -4,7 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"strings"
+	"sync"
 	"time"
 
 	"github.com/likexian/whois"
@@ -23,8 +23,11 @@ const (
 
 // WHOISService handles domain enrichment via WHOIS lookups
 type WHOISService struct {
-	store       *storage.BoltStore
-	lastLookup  time.Time
+	store *storage.BoltStore
+
+	mu          sync.Mutex               // Guards the fields below; never held during a lookup
+	lastLookup  time.Time                // Time slot reserved by the latest lookup
+	inflight    map[string]chan struct{} // Closed when the lookup of a domain finishes
 	lookupCount int
 	cacheHits   int
 	cacheMisses int
@@ -33,38 +36,65 @@ type WHOISService struct {
 // NewWHOISService creates a new WHOIS enrichment service
 func NewWHOISService(store *storage.BoltStore) *WHOISService {
 	return &WHOISService{
-		store: store,
+		store:    store,
+		inflight: make(map[string]chan struct{}),
 	}
 }
 
-// Lookup performs a WHOIS lookup for the given domain, using cache when available
+// Lookup performs a WHOIS lookup for the registrable part of the given domain,
+// using cache when available. Subdomains of one registrable domain share a
+// cache entry, and concurrent lookups of the same domain share one request.
 func (s *WHOISService) Lookup(domain string) (*storage.WHOISData, error) {
-	// Normalize domain (remove any trailing dots, convert to lowercase)
-	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	// WHOIS records belong to the registrable domain (also normalizes case and trailing dot)
+	domain = RegistrableDomain(domain)
+
+	for {
+		s.mu.Lock()
+
+		// Check cache first
+		if cached := s.getFromCache(domain); cached != nil {
+			s.cacheHits++
+			s.mu.Unlock()
+			log.Printf("[WHOIS] Cache hit for %s (age: %s)", domain, time.Since(cached.LookedUpAt).Round(time.Minute))
+			return cached, nil
+		}
 
-	// Check cache first
-	if cached := s.getFromCache(domain); cached != nil {
-		s.cacheHits++
-		log.Printf("[WHOIS] Cache hit for %s (age: %s)", domain, time.Since(cached.LookedUpAt).Round(time.Minute))
-		return cached, nil
+		// Wait for a lookup of the same domain that is already running
+		if done, ok := s.inflight[domain]; ok {
+			s.mu.Unlock()
+			<-done
+			continue
+		}
+		break
 	}
 
 	s.cacheMisses++
+	s.lookupCount++
+	done := make(chan struct{})
+	s.inflight[domain] = done
 
-	// Rate limiting: ensure we don't make requests too quickly
-	if !s.lastLookup.IsZero() {
-		elapsed := time.Since(s.lastLookup)
-		if elapsed < RateLimitDelay {
-			sleepTime := RateLimitDelay - elapsed
-			log.Printf("[WHOIS] Rate limiting: sleeping for %s", sleepTime)
-			time.Sleep(sleepTime)
-		}
+	// Rate limiting: reserve the next free slot, then wait for it without the lock
+	slot := time.Now()
+	if next := s.lastLookup.Add(RateLimitDelay); next.After(slot) {
+		slot = next
+	}
+	s.lastLookup = slot
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.inflight, domain)
+		s.mu.Unlock()
+		close(done)
+	}()
+
+	if wait := time.Until(slot); wait > 0 {
+		log.Printf("[WHOIS] Rate limiting: sleeping for %s", wait.Round(time.Millisecond))
+		time.Sleep(wait)
 	}
 
 	// Perform WHOIS lookup
 	log.Printf("[WHOIS] Looking up domain: %s", domain)
-	s.lastLookup = time.Now()
-	s.lookupCount++
 
 	rawWhois, err := whois.Whois(domain)
 	if err != nil {
@@ -111,6 +141,16 @@ func (s *WHOISService) Lookup(domain string) (*storage.WHOISData, error) {
 	if parsed.Domain.ExpirationDate != "" {
 		data.ExpiryDate = parsed.Domain.ExpirationDate
 	}
+	if parsed.Domain.CreatedDateInTime != nil {
+		data.CreatedAt = parsed.Domain.CreatedDateInTime.UTC()
+	}
+	if parsed.Domain.UpdatedDateInTime != nil {
+		data.UpdatedAt = parsed.Domain.UpdatedDateInTime.UTC()
+	}
+	if parsed.Domain.ExpirationDateInTime != nil {
+		data.ExpiresAt = parsed.Domain.ExpirationDateInTime.UTC()
+	}
+	parseDates(data)
 
 	// Extract name servers
 	data.NameServers = parsed.Domain.NameServers
@@ -149,6 +189,9 @@ func (s *WHOISService) getFromCache(domain string) *storage.WHOISData {
 		return nil
 	}
 
+	// Entries cached before dates were parsed only carry the raw strings
+	parseDates(&cached)
+
 	return &cached
 }
 
@@ -159,6 +202,9 @@ func (s *WHOISService) saveToCache(data *storage.WHOISData) error {
 
 // GetStats returns statistics about WHOIS lookups
 func (s *WHOISService) GetStats() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	cacheHitRate := 0.0
 	totalRequests := s.cacheHits + s.cacheMisses
 	if totalRequests > 0 {
@@ -162,14 +162,23 @@ func (c *AdGuardClient) convertToQuery(entry QueryLogEntry) (storage.DNSQuery, e
 		domain = entry.Question.UnicodeName
 	}
 
+	// Keep the decoded form of internationalized domains for homograph checks
+	unicodeDomain := ""
+	if entry.Question.UnicodeName != "" && entry.Question.UnicodeName != domain {
+		unicodeDomain = entry.Question.UnicodeName
+	}
+
 	return storage.DNSQuery{
-		ClientID:   clientID,
-		ClientName: clientName,
-		Domain:     domain,
-		Timestamp:  timestamp,
-		QueryType:  entry.Question.Type,
-		Answer:     answerValue,
-		Reason:     entry.Reason,
+		ClientID:      clientID,
+		ClientName:    clientName,
+		Domain:        domain,
+		UnicodeDomain: unicodeDomain,
+		Timestamp:     timestamp,
+		QueryType:     entry.Question.Type,
+		Answer:        answerValue,
+		Reason:        entry.Reason,
+		Response:      entry.Status,
+		Upstream:      entry.Upstream,
 	}, nil
 }
 
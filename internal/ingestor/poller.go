@@ -3,14 +3,20 @@ package ingestor
 import (
 	"context"
 	"log"
+	"sort"
 	"time"
 
 	"github.com/eiladin/guardian-log/internal/analyzer"
+	"github.com/eiladin/guardian-log/internal/enrichment"
+	"github.com/eiladin/guardian-log/internal/rules"
+	"github.com/eiladin/guardian-log/internal/storage"
+	"github.com/eiladin/guardian-log/internal/threatintel"
 )
 
 // LLMAnalyzer defines the interface for LLM analysis
 type LLMAnalyzer interface {
 	AnalyzeAsync(query interface{})
+	RecordPopularDomain(query storage.DNSQuery, rank int) error
 	GetStats() map[string]interface{}
 	Stop()
 }
@@ -19,7 +25,14 @@ type LLMAnalyzer interface {
 type Poller struct {
 	client      *AdGuardClient
 	analyzer    *analyzer.BaselineAnalyzer
-	llmAnalyzer LLMAnalyzer // Optional LLM analyzer
+	llmAnalyzer LLMAnalyzer                 // Optional LLM analyzer
+	beacons     *analyzer.BeaconDetector    // Optional beaconing detector
+	volume      *analyzer.VolumeDetector    // Optional volume spike detector
+	domainAge   *analyzer.DomainAgeChecker  // Optional newly-registered-domain check
+	typosquat   *analyzer.TyposquatDetector // Optional typosquatting detector
+	rules       *rules.Engine               // Optional declarative rule engine
+	threatIntel *threatintel.Service        // Optional threat-intel feed matching
+	popularity  *enrichment.PopularityList  // Optional top-sites list that skips LLM analysis
 	interval    time.Duration
 }
 
@@ -37,6 +50,42 @@ func (p *Poller) SetLLMAnalyzer(llmAnalyzer LLMAnalyzer) {
 	p.llmAnalyzer = llmAnalyzer
 }
 
+// SetBeaconDetector sets the optional beaconing detector
+func (p *Poller) SetBeaconDetector(detector *analyzer.BeaconDetector) {
+	p.beacons = detector
+}
+
+// SetVolumeDetector sets the optional volume spike detector
+func (p *Poller) SetVolumeDetector(detector *analyzer.VolumeDetector) {
+	p.volume = detector
+}
+
+// SetDomainAgeChecker sets the optional newly-registered-domain check
+func (p *Poller) SetDomainAgeChecker(checker *analyzer.DomainAgeChecker) {
+	p.domainAge = checker
+}
+
+// SetTyposquatDetector sets the optional typosquatting detector
+func (p *Poller) SetTyposquatDetector(detector *analyzer.TyposquatDetector) {
+	p.typosquat = detector
+}
+
+// SetRuleEngine sets the optional declarative rule engine
+func (p *Poller) SetRuleEngine(engine *rules.Engine) {
+	p.rules = engine
+}
+
+// SetThreatIntel sets the optional threat-intel feed matching
+func (p *Poller) SetThreatIntel(service *threatintel.Service) {
+	p.threatIntel = service
+}
+
+// SetPopularityList sets the optional top-sites list; first-seen domains on it
+// are recorded as low-risk instead of being sent to the LLM
+func (p *Poller) SetPopularityList(list *enrichment.PopularityList) {
+	p.popularity = list
+}
+
 // Start begins the polling loop
 func (p *Poller) Start(ctx context.Context) error {
 	log.Printf("Starting poller with interval: %s", p.interval)
@@ -84,6 +133,11 @@ func (p *Poller) poll(ctx context.Context) error {
 
 	log.Printf("Fetched %d queries from AdGuard Home", len(queries))
 
+	// AdGuard Home returns the newest queries first; process them in the order they happened
+	sort.SliceStable(queries, func(i, j int) bool {
+		return queries[i].Timestamp.Before(queries[j].Timestamp)
+	})
+
 	// Process each query
 	anomalyCount := 0
 	processedCount := 0
@@ -98,19 +152,98 @@ func (p *Poller) poll(ctx context.Context) error {
 
 		processedCount++
 
-		// Process the query
-		isAnomaly, err := p.analyzer.ProcessQuery(query)
+		// Skip queries that were already processed in an earlier poll
+		isNew, err := p.analyzer.MarkQueryNew(query)
+		if err != nil {
+			log.Printf("Error processing query: %v", err)
+			continue
+		}
+		if !isNew {
+			continue
+		}
+
+		// Compare the query to the client's time-of-day profile and record it
+		offHours, err := p.analyzer.TrackActivity(query)
+		if err != nil {
+			log.Printf("Error tracking client activity: %v", err)
+		}
+		query.OffHours = offHours
+
+		// Match every query against the threat-intel feeds
+		if p.threatIntel != nil {
+			if hit := p.threatIntel.Check(query); hit != nil {
+				if err := p.analyzer.RecordAnomaly(hit); err != nil {
+					log.Printf("Error recording threat-intel anomaly: %v", err)
+				}
+			}
+		}
+
+		// Evaluate declarative rules against every query
+		if p.rules != nil {
+			for _, result := range p.rules.Evaluate(query) {
+				p.applyRule(result)
+			}
+		}
+
+		// Track query timing for beaconing, even for domains already in the baseline
+		if p.beacons != nil {
+			if beacon := p.beacons.Observe(query); beacon != nil {
+				if err := p.analyzer.RecordAnomaly(beacon); err != nil {
+					log.Printf("Error recording beaconing anomaly: %v", err)
+				}
+			}
+		}
+
+		// Track per-client query volume, NXDOMAIN and blocked ratios
+		if p.volume != nil {
+			for _, spike := range p.volume.Observe(query) {
+				if err := p.analyzer.RecordAnomaly(spike); err != nil {
+					log.Printf("Error recording volume anomaly: %v", err)
+				}
+			}
+		}
+
+		// Check whether the domain is new for this client
+		isAnomaly, err := p.analyzer.IsFirstSeen(query)
 		if err != nil {
 			log.Printf("Error processing query: %v", err)
 			continue
 		}
 
-		// If it's an anomaly, log it and add to baseline
+		// If it's an anomaly, record the first-seen event and add to baseline
 		if isAnomaly {
-			p.analyzer.LogAnomaly(query)
+			if err := p.analyzer.RecordFirstSeen(query); err != nil {
+				log.Printf("Error recording first-seen event: %v", err)
+			}
 
-			// If LLM analysis is enabled, queue for analysis
-			if p.llmAnalyzer != nil {
+			// Compare against brand and baseline domains for typosquatting
+			if p.typosquat != nil {
+				squat, err := p.typosquat.Check(query)
+				if err != nil {
+					log.Printf("Error checking for typosquatting: %v", err)
+				} else if squat != nil {
+					if err := p.analyzer.RecordAnomaly(squat); err != nil {
+						log.Printf("Error recording typosquat anomaly: %v", err)
+					}
+				}
+			}
+
+			// Flag newly registered domains regardless of LLM availability
+			if p.domainAge != nil {
+				p.domainAge.CheckAsync(query)
+			}
+
+			// If LLM analysis is enabled, queue for analysis unless the site is top-ranked
+			rank, popular := 0, false
+			if p.popularity != nil {
+				rank, popular = p.popularity.Rank(query.Domain)
+			}
+
+			if p.llmAnalyzer != nil && popular {
+				if err := p.llmAnalyzer.RecordPopularDomain(query, rank); err != nil {
+					log.Printf("Error recording popular domain: %v", err)
+				}
+			} else if p.llmAnalyzer != nil {
 				log.Printf("🤖 [LLM] Queuing domain for analysis: %s", query.Domain)
 				p.llmAnalyzer.AnalyzeAsync(query)
 			} else {
@@ -126,6 +259,21 @@ func (p *Poller) poll(ctx context.Context) error {
 		}
 	}
 
+	// Persist updated time-of-day profiles
+	if err := p.analyzer.FlushActivity(); err != nil {
+		log.Printf("Error saving activity profiles: %v", err)
+	}
+
+	// Forget timing histories for pairs that went quiet
+	if p.beacons != nil {
+		p.beacons.Prune(time.Now())
+	}
+
+	// Forget rule windows and cooldowns that have expired
+	if p.rules != nil {
+		p.rules.Prune(time.Now())
+	}
+
 	// Log summary if there were anomalies or skipped queries
 	if anomalyCount > 0 {
 		// Get updated baseline stats
@@ -141,6 +289,31 @@ func (p *Poller) poll(ctx context.Context) error {
 	return nil
 }
 
+// applyRule performs the action of a rule that fired
+func (p *Poller) applyRule(result rules.Result) {
+	rule := result.Rule
+
+	if rule.Action == rules.ActionLog {
+		log.Printf("📜 [Rule] %s matched %s (client: %s)", rule.Name, result.Query.Domain, result.Query.ClientID)
+		return
+	}
+
+	anomaly := result.Anomaly()
+
+	if rule.Action == rules.ActionBlock {
+		if err := p.client.BlockDomain(anomaly.Domain); err != nil {
+			log.Printf("Error blocking %s for rule %s: %v", anomaly.Domain, rule.Name, err)
+		} else {
+			anomaly.Status = "blocked"
+			log.Printf("🚫 [Rule] %s blocked %s in AdGuard Home", rule.Name, anomaly.Domain)
+		}
+	}
+
+	if err := p.analyzer.RecordAnomaly(anomaly); err != nil {
+		log.Printf("Error recording rule anomaly: %v", err)
+	}
+}
+
 // GetStats returns current baseline statistics
 func (p *Poller) GetStats() (map[string]interface{}, error) {
 	return p.analyzer.GetBaselineStats()
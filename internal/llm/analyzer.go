@@ -2,37 +2,56 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/eiladin/guardian-log/internal/enrichment"
 	"github.com/eiladin/guardian-log/internal/storage"
 )
 
+const (
+	// rateLimitBackoff is how long rate-limited analyses wait before retrying
+	rateLimitBackoff = 30 * time.Second
+
+	// maxRetryBackoff caps the exponential backoff between failed attempts
+	maxRetryBackoff = time.Hour
+
+	// pruneInterval is how often expired cached verdicts and tier comparisons are removed
+	pruneInterval = time.Hour
+)
+
 // Analyzer orchestrates LLM analysis of DNS queries
 type Analyzer struct {
 	provider     Provider
 	whoisService *enrichment.WHOISService
 	store        *storage.BoltStore
 
-	// Async processing
-	queryQueue chan storage.DNSQuery
-	wg         sync.WaitGroup
-	ctx        context.Context
-	cancel     context.CancelFunc
+	// Async processing. Pending work lives in the store's analysis queue;
+	// wake nudges the worker when new work arrives.
+	wake   chan struct{}
+	wg     sync.WaitGroup
+	ctx    context.Context
+	cancel context.CancelFunc
 
 	// Batching
 	batchSize    int
 	batchTimeout time.Duration
-	currentBatch []storage.DNSQuery
-	batchMu      sync.Mutex
-	batchTimer   *time.Timer
 
-	// Rate limiting
-	rateLimiter  chan struct{} // Semaphore for rate limiting
-	requestDelay time.Duration // Delay between requests
+	// Retries
+	maxAttempts int // Failed attempts before an analysis is dead-lettered
+
+	// Tiered analysis: verdicts matching the criteria are re-analyzed by the escalation provider
+	escalation         Provider
+	escalationCriteria EscalationCriteria
+
+	// Verdict cache: recent verdicts are reused for other clients (0 TTL disables)
+	cacheTTL         time.Duration
+	cacheByQueryType bool
 
 	// Statistics
 	mu                 sync.Mutex
@@ -41,10 +60,30 @@ type Analyzer struct {
 	failedAnalyses     int
 	rateLimitedCount   int
 	batchCount         int
+	attachedQueries    int // Queries attached to an existing or queued verdict instead of analyzed
+	deadLettered       int
+	escalations        int
+	escalationFailures int
+	cacheHits          int
+	cacheMisses        int
+
+	// Queued domains currently claimed by a running batch
+	claimedMu sync.Mutex
+	claimed   map[string]bool
+
+	// Set while a backfill is feeding stored first-seen events into the queue
+	backfilling atomic.Bool
+
+	// Optional top-sites list; backfilled popular domains skip the LLM like live ones
+	popularity *enrichment.PopularityList
 }
 
-// NewAnalyzer creates a new LLM analyzer
-func NewAnalyzer(provider Provider, whoisService *enrichment.WHOISService, store *storage.BoltStore, batchSize int, batchTimeout, requestDelay time.Duration) *Analyzer {
+// ErrBackfillRunning is returned when a backfill is started while another is running
+var ErrBackfillRunning = errors.New("a backfill is already running")
+
+// NewAnalyzer creates a new LLM analyzer. Batches run concurrently; wrap the
+// provider in a LimitedProvider to pace them to its RPM/TPM budget.
+func NewAnalyzer(provider Provider, whoisService *enrichment.WHOISService, store *storage.BoltStore, batchSize int, batchTimeout time.Duration) *Analyzer {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	// Validate batch settings
@@ -54,38 +93,49 @@ func NewAnalyzer(provider Provider, whoisService *enrichment.WHOISService, store
 	if batchTimeout <= 0 {
 		batchTimeout = 60 * time.Second // Default
 	}
-	if requestDelay <= 0 {
-		requestDelay = 60 * time.Second // Default
-	}
-
-	rateLimiterSize := 1 // Only allow 1 concurrent batch request
 
 	analyzer := &Analyzer{
 		provider:     provider,
 		whoisService: whoisService,
 		store:        store,
-		queryQueue:   make(chan storage.DNSQuery, 100), // Buffer up to 100 queries
+		wake:         make(chan struct{}, 1),
 		batchSize:    batchSize,
 		batchTimeout: batchTimeout,
-		currentBatch: make([]storage.DNSQuery, 0, batchSize),
-		rateLimiter:  make(chan struct{}, rateLimiterSize), // Semaphore for rate limiting
-		requestDelay: requestDelay,
+		maxAttempts:  5, // Default
 		ctx:          ctx,
 		cancel:       cancel,
+		claimed:      make(map[string]bool),
+	}
+
+	// Resume work left in the queue by a previous run
+	if pending, dead, err := store.CountQueuedAnalyses(); err != nil {
+		log.Printf("⚠️  [Analyzer] Failed to read analysis queue: %v", err)
+	} else if pending > 0 || dead > 0 {
+		log.Printf("♻️  [Analyzer] Resuming %d queued analyses (%d dead-lettered)", pending, dead)
 	}
 
 	// Start background worker
 	analyzer.wg.Add(1)
 	go analyzer.worker()
 
-	log.Printf("📦 [Analyzer] Batch processing enabled: %d domains per batch, %v timeout",
+	log.Printf("📦 [Analyzer] Batch processing enabled: up to %d domains per batch, %v timeout",
 		batchSize, batchTimeout)
-	log.Printf("🚦 [Analyzer] Rate limiting: %v delay between batches, max %d concurrent",
-		requestDelay, rateLimiterSize)
 
 	return analyzer
 }
 
+// SetMaxAttempts sets how many failed attempts an analysis gets before it is dead-lettered
+func (a *Analyzer) SetMaxAttempts(attempts int) {
+	if attempts > 0 {
+		a.maxAttempts = attempts
+	}
+}
+
+// SetPopularityList sets the top-sites list checked before backfilled events are queued
+func (a *Analyzer) SetPopularityList(list *enrichment.PopularityList) {
+	a.popularity = list
+}
+
 // AnalyzeAsync queues a DNS query for asynchronous analysis
 func (a *Analyzer) AnalyzeAsync(query interface{}) {
 	// Type assert to DNSQuery
@@ -95,86 +145,309 @@ func (a *Analyzer) AnalyzeAsync(query interface{}) {
 		return
 	}
 
+	a.submit(dnsQuery)
+}
+
+// submit attaches a query to an existing or queued verdict, or queues it
+func (a *Analyzer) submit(dnsQuery storage.DNSQuery) {
+	// Attach to the domain's verdict, pending or reviewed, instead of re-running the LLM
+	attached, err := a.store.AttachClientToAnomaly(dnsQuery.Domain, storage.AffectedClient{
+		ClientID:   dnsQuery.ClientID,
+		ClientName: dnsQuery.ClientName,
+		FirstSeen:  dnsQuery.Timestamp,
+	})
+	if err != nil {
+		log.Printf("⚠️  [Analyzer] Failed to attach %s to existing anomaly: %v", dnsQuery.ClientID, err)
+	}
+	if attached {
+		a.mu.Lock()
+		a.attachedQueries++
+		a.mu.Unlock()
+		log.Printf("🔗 [Analyzer] %s already has a verdict, attached client %s", dnsQuery.Domain, dnsQuery.ClientID)
+		a.markAnalyzed(dnsQuery)
+		return
+	}
+
+	// Reuse a recent verdict another client received for the domain
+	if a.useCachedVerdict(dnsQuery) {
+		return
+	}
+
+	// Queue the domain, or wait on the analysis already queued for it
+	queued, err := a.store.EnqueueAnalysis(dnsQuery)
+	if err != nil {
+		log.Printf("❌ [Analyzer] Failed to queue analysis for %s: %v", dnsQuery.Domain, err)
+		return
+	}
+	if !queued {
+		a.mu.Lock()
+		a.attachedQueries++
+		a.mu.Unlock()
+		log.Printf("🔗 [Analyzer] %s is already queued, client %s will share the verdict", dnsQuery.Domain, dnsQuery.ClientID)
+		return
+	}
+
+	log.Printf("🤖 [Analyzer] Query queued successfully: %s", dnsQuery.Domain)
 	select {
-	case a.queryQueue <- dnsQuery:
-		log.Printf("🤖 [Analyzer] Query queued successfully: %s (queue depth: %d)", dnsQuery.Domain, len(a.queryQueue))
+	case a.wake <- struct{}{}:
 	default:
-		log.Printf("❌ [Analyzer] Queue full, dropping analysis for %s", dnsQuery.Domain)
 	}
 }
 
-// worker processes queued queries in batches
+// Backfill queues the unanalyzed first-seen events detected in [from, to) for
+// analysis, e.g. those recorded while the LLM was disabled or rate-limited.
+// Events are fed to the queue in the background; the number of events is returned.
+func (a *Analyzer) Backfill(from, to time.Time) (int, error) {
+	if !a.backfilling.CompareAndSwap(false, true) {
+		return 0, ErrBackfillRunning
+	}
+
+	events, err := a.store.GetUnanalyzedEvents(from, to)
+	if err != nil {
+		a.backfilling.Store(false)
+		return 0, fmt.Errorf("failed to load unanalyzed events: %w", err)
+	}
+
+	log.Printf("⏪ [Analyzer] Backfilling %d unanalyzed first-seen events", len(events))
+
+	go func() {
+		defer a.backfilling.Store(false)
+		for _, event := range events {
+			if a.ctx.Err() != nil {
+				return
+			}
+
+			// Events recorded while the LLM was disabled skipped the top-sites check
+			if a.popularity != nil {
+				if rank, popular := a.popularity.Rank(event.Query.Domain); popular {
+					if err := a.RecordPopularDomain(event.Query, rank); err != nil {
+						log.Printf("⚠️  [Analyzer] Failed to record popular domain %s: %v", event.Query.Domain, err)
+					}
+					continue
+				}
+			}
+			a.submit(event.Query)
+		}
+		log.Printf("⏪ [Analyzer] Backfill queued all %d events", len(events))
+	}()
+
+	return len(events), nil
+}
+
+// markAnalyzed flags the first-seen events of queries that received a verdict
+func (a *Analyzer) markAnalyzed(queries ...storage.DNSQuery) {
+	for _, query := range queries {
+		if err := a.store.MarkEventAnalyzed(query.ClientID, query.Domain); err != nil {
+			log.Printf("⚠️  [Analyzer] Failed to mark %s as analyzed for %s: %v", query.Domain, query.ClientID, err)
+		}
+	}
+}
+
+// finishDomain removes an analyzed domain from the queue and returns the queries
+// of other clients that waited on its verdict
+func (a *Analyzer) finishDomain(domain string) []storage.DNSQuery {
+	defer a.release(domain)
+
+	item, err := a.store.CompleteAnalysis(domain)
+	if err != nil {
+		log.Printf("⚠️  [Analyzer] Failed to remove %s from the queue: %v", domain, err)
+		return nil
+	}
+	if item == nil {
+		return nil
+	}
+	return item.Waiting
+}
+
+// retryLater reschedules a queued domain after a failed attempt. Rate limiting
+// does not count as a failed attempt; other errors back off exponentially and
+// dead-letter the domain after maxAttempts.
+func (a *Analyzer) retryLater(domain string, cause error) {
+	defer a.release(domain)
+
+	if a.ctx.Err() != nil {
+		return // Shutting down; the item stays queued for the next run
+	}
+
+	rateLimited := errors.Is(cause, ErrRateLimited)
+	dead, err := a.store.RescheduleAnalysis(domain, cause.Error(), !rateLimited, a.maxAttempts, func(attempts int) time.Duration {
+		if rateLimited {
+			// Honor the provider's Retry-After when it asked for longer
+			var limitErr *RateLimitError
+			if errors.As(cause, &limitErr) {
+				return max(limitErr.RetryAfter, rateLimitBackoff)
+			}
+			return rateLimitBackoff
+		}
+		return retryBackoff(attempts)
+	})
+	if err != nil {
+		log.Printf("⚠️  [Analyzer] Failed to reschedule %s: %v", domain, err)
+		return
+	}
+	if dead {
+		a.mu.Lock()
+		a.deadLettered++
+		a.mu.Unlock()
+		log.Printf("💀 [Analyzer] Giving up on %s after %d attempts: %v", domain, a.maxAttempts, cause)
+	}
+}
+
+// retryBackoff doubles the delay with each failed attempt, up to maxRetryBackoff
+func retryBackoff(attempts int) time.Duration {
+	if attempts > 10 {
+		return maxRetryBackoff
+	}
+	return min(rateLimitBackoff<<max(attempts-1, 0), maxRetryBackoff)
+}
+
+// pruneEvery runs prune now and then every pruneInterval until the analyzer stops
+func (a *Analyzer) pruneEvery(prune func()) {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+
+		ticker := time.NewTicker(pruneInterval)
+		defer ticker.Stop()
+
+		for {
+			prune()
+
+			select {
+			case <-a.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// claim marks queued domains as taken by a batch
+func (a *Analyzer) claim(items []storage.QueuedAnalysis) {
+	a.claimedMu.Lock()
+	defer a.claimedMu.Unlock()
+
+	for _, item := range items {
+		a.claimed[item.Domain] = true
+	}
+}
+
+// release frees a domain claimed by a batch
+func (a *Analyzer) release(domain string) {
+	a.claimedMu.Lock()
+	defer a.claimedMu.Unlock()
+
+	delete(a.claimed, queueKey(domain))
+}
+
+// isClaimed reports whether a queued domain is being analyzed by a batch
+func (a *Analyzer) isClaimed(domain string) bool {
+	a.claimedMu.Lock()
+	defer a.claimedMu.Unlock()
+
+	return a.claimed[domain]
+}
+
+// queueKey normalizes a domain like the store's analysis queue
+func queueKey(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(domain, "."))
+}
+
+// worker starts batches of due queue items: immediately when a full batch is
+// waiting, otherwise whatever is due every batch timeout
 func (a *Analyzer) worker() {
 	defer a.wg.Done()
 
-	// Initialize batch timer
-	a.batchTimer = time.NewTimer(a.batchTimeout)
-	defer a.batchTimer.Stop()
+	ticker := time.NewTicker(a.batchTimeout)
+	defer ticker.Stop()
+
+	// Full batches left over from a previous run start right away
+	a.dispatch(false)
 
 	for {
 		select {
 		case <-a.ctx.Done():
-			// Flush any remaining batch before shutdown
-			a.batchMu.Lock()
-			if len(a.currentBatch) > 0 {
-				log.Printf("📦 [Analyzer] Flushing final batch of %d queries on shutdown", len(a.currentBatch))
-				a.processBatch(a.currentBatch)
-				a.currentBatch = a.currentBatch[:0]
-			}
-			a.batchMu.Unlock()
 			log.Println("[Analyzer] Shutting down worker")
 			return
 
-		case query := <-a.queryQueue:
-			a.batchMu.Lock()
-			a.currentBatch = append(a.currentBatch, query)
-			batchFull := len(a.currentBatch) >= a.batchSize
-			a.batchMu.Unlock()
-
-			// If batch is full, process it immediately
-			if batchFull {
-				a.batchMu.Lock()
-				batch := make([]storage.DNSQuery, len(a.currentBatch))
-				copy(batch, a.currentBatch)
-				a.currentBatch = a.currentBatch[:0]
-				a.batchMu.Unlock()
-
-				// Reset timer
-				if !a.batchTimer.Stop() {
-					select {
-					case <-a.batchTimer.C:
-					default:
-					}
-				}
-				a.batchTimer.Reset(a.batchTimeout)
+		case <-a.wake:
+			a.dispatch(false)
 
-				log.Printf("📦 [Analyzer] Batch full (%d queries), processing now", len(batch))
-				go a.processBatch(batch)
-			}
+		case <-ticker.C:
+			a.dispatch(true)
+		}
+	}
+}
 
-		case <-a.batchTimer.C:
-			// Timeout expired, process whatever we have
-			a.batchMu.Lock()
-			if len(a.currentBatch) > 0 {
-				batch := make([]storage.DNSQuery, len(a.currentBatch))
-				copy(batch, a.currentBatch)
-				a.currentBatch = a.currentBatch[:0]
-				a.batchMu.Unlock()
-
-				log.Printf("⏰ [Analyzer] Batch timeout, processing %d queries", len(batch))
-				go a.processBatch(batch)
-			} else {
-				a.batchMu.Unlock()
-			}
+// currentBatchSize sizes the next batch to fit the provider's token budget,
+// between one domain and the configured batch size
+func (a *Analyzer) currentBatchSize() int {
+	budgeted, ok := a.provider.(Budgeted)
+	if !ok {
+		return a.batchSize
+	}
+	tokens := budgeted.AvailableTokens()
+	if tokens < 0 {
+		return a.batchSize
+	}
 
-			// Reset timer
-			a.batchTimer.Reset(a.batchTimeout)
+	prompt, err := BuildBatchPrompt(a.provider.Name(), []storage.DNSQuery{{Domain: "subdomain.example.com"}}, nil)
+	if err != nil {
+		return a.batchSize
+	}
+	perDomain := EstimateTokens(prompt.Text) + outputTokensPerDomain
+	return max(1, min(a.batchSize, tokens/perDomain))
+}
+
+// dispatch starts a batch for every full batch of due items. With partial set,
+// a final smaller batch is started too. Batches shrink to the provider's
+// available token budget; while it is low only one smaller batch starts and
+// the rest stay queued until the budget refills.
+func (a *Analyzer) dispatch(partial bool) {
+	size := a.currentBatchSize()
+	throttled := size < a.batchSize
+
+	for a.ctx.Err() == nil {
+		items, err := a.store.DueAnalyses(time.Now(), size, a.isClaimed)
+		if err != nil {
+			log.Printf("⚠️  [Analyzer] Failed to read analysis queue: %v", err)
+			return
+		}
+		if len(items) == 0 || (!partial && len(items) < size) {
+			return
+		}
+
+		a.claim(items)
+		batch := make([]storage.DNSQuery, len(items))
+		for i, item := range items {
+			batch[i] = item.Query
+		}
+
+		if len(batch) >= size {
+			log.Printf("📦 [Analyzer] Batch full (%d queries), processing now", len(batch))
+		} else {
+			log.Printf("⏰ [Analyzer] Batch timeout, processing %d queries", len(batch))
+		}
+
+		a.wg.Add(1)
+		go func() {
+			defer a.wg.Done()
+			a.processBatch(batch)
+		}()
+
+		if len(items) < size || throttled {
+			return
 		}
 	}
 }
 
 // GetStats returns statistics about the analyzer
 func (a *Analyzer) GetStats() map[string]interface{} {
+	pending, dead, err := a.store.CountQueuedAnalyses()
+	if err != nil {
+		log.Printf("⚠️  [Analyzer] Failed to read analysis queue: %v", err)
+	}
+
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
@@ -194,10 +467,19 @@ func (a *Analyzer) GetStats() map[string]interface{} {
 		"failed_analyses":     a.failedAnalyses,
 		"rate_limited_count":  a.rateLimitedCount,
 		"batches_processed":   a.batchCount,
+		"attached_queries":    a.attachedQueries,
 		"avg_batch_size":      fmt.Sprintf("%.1f", avgBatchSize),
 		"success_rate":        successRate,
-		"queue_depth":         len(a.queryQueue),
+		"queue_depth":         pending,
+		"dead_letters":        dead,
+		"dead_lettered":       a.deadLettered,
+		"backfilling":         a.backfilling.Load(),
+		"current_batch_size":  a.currentBatchSize(),
 		"provider":            a.provider.Name(),
+		"escalations":         a.escalations,
+		"escalation_failures": a.escalationFailures,
+		"cache_hits":          a.cacheHits,
+		"cache_misses":        a.cacheMisses,
 	}
 
 	// Add WHOIS stats
@@ -209,7 +491,16 @@ func (a *Analyzer) GetStats() map[string]interface{} {
 	return stats
 }
 
-// Stop gracefully shuts down the analyzer
+// ProviderStatus returns the health of each configured provider in failover order
+func (a *Analyzer) ProviderStatus() []ProviderStatus {
+	if chain, ok := a.provider.(*Chain); ok {
+		return chain.Status()
+	}
+	return []ProviderStatus{{Name: a.provider.Name(), Available: true}}
+}
+
+// Stop gracefully shuts down the analyzer. Unfinished analyses stay queued
+// and resume on the next start.
 func (a *Analyzer) Stop() {
 	log.Println("[Analyzer] Stopping...")
 	a.cancel()
@@ -2,6 +2,7 @@ package llm
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
@@ -61,21 +62,19 @@ func (a *Analyzer) processBatch(queries []storage.DNSQuery) {
 		whoisData[query.Domain] = whois
 	}
 
-	// Step 2: Rate limiting for the entire batch
-	select {
-	case a.rateLimiter <- struct{}{}:
-		defer func() {
-			time.Sleep(a.requestDelay)
-			<-a.rateLimiter
-		}()
-	case <-a.ctx.Done():
+	if a.ctx.Err() != nil {
+		// Shutting down; the queries stay queued for the next run
+		for _, query := range queries {
+			a.release(query.Domain)
+		}
 		return
 	}
 
+	// Rate limiting is left to the provider, which waits for RPM/TPM budget
 	ctx, cancel := context.WithTimeout(a.ctx, 90*time.Second) // Longer timeout for batches
 	defer cancel()
 
-	// Step 3: Check if provider supports true batch processing
+	// Step 2: Check if provider supports true batch processing
 	if a.provider.SupportsBatch() {
 		log.Printf("🚀 [Batch #%d] Using true batch API call for %d domains", batchNum, len(queries))
 		a.processBatchWithAPI(ctx, batchNum, queries, whoisData)
@@ -100,24 +99,22 @@ func (a *Analyzer) processBatch(queries []storage.DNSQuery) {
 		analysis, err := a.provider.Analyze(ctx, query, whois)
 
 		if err != nil {
-			if err == ErrRateLimited {
+			if errors.Is(err, ErrRateLimited) {
 				log.Printf("🚫 [Batch #%d] Rate limited, stopping batch processing", batchNum)
 				a.mu.Lock()
 				a.rateLimitedCount++
 				a.failedAnalyses += (len(queries) - i)
 				a.mu.Unlock()
 
-				// Requeue remaining queries
+				// Retry remaining queries later
 				for j := i; j < len(queries); j++ {
-					go func(q storage.DNSQuery) {
-						time.Sleep(30 * time.Second)
-						a.AnalyzeAsync(q)
-					}(queries[j])
+					a.retryLater(queries[j].Domain, err)
 				}
 				return
 			}
 
 			log.Printf("❌ Failed to analyze %s: %v", query.Domain, err)
+			a.retryLater(query.Domain, err)
 			a.mu.Lock()
 			a.failedAnalyses++
 			a.mu.Unlock()
@@ -125,32 +122,22 @@ func (a *Analyzer) processBatch(queries []storage.DNSQuery) {
 			continue
 		}
 
+		// Re-analyze a suspicious first-stage verdict with the escalation provider
+		analysis = a.escalate(batchNum, queries[i:i+1], []*Analysis{analysis}, whoisData)[0]
+
 		// Save analysis
 		if err := a.store.SaveAnalysis(analysis); err != nil {
 			log.Printf("⚠️  Failed to save analysis for %s: %v", query.Domain, err)
+			a.retryLater(query.Domain, err)
+			a.mu.Lock()
+			a.failedAnalyses++
+			a.mu.Unlock()
+			failCount++
+			continue
 		}
 
 		// Save as anomaly if suspicious/malicious
-		if analysis.Classification == "Suspicious" || analysis.Classification == "Malicious" {
-			anomaly := storage.Anomaly{
-				Domain:          query.Domain,
-				ClientID:        query.ClientID,
-				ClientName:      query.ClientName,
-				QueryType:       query.QueryType,
-				Classification:  analysis.Classification,
-				RiskScore:       analysis.RiskScore,
-				Explanation:     analysis.Explanation,
-				SuggestedAction: analysis.SuggestedAction,
-				DetectedAt:      analysis.AnalyzedAt,
-			}
-
-			if err := a.store.SaveAnomaly(&anomaly); err != nil {
-				log.Printf("⚠️  Failed to save anomaly for %s: %v", query.Domain, err)
-			} else {
-				log.Printf("🚨 ANOMALY: %s -> %s (risk: %d/10)",
-					query.Domain, analysis.Classification, analysis.RiskScore)
-			}
-		}
+		a.saveVerdict(analysis, query, whois)
 
 		a.mu.Lock()
 		a.successfulAnalyses++
@@ -172,31 +159,34 @@ func (a *Analyzer) processBatchWithAPI(ctx context.Context, batchNum int, querie
 	analyses, err := a.provider.AnalyzeBatch(ctx, queries, whoisData)
 	if err != nil {
 		// Handle rate limiting
-		if err == ErrRateLimited {
+		if errors.Is(err, ErrRateLimited) {
 			log.Printf("🚫 [Batch #%d] Rate limited, will retry all %d domains later", batchNum, len(queries))
 			a.mu.Lock()
 			a.rateLimitedCount++
 			a.failedAnalyses += len(queries)
 			a.mu.Unlock()
 
-			// Requeue all queries
+			// Retry all queries later
 			for _, query := range queries {
-				go func(q storage.DNSQuery) {
-					time.Sleep(30 * time.Second)
-					a.AnalyzeAsync(q)
-				}(query)
+				a.retryLater(query.Domain, err)
 			}
 			return
 		}
 
-		// Other errors - log and count as failed
+		// Other errors - log, count as failed and retry with backoff
 		log.Printf("❌ [Batch #%d] Batch analysis failed: %v", batchNum, err)
+		for _, query := range queries {
+			a.retryLater(query.Domain, err)
+		}
 		a.mu.Lock()
 		a.failedAnalyses += len(queries)
 		a.mu.Unlock()
 		return
 	}
 
+	// Re-analyze suspicious first-stage verdicts with the escalation provider
+	analyses = a.escalate(batchNum, queries, analyses, whoisData)
+
 	// Process successful analyses
 	successCount := 0
 	failCount := 0
@@ -204,6 +194,7 @@ func (a *Analyzer) processBatchWithAPI(ctx context.Context, batchNum int, querie
 	for i, analysis := range analyses {
 		if analysis == nil {
 			log.Printf("⚠️  [Batch #%d] Nil analysis for query %d", batchNum, i)
+			a.retryLater(queries[i].Domain, fmt.Errorf("no analysis returned for %s", queries[i].Domain))
 			failCount++
 			continue
 		}
@@ -211,31 +202,13 @@ func (a *Analyzer) processBatchWithAPI(ctx context.Context, batchNum int, querie
 		// Save analysis
 		if err := a.store.SaveAnalysis(analysis); err != nil {
 			log.Printf("⚠️  [Batch #%d] Failed to save analysis for %s: %v", batchNum, analysis.Domain, err)
+			a.retryLater(queries[i].Domain, err)
 			failCount++
 			continue
 		}
 
 		// Save as anomaly if suspicious/malicious
-		if analysis.Classification == "Suspicious" || analysis.Classification == "Malicious" {
-			anomaly := storage.Anomaly{
-				Domain:          analysis.Domain,
-				ClientID:        analysis.ClientID,
-				ClientName:      analysis.ClientName,
-				QueryType:       analysis.QueryType,
-				Classification:  analysis.Classification,
-				RiskScore:       analysis.RiskScore,
-				Explanation:     analysis.Explanation,
-				SuggestedAction: analysis.SuggestedAction,
-				DetectedAt:      analysis.AnalyzedAt,
-			}
-
-			if err := a.store.SaveAnomaly(&anomaly); err != nil {
-				log.Printf("⚠️  [Batch #%d] Failed to save anomaly for %s: %v", batchNum, analysis.Domain, err)
-			} else {
-				log.Printf("🚨 ANOMALY: %s -> %s (risk: %d/10)",
-					analysis.Domain, analysis.Classification, analysis.RiskScore)
-			}
-		}
+		a.saveVerdict(analysis, queries[i], whoisData[analysis.Domain])
 
 		successCount++
 	}
@@ -248,3 +221,71 @@ func (a *Analyzer) processBatchWithAPI(ctx context.Context, batchNum int, querie
 
 	log.Printf("✅ [Batch #%d] Complete: %d succeeded, %d failed (single API call)", batchNum, successCount, failCount)
 }
+
+// saveVerdict completes a queued domain's analysis: it marks the query and every
+// client that waited on the verdict as analyzed, caches the verdict and records
+// an anomaly if the verdict is Suspicious or Malicious
+func (a *Analyzer) saveVerdict(analysis *Analysis, query storage.DNSQuery, whois *storage.WHOISData) {
+	waiting := a.finishDomain(query.Domain)
+	a.markAnalyzed(append([]storage.DNSQuery{query}, waiting...)...)
+	a.cacheVerdict(analysis)
+	a.recordAnomaly(analysis, query, whois, waiting)
+}
+
+// recordAnomaly stores a Suspicious or Malicious verdict as the domain's anomaly,
+// listing the analyzed client and every client that waited on the verdict
+func (a *Analyzer) recordAnomaly(analysis *Analysis, query storage.DNSQuery, whois *storage.WHOISData, waiting []storage.DNSQuery) {
+	if analysis.Classification != "Suspicious" && analysis.Classification != "Malicious" {
+		// A Safe re-analysis still records the clients on an earlier verdict
+		for _, q := range append([]storage.DNSQuery{query}, waiting...) {
+			if _, err := a.store.AttachClientToAnomaly(q.Domain, storage.AffectedClient{
+				ClientID:   q.ClientID,
+				ClientName: q.ClientName,
+				FirstSeen:  q.Timestamp,
+			}); err != nil {
+				log.Printf("⚠️  [Analyzer] Failed to attach %s to existing anomaly: %v", q.ClientID, err)
+			}
+		}
+		return
+	}
+
+	anomaly := storage.Anomaly{
+		Domain:          query.Domain,
+		ClientID:        query.ClientID,
+		ClientName:      query.ClientName,
+		QueryType:       query.QueryType,
+		Classification:  analysis.Classification,
+		RiskScore:       analysis.RiskScore,
+		Explanation:     analysis.Explanation,
+		SuggestedAction: analysis.SuggestedAction,
+		DetectedAt:      analysis.AnalyzedAt,
+		OffHours:        query.OffHours,
+	}
+	if whois != nil {
+		anomaly.DomainCreatedAt = whois.CreatedAt
+	}
+	if analysis.PromptVersion != "" {
+		anomaly.Details = map[string]string{"prompt_version": analysis.PromptVersion}
+	}
+
+	anomaly.AddClient(storage.AffectedClient{
+		ClientID:   query.ClientID,
+		ClientName: query.ClientName,
+		FirstSeen:  query.Timestamp,
+	})
+	for _, other := range waiting {
+		anomaly.AddClient(storage.AffectedClient{
+			ClientID:   other.ClientID,
+			ClientName: other.ClientName,
+			FirstSeen:  other.Timestamp,
+		})
+	}
+
+	if err := a.store.SaveAnomaly(&anomaly); err != nil {
+		log.Printf("⚠️  Failed to save anomaly for %s: %v", query.Domain, err)
+		return
+	}
+
+	log.Printf("🚨 ANOMALY: %s -> %s (risk: %d/10, %d clients)",
+		query.Domain, analysis.Classification, analysis.RiskScore, len(anomaly.AffectedClients))
+}
@@ -0,0 +1,89 @@
+package llm
+
+import (
+	"log"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+// SetVerdictCache enables reuse of verdicts younger than ttl for other clients
+// querying the same domain. With byQueryType, verdicts are cached per domain and
+// query type (e.g. A and TXT lookups are analyzed separately). Expired verdicts
+// are removed every hour until the analyzer stops.
+func (a *Analyzer) SetVerdictCache(ttl time.Duration, byQueryType bool) {
+	a.cacheTTL = ttl
+	a.cacheByQueryType = byQueryType
+
+	if ttl <= 0 {
+		return
+	}
+	a.pruneEvery(func() {
+		if removed, err := a.store.CleanVerdictCache(ttl); err != nil {
+			log.Printf("⚠️  [Analyzer] Failed to clean verdict cache: %v", err)
+		} else if removed > 0 {
+			log.Printf("🧹 [Analyzer] Removed %d expired cached verdicts", removed)
+		}
+	})
+}
+
+// cacheQueryType returns the query type part of a verdict cache key
+func (a *Analyzer) cacheQueryType(queryType string) string {
+	if !a.cacheByQueryType {
+		return ""
+	}
+	return queryType
+}
+
+// cacheVerdict stores a fresh LLM verdict for reuse
+func (a *Analyzer) cacheVerdict(analysis *Analysis) {
+	if a.cacheTTL <= 0 || analysis.Cached {
+		return
+	}
+	if err := a.store.CacheVerdict(analysis.Domain, a.cacheQueryType(analysis.QueryType), analysis); err != nil {
+		log.Printf("⚠️  [Analyzer] Failed to cache verdict for %s: %v", analysis.Domain, err)
+	}
+}
+
+// useCachedVerdict records a recent cached verdict for the query's domain,
+// re-attributed to the query's client. It returns false on a cache miss.
+func (a *Analyzer) useCachedVerdict(query storage.DNSQuery) bool {
+	if a.cacheTTL <= 0 {
+		return false
+	}
+
+	var analysis Analysis
+	hit, err := a.store.GetCachedVerdict(query.Domain, a.cacheQueryType(query.QueryType), a.cacheTTL, &analysis)
+	if err != nil {
+		log.Printf("⚠️  [Analyzer] Failed to read cached verdict for %s: %v", query.Domain, err)
+	}
+
+	a.mu.Lock()
+	if hit {
+		a.cacheHits++
+	} else {
+		a.cacheMisses++
+	}
+	a.mu.Unlock()
+
+	if !hit {
+		return false
+	}
+
+	analysis.ClientID = query.ClientID
+	analysis.ClientName = query.ClientName
+	analysis.QueryType = query.QueryType
+	analysis.AnalyzedAt = time.Now()
+	analysis.Cached = true
+
+	if err := a.store.SaveAnalysis(&analysis); err != nil {
+		log.Printf("⚠️  [Analyzer] Failed to save cached verdict for %s: %v", query.Domain, err)
+		return false
+	}
+	a.markAnalyzed(query)
+	a.recordAnomaly(&analysis, query, nil, nil)
+
+	log.Printf("💾 [Analyzer] Reused cached verdict for %s: %s (risk: %d/10, client: %s)",
+		query.Domain, analysis.Classification, analysis.RiskScore, query.ClientID)
+	return true
+}
@@ -0,0 +1,234 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+const (
+	// DefaultChainCooldown is how long a failing provider is skipped
+	DefaultChainCooldown = 10 * time.Minute
+
+	// DefaultChainMaxErrors is how many consecutive errors put a provider into cooldown
+	DefaultChainMaxErrors = 3
+)
+
+// ErrAllProvidersCoolingDown is returned when every provider in a chain is cooling down.
+// It wraps ErrRateLimited so queued analyses wait without using up an attempt.
+var ErrAllProvidersCoolingDown = fmt.Errorf("%w: all providers are cooling down", ErrRateLimited)
+
+// Chain is a Provider that tries an ordered list of providers, failing over to the
+// next one when a provider is rate limited, times out or keeps returning errors.
+// Failed providers are skipped until their cooldown expires.
+type Chain struct {
+	mu        sync.Mutex
+	members   []*chainMember
+	cooldown  time.Duration
+	maxErrors int
+}
+
+// chainMember tracks the health of one provider in a chain
+type chainMember struct {
+	provider     Provider
+	errors       int
+	coolingUntil time.Time
+	lastError    string
+	analyses     int
+	failovers    int
+}
+
+// ProviderStatus describes the health of one provider in a chain
+type ProviderStatus struct {
+	Name         string     `json:"name"`
+	Available    bool       `json:"available"`
+	CoolingUntil *time.Time `json:"cooling_until,omitempty"`
+	Errors       int        `json:"consecutive_errors"`
+	LastError    string     `json:"last_error,omitempty"`
+	Analyses     int        `json:"analyses"`
+	Failovers    int        `json:"failovers"`
+}
+
+// NewChain creates a provider chain. Providers are tried in the given order.
+func NewChain(providers []Provider, cooldown time.Duration, maxErrors int) *Chain {
+	if cooldown <= 0 {
+		cooldown = DefaultChainCooldown
+	}
+	if maxErrors <= 0 {
+		maxErrors = DefaultChainMaxErrors
+	}
+
+	members := make([]*chainMember, len(providers))
+	for i, provider := range providers {
+		members[i] = &chainMember{provider: provider}
+	}
+
+	return &Chain{
+		members:   members,
+		cooldown:  cooldown,
+		maxErrors: maxErrors,
+	}
+}
+
+// Name returns the provider names in failover order (e.g. "gemini,ollama")
+func (c *Chain) Name() string {
+	names := make([]string, len(c.members))
+	for i, m := range c.members {
+		names[i] = m.provider.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// SupportsBatch returns true; providers without batch support analyze batches one query at a time
+func (c *Chain) SupportsBatch() bool {
+	return true
+}
+
+// AvailableTokens returns the token budget of the first available provider, or -1 if unlimited
+func (c *Chain) AvailableTokens() int {
+	for _, m := range c.members {
+		if !c.available(m) {
+			continue
+		}
+		if budgeted, ok := m.provider.(Budgeted); ok {
+			return budgeted.AvailableTokens()
+		}
+		return -1
+	}
+	return -1
+}
+
+// Analyze analyzes a query with the first available provider
+func (c *Chain) Analyze(ctx context.Context, query storage.DNSQuery, whois *storage.WHOISData) (*Analysis, error) {
+	var analysis *Analysis
+	err := c.try(ctx, func(p Provider) error {
+		var err error
+		analysis, err = p.Analyze(ctx, query, whois)
+		return err
+	})
+	return analysis, err
+}
+
+// AnalyzeBatch analyzes a batch with the first available provider
+func (c *Chain) AnalyzeBatch(ctx context.Context, queries []storage.DNSQuery, whoisData map[string]*storage.WHOISData) ([]*Analysis, error) {
+	var analyses []*Analysis
+	err := c.try(ctx, func(p Provider) error {
+		var err error
+		if p.SupportsBatch() {
+			analyses, err = p.AnalyzeBatch(ctx, queries, whoisData)
+			return err
+		}
+
+		results := make([]*Analysis, len(queries))
+		for i, query := range queries {
+			if results[i], err = p.Analyze(ctx, query, whoisData[query.Domain]); err != nil {
+				return err
+			}
+		}
+		analyses = results
+		return nil
+	})
+	return analyses, err
+}
+
+// try runs fn against each available provider in order until one succeeds or
+// returns an error that should not trigger failover
+func (c *Chain) try(ctx context.Context, fn func(Provider) error) error {
+	tried := 0
+	var lastErr error
+
+	for _, m := range c.members {
+		if !c.available(m) {
+			continue
+		}
+		tried++
+
+		err := fn(m.provider)
+		if err == nil {
+			c.recordSuccess(m)
+			return nil
+		}
+
+		// Shutting down; not the provider's fault
+		if ctx.Err() != nil {
+			return err
+		}
+
+		lastErr = err
+		if !c.recordFailure(m, err) {
+			return err
+		}
+	}
+
+	if tried == 0 {
+		return ErrAllProvidersCoolingDown
+	}
+	return lastErr
+}
+
+// available reports whether a provider is outside its cooldown
+func (c *Chain) available(m *chainMember) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().After(m.coolingUntil)
+}
+
+// recordSuccess resets a provider's error count
+func (c *Chain) recordSuccess(m *chainMember) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	m.errors = 0
+	m.analyses++
+}
+
+// recordFailure counts an error against a provider and starts its cooldown when
+// it is rate limited, timed out or has failed too many times in a row. It returns
+// true if the chain should fail over to the next provider.
+func (c *Chain) recordFailure(m *chainMember, err error) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	m.errors++
+	m.lastError = err.Error()
+
+	unavailable := errors.Is(err, ErrRateLimited) || errors.Is(err, ErrTimeout)
+	if !unavailable && m.errors < c.maxErrors {
+		return false
+	}
+
+	m.coolingUntil = time.Now().Add(c.cooldown)
+	m.failovers++
+	log.Printf("🔀 [Chain] %s unavailable (%v), cooling down for %s", m.provider.Name(), err, c.cooldown)
+	return true
+}
+
+// Status returns the health of each provider in failover order
+func (c *Chain) Status() []ProviderStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	statuses := make([]ProviderStatus, len(c.members))
+	for i, m := range c.members {
+		status := ProviderStatus{
+			Name:      m.provider.Name(),
+			Available: now.After(m.coolingUntil),
+			Errors:    m.errors,
+			LastError: m.lastError,
+			Analyses:  m.analyses,
+			Failovers: m.failovers,
+		}
+		if !status.Available {
+			until := m.coolingUntil
+			status.CoolingUntil = &until
+		}
+		statuses[i] = status
+	}
+	return statuses
+}
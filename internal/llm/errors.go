@@ -1,6 +1,9 @@
 package llm
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
 	// ErrInvalidClassification is returned when the LLM returns an invalid classification
@@ -26,4 +29,7 @@ var (
 
 	// ErrRateLimited is returned when the LLM API rate limit is exceeded
 	ErrRateLimited = errors.New("LLM API rate limit exceeded")
+
+	// ErrBudgetExceeded pauses paid providers until the spent day or month rolls over
+	ErrBudgetExceeded = fmt.Errorf("%w: LLM budget exceeded", ErrRateLimited)
 )
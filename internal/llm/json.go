@@ -0,0 +1,121 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+// ExtractJSON returns the JSON object or array embedded in an LLM response,
+// tolerating markdown code fences and prose before or after the JSON
+func ExtractJSON(text string) (string, error) {
+	text = strings.TrimSpace(text)
+
+	// Prefer the contents of a ```json (or bare ```) fence
+	if start := strings.Index(text, "```"); start >= 0 {
+		fenced := text[start+3:]
+		if newline := strings.IndexByte(fenced, '\n'); newline >= 0 {
+			fenced = fenced[newline+1:]
+		}
+		if end := strings.Index(fenced, "```"); end >= 0 {
+			fenced = strings.TrimSpace(fenced[:end])
+			if json.Valid([]byte(fenced)) {
+				return fenced, nil
+			}
+		}
+	}
+
+	// Otherwise take the first complete JSON value starting at a { or [
+	for i := 0; i < len(text); i++ {
+		if text[i] != '{' && text[i] != '[' {
+			continue
+		}
+
+		decoder := json.NewDecoder(strings.NewReader(text[i:]))
+		var value json.RawMessage
+		if err := decoder.Decode(&value); err == nil {
+			return string(value), nil
+		}
+	}
+
+	return "", ErrInvalidJSON
+}
+
+// ParseBatchResponse parses a batch response that is either a JSON array of
+// verdicts or an object wrapping that array (e.g. {"results": [...]}), as
+// produced by providers whose JSON modes require a top-level object
+func ParseBatchResponse(text string) ([]BatchAnalysisResponse, error) {
+	jsonText, err := ExtractJSON(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []BatchAnalysisResponse
+	if strings.HasPrefix(jsonText, "[") {
+		if err := json.Unmarshal([]byte(jsonText), &results); err != nil {
+			return nil, err
+		}
+		return results, nil
+	}
+
+	var envelope map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(jsonText), &envelope); err != nil {
+		return nil, err
+	}
+	if raw, ok := envelope["results"]; ok {
+		err := json.Unmarshal(raw, &results)
+		return results, err
+	}
+	for _, raw := range envelope {
+		if err := json.Unmarshal(raw, &results); err == nil {
+			return results, nil
+		}
+	}
+	return nil, fmt.Errorf("no array of results in batch response")
+}
+
+// BatchAnalyses checks a batch response against the queries it answers, one
+// verdict per domain in the same order, and converts it to analyses
+func BatchAnalyses(provider, promptVersion string, queries []storage.DNSQuery, responses []BatchAnalysisResponse) ([]*Analysis, error) {
+	// Validate we got responses for all queries
+	if len(responses) != len(queries) {
+		return nil, fmt.Errorf("batch response count mismatch: expected %d, got %d",
+			len(queries), len(responses))
+	}
+
+	analyses := make([]*Analysis, len(queries))
+	for i, resp := range responses {
+		if err := resp.Validate(); err != nil {
+			return nil, fmt.Errorf("batch response[%d] validation failed: %w", i, err)
+		}
+
+		// Ensure domain matches (responses should be in order)
+		if resp.Domain != queries[i].Domain {
+			return nil, fmt.Errorf("domain mismatch at index %d: expected %s, got %s",
+				i, queries[i].Domain, resp.Domain)
+		}
+
+		analyses[i] = &Analysis{
+			Domain:          queries[i].Domain,
+			ClientID:        queries[i].ClientID,
+			ClientName:      queries[i].ClientName,
+			Classification:  resp.Classification,
+			Explanation:     resp.Explanation,
+			RiskScore:       resp.RiskScore,
+			SuggestedAction: resp.SuggestedAction,
+			AnalyzedAt:      time.Now(),
+			Provider:        provider,
+			QueryType:       queries[i].QueryType,
+			PromptVersion:   promptVersion,
+		}
+
+		log.Printf("  [%d/%d] %s -> %s (risk: %d/10)",
+			i+1, len(queries), analyses[i].Domain, analyses[i].Classification, analyses[i].RiskScore)
+	}
+
+	return analyses, nil
+}
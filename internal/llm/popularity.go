@@ -0,0 +1,42 @@
+package llm
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+// PopularityProvider is the name recorded on analyses of top-ranked sites
+const PopularityProvider = "popularity"
+
+// RecordPopularDomain records a first-seen domain from the top-sites list as a
+// low-risk analysis instead of sending it to the LLM, and counts the saved call
+func (a *Analyzer) RecordPopularDomain(query storage.DNSQuery, rank int) error {
+	analysis := &Analysis{
+		Domain:          query.Domain,
+		ClientID:        query.ClientID,
+		ClientName:      query.ClientName,
+		Classification:  "Safe",
+		Explanation:     fmt.Sprintf("Ranked #%d on the top-sites list; skipped LLM analysis", rank),
+		RiskScore:       1,
+		SuggestedAction: "Allow",
+		AnalyzedAt:      time.Now(),
+		Provider:        PopularityProvider,
+		QueryType:       query.QueryType,
+	}
+
+	if err := a.store.SaveAnalysis(analysis); err != nil {
+		return fmt.Errorf("failed to save analysis: %w", err)
+	}
+	if err := a.store.IncrementCounter(storage.CounterLLMCallsSaved); err != nil {
+		return fmt.Errorf("failed to update counter: %w", err)
+	}
+	if err := a.store.MarkEventAnalyzed(query.ClientID, query.Domain); err != nil {
+		return fmt.Errorf("failed to mark event as analyzed: %w", err)
+	}
+
+	log.Printf("⭐ [Popularity] %s is ranked #%d, skipping LLM analysis", query.Domain, rank)
+	return nil
+}
@@ -1,118 +1,262 @@
 package llm
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
 	"fmt"
+	"io/fs"
+	"os"
+	"sort"
 	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
 
 	"github.com/eiladin/guardian-log/internal/storage"
 )
 
-// BuildPrompt constructs the LLM prompt for analyzing a DNS query
-func BuildPrompt(query storage.DNSQuery, whois *storage.WHOISData) string {
-	var sb strings.Builder
+//go:embed prompts/*.tmpl
+var defaultPrompts embed.FS
+
+const (
+	// analyzeTemplate is the template kind for single-domain prompts
+	analyzeTemplate = "analyze"
+
+	// batchTemplate is the template kind for batch prompts
+	batchTemplate = "batch"
+)
+
+// Prompt is a rendered prompt and the version of the template that produced it
+type Prompt struct {
+	Text    string
+	Version string // Template name and content hash, e.g. "analyze@1a2b3c4d5e6f"
+}
+
+// PromptData is the data a single-domain prompt template is rendered with.
+// In batch prompts it describes one domain of the batch.
+type PromptData struct {
+	Provider string
+	Number   int // Position in the batch, starting at 1
+	Query    storage.DNSQuery
+	WHOIS    *storage.WHOISData // nil if the lookup failed
+	AgeDays  int                // Domain age from WHOIS, if HasAge
+	HasAge   bool
+}
+
+// BatchPromptData is the data a batch prompt template is rendered with
+type BatchPromptData struct {
+	Provider    string
+	Domains     []PromptData
+	AnyOffHours bool // At least one query was made outside the client's active hours
+}
+
+// promptTemplate is a parsed prompt template and its version
+type promptTemplate struct {
+	tmpl    *template.Template
+	version string
+}
+
+// promptFuncs are the functions available to prompt templates
+var promptFuncs = template.FuncMap{
+	"join": strings.Join,
+}
 
-	sb.WriteString("You are a cybersecurity expert analyzing DNS queries for potential threats.\n\n")
+// prompts holds the loaded templates keyed by lowercase name without extension,
+// such as "analyze", "analyze.txt", "batch.ollama" or "analyze.gemini.txt"
+var prompts atomic.Pointer[map[string]*promptTemplate]
 
-	// Query information
-	sb.WriteString("## DNS Query Details\n")
-	sb.WriteString(fmt.Sprintf("- **Domain**: %s\n", query.Domain))
-	sb.WriteString(fmt.Sprintf("- **Client**: %s (%s)\n", query.ClientName, query.ClientID))
-	sb.WriteString(fmt.Sprintf("- **Query Type**: %s\n", query.QueryType))
-	sb.WriteString(fmt.Sprintf("- **Response**: %s\n", query.Response))
-	if query.Upstream != "" {
-		sb.WriteString(fmt.Sprintf("- **Upstream**: %s\n", query.Upstream))
+func init() {
+	templates, err := parseDefaultPrompts()
+	if err != nil {
+		panic(fmt.Sprintf("invalid embedded prompt templates: %v", err))
 	}
-	sb.WriteString("\n")
+	prompts.Store(&templates)
+}
 
-	// WHOIS enrichment data
-	if whois != nil {
-		sb.WriteString("## Domain Information (WHOIS)\n")
+// parseDefaultPrompts parses the embedded prompt templates
+func parseDefaultPrompts() (map[string]*promptTemplate, error) {
+	embedded, err := fs.Sub(defaultPrompts, "prompts")
+	if err != nil {
+		return nil, err
+	}
+	return parsePrompts(embedded, nil)
+}
 
-		if whois.Registrar != "" {
-			sb.WriteString(fmt.Sprintf("- **Registrar**: %s\n", whois.Registrar))
-		}
+// LoadPrompts loads prompt templates from dir on top of the embedded defaults.
+// Files are named <kind>[.<provider>][.<query type>].tmpl, where kind is
+// "analyze" or "batch", e.g. analyze.tmpl, analyze.TXT.tmpl or batch.ollama.tmpl.
+// It returns the versions of the loaded templates.
+func LoadPrompts(dir string) ([]string, error) {
+	defaults, err := parseDefaultPrompts()
+	if err != nil {
+		return nil, err
+	}
+	templates, err := parsePrompts(os.DirFS(dir), defaults)
+	if err != nil {
+		return nil, err
+	}
+	prompts.Store(&templates)
 
-		if whois.Country != "" {
-			sb.WriteString(fmt.Sprintf("- **Country**: %s\n", whois.Country))
-		}
+	versions := make([]string, 0, len(templates))
+	for _, t := range templates {
+		versions = append(versions, t.version)
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
 
-		if whois.CreatedDate != "" {
-			sb.WriteString(fmt.Sprintf("- **Created**: %s\n", whois.CreatedDate))
+// parsePrompts parses every analyze*.tmpl and batch*.tmpl file in fsys,
+// adding them to (and overriding) base
+func parsePrompts(fsys fs.FS, base map[string]*promptTemplate) (map[string]*promptTemplate, error) {
+	templates := make(map[string]*promptTemplate, len(base))
+	for name, t := range base {
+		templates[name] = t
+	}
+
+	paths, err := fs.Glob(fsys, "*.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list prompt templates: %w", err)
+	}
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(path, ".tmpl")
+		kind, _, _ := strings.Cut(name, ".")
+		if kind != analyzeTemplate && kind != batchTemplate {
+			continue
 		}
 
-		if whois.UpdatedDate != "" {
-			sb.WriteString(fmt.Sprintf("- **Updated**: %s\n", whois.UpdatedDate))
+		source, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read prompt template %s: %w", path, err)
+		}
+		tmpl, err := template.New(name).Funcs(promptFuncs).Parse(string(source))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse prompt template %s: %w", path, err)
 		}
 
-		if whois.ExpiryDate != "" {
-			sb.WriteString(fmt.Sprintf("- **Expires**: %s\n", whois.ExpiryDate))
+		// Render sample data so mistakes surface at startup rather than per query
+		if err := tmpl.Execute(&bytes.Buffer{}, samplePromptData(kind)); err != nil {
+			return nil, fmt.Errorf("failed to render prompt template %s: %w", path, err)
 		}
 
-		if len(whois.NameServers) > 0 {
-			sb.WriteString(fmt.Sprintf("- **Name Servers**: %s\n", strings.Join(whois.NameServers, ", ")))
+		hash := sha256.Sum256(source)
+		templates[strings.ToLower(name)] = &promptTemplate{
+			tmpl:    tmpl,
+			version: name + "@" + hex.EncodeToString(hash[:6]),
 		}
+	}
+	return templates, nil
+}
+
+// samplePromptData returns data that exercises every field of a template kind
+func samplePromptData(kind string) any {
+	created := time.Now().AddDate(0, 0, -3)
+	domain := PromptData{
+		Provider: "sample",
+		Number:   1,
+		Query: storage.DNSQuery{
+			ClientID:   "192.168.1.10",
+			ClientName: "sample",
+			Domain:     "sample.example",
+			QueryType:  "A",
+			Response:   "NOERROR",
+			Upstream:   "1.1.1.1",
+			OffHours:   true,
+		},
+		WHOIS: &storage.WHOISData{
+			Domain:      "sample.example",
+			Registrar:   "Sample Registrar",
+			Country:     "US",
+			CreatedDate: created.Format("2006-01-02"),
+			CreatedAt:   created,
+			NameServers: []string{"ns1.sample.example"},
+		},
+		AgeDays: 3,
+		HasAge:  true,
+	}
+	if kind == batchTemplate {
+		return BatchPromptData{Provider: domain.Provider, Domains: []PromptData{domain}, AnyOffHours: true}
+	}
+	return domain
+}
+
+// selectPrompt returns the most specific template of a kind for a provider and
+// query type: <kind>.<provider>.<type>, <kind>.<type>, <kind>.<provider>, <kind>
+func selectPrompt(kind, provider, queryType string) *promptTemplate {
+	templates := *prompts.Load()
+	provider = strings.ToLower(provider)
+	queryType = strings.ToLower(queryType)
+
+	var candidates []string
+	if provider != "" && queryType != "" {
+		candidates = append(candidates, kind+"."+provider+"."+queryType)
+	}
+	if queryType != "" {
+		candidates = append(candidates, kind+"."+queryType)
+	}
+	if provider != "" {
+		candidates = append(candidates, kind+"."+provider)
+	}
+	candidates = append(candidates, kind)
 
-		sb.WriteString("\n")
-	}
-
-	// Analysis instructions
-	sb.WriteString("## Analysis Task\n")
-	sb.WriteString("This domain was identified as a **first-time query** from this client. ")
-	sb.WriteString("Analyze this DNS query for potential security threats considering:\n\n")
-	sb.WriteString("1. **Domain Reputation**: Is this a known malicious domain? Does it exhibit suspicious patterns?\n")
-	sb.WriteString("2. **WHOIS Patterns**: Recent registration? Privacy-protected? Unusual registrar or country?\n")
-	sb.WriteString("3. **Query Context**: Does the query type match expected behavior for this domain?\n")
-	sb.WriteString("4. **Infrastructure**: Are the name servers or hosting infrastructure suspicious?\n\n")
-
-	// Response format
-	sb.WriteString("## Required Response Format\n")
-	sb.WriteString("Respond **only** with valid JSON in the following format (no additional text):\n\n")
-	sb.WriteString("```json\n")
-	sb.WriteString("{\n")
-	sb.WriteString("  \"classification\": \"Safe|Suspicious|Malicious\",\n")
-	sb.WriteString("  \"explanation\": \"Brief explanation of your assessment\",\n")
-	sb.WriteString("  \"risk_score\": 1-10,\n")
-	sb.WriteString("  \"suggested_action\": \"Allow|Investigate|Block\"\n")
-	sb.WriteString("}\n")
-	sb.WriteString("```\n\n")
-
-	// Classification guidelines
-	sb.WriteString("### Classification Guidelines\n")
-	sb.WriteString("- **Safe** (1-3): Legitimate domain from reputable organizations\n")
-	sb.WriteString("- **Suspicious** (4-7): Unusual patterns that warrant investigation\n")
-	sb.WriteString("- **Malicious** (8-10): Known threats or clear indicators of malicious activity\n\n")
-
-	// Action guidelines
-	sb.WriteString("### Action Guidelines\n")
-	sb.WriteString("- **Allow**: No action needed, domain appears safe\n")
-	sb.WriteString("- **Investigate**: Flag for manual review, potential risk\n")
-	sb.WriteString("- **Block**: Immediate threat, recommend blocking\n")
-
-	return sb.String()
+	for _, name := range candidates {
+		if t, ok := templates[name]; ok {
+			return t
+		}
+	}
+	return nil
 }
 
-// BuildBatchPrompt constructs a prompt for analyzing multiple queries at once
-// This can be more efficient with some LLM providers
-func BuildBatchPrompt(queries []storage.DNSQuery, whoisData map[string]*storage.WHOISData) string {
+// render executes a template into a Prompt
+func (t *promptTemplate) render(data any) (Prompt, error) {
 	var sb strings.Builder
+	if err := t.tmpl.Execute(&sb, data); err != nil {
+		return Prompt{}, fmt.Errorf("failed to render prompt %s: %w", t.version, err)
+	}
+	return Prompt{Text: sb.String(), Version: t.version}, nil
+}
 
-	sb.WriteString("Analyze these DNS queries for security threats. Respond with JSON array only.\n\n")
+// promptData collects the template data for one query
+func promptData(provider string, number int, query storage.DNSQuery, whois *storage.WHOISData) PromptData {
+	data := PromptData{
+		Provider: provider,
+		Number:   number,
+		Query:    query,
+		WHOIS:    whois,
+	}
+	if whois != nil {
+		data.AgeDays, data.HasAge = whois.AgeDays(time.Now())
+	}
+	return data
+}
+
+// BuildPrompt renders the LLM prompt for analyzing a DNS query with the
+// template selected for the provider and the query's type
+func BuildPrompt(provider string, query storage.DNSQuery, whois *storage.WHOISData) (Prompt, error) {
+	t := selectPrompt(analyzeTemplate, provider, query.QueryType)
+	return t.render(promptData(provider, 1, query, whois))
+}
 
+// BuildBatchPrompt renders a prompt for analyzing multiple queries at once with
+// the template selected for the provider. A query-type template is used when
+// every query in the batch has that type.
+func BuildBatchPrompt(provider string, queries []storage.DNSQuery, whoisData map[string]*storage.WHOISData) (Prompt, error) {
+	data := BatchPromptData{Provider: provider, Domains: make([]PromptData, len(queries))}
+
+	queryType := ""
 	for i, query := range queries {
-		sb.WriteString(fmt.Sprintf("%d. %s", i+1, query.Domain))
-
-		if whois, ok := whoisData[query.Domain]; ok && whois != nil {
-			if whois.Country != "" {
-				sb.WriteString(fmt.Sprintf(" [%s]", whois.Country))
-			}
-			if whois.Registrar != "" {
-				sb.WriteString(fmt.Sprintf(" (%s)", whois.Registrar))
-			}
+		data.Domains[i] = promptData(provider, i+1, query, whoisData[query.Domain])
+		data.AnyOffHours = data.AnyOffHours || query.OffHours
+
+		if i == 0 {
+			queryType = query.QueryType
+		} else if query.QueryType != queryType {
+			queryType = ""
 		}
-		sb.WriteString("\n")
 	}
 
-	sb.WriteString("\nFormat: [{\"domain\":\"x.com\",\"classification\":\"Safe|Suspicious|Malicious\",\"explanation\":\"...\",\"risk_score\":1-10,\"suggested_action\":\"Allow|Investigate|Block\"}]\n")
-
-	return sb.String()
+	t := selectPrompt(batchTemplate, provider, queryType)
+	return t.render(data)
 }
@@ -0,0 +1,299 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/llm"
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+const (
+	// DefaultBaseURL is the Anthropic API endpoint
+	DefaultBaseURL = "https://api.anthropic.com"
+
+	// APIVersion is the Messages API version sent in the anthropic-version header
+	APIVersion = "2023-06-01"
+
+	// MaxRetries is the maximum number of retry attempts for rate-limited requests
+	MaxRetries = 3
+
+	// InitialBackoff is the initial backoff duration for retries
+	InitialBackoff = 1 * time.Second
+
+	// MaxBackoff is the maximum backoff duration
+	MaxBackoff = 30 * time.Second
+
+	// maxTokens limits the response of a single-domain analysis
+	maxTokens = 1024
+
+	// maxTokensPerDomain is the response budget per domain in a batch
+	maxTokensPerDomain = 256
+
+	// maxBatchTokens caps the response budget of a batch
+	maxBatchTokens = 8192
+)
+
+// systemPrompt keeps responses to bare JSON so they parse reliably
+const systemPrompt = "You are a DNS security analyst. Reply with JSON only, no prose or markdown."
+
+// Provider implements the LLM Provider interface for the Anthropic Messages API
+type Provider struct {
+	apiKey  string
+	model   string
+	timeout time.Duration
+	baseURL string
+	client  *http.Client
+
+	observeQuota func(llm.Quota) // Receives the rate-limit headers of each response
+
+	initialBackoff time.Duration
+}
+
+// NewProvider creates a new Anthropic provider
+func NewProvider(apiKey, model string, timeout time.Duration) (*Provider, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("anthropic API key is required")
+	}
+
+	if model == "" {
+		model = "claude-3-5-haiku-latest" // Default to the fast, inexpensive model
+	}
+
+	return &Provider{
+		apiKey:         apiKey,
+		model:          model,
+		timeout:        timeout,
+		baseURL:        DefaultBaseURL,
+		client:         &http.Client{},
+		initialBackoff: InitialBackoff,
+	}, nil
+}
+
+// Name returns the provider name
+func (p *Provider) Name() string {
+	return "anthropic"
+}
+
+// SupportsBatch returns true indicating Anthropic supports batch analysis
+func (p *Provider) SupportsBatch() bool {
+	return true
+}
+
+// messagesRequest is the Messages API request body
+type messagesRequest struct {
+	Model       string    `json:"model"`
+	MaxTokens   int       `json:"max_tokens"`
+	System      string    `json:"system,omitempty"`
+	Messages    []message `json:"messages"`
+	Temperature float64   `json:"temperature"`
+}
+
+// message is a single conversation turn
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// messagesResponse is the subset of the Messages API response we use
+type messagesResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	StopReason string `json:"stop_reason"`
+	Usage      struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// apiError is the error body returned by the API
+type apiError struct {
+	Error struct {
+		Type    string `json:"type"` // e.g. rate_limit_error, overloaded_error
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Analyze performs LLM analysis on a DNS query using Anthropic with retry logic
+func (p *Provider) Analyze(ctx context.Context, query storage.DNSQuery, whois *storage.WHOISData) (*llm.Analysis, error) {
+	// Create context with timeout
+	analyzeCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	log.Printf("[Anthropic] Analyzing domain: %s (client: %s)", query.Domain, query.ClientID)
+
+	prompt, err := llm.BuildPrompt(p.Name(), query, whois)
+	if err != nil {
+		return nil, err
+	}
+
+	responseText, err := p.complete(analyzeCtx, prompt.Text, maxTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Anthropic] Raw response: %s", responseText)
+
+	// Parse JSON response
+	var llmResp llm.LLMResponse
+	jsonText, err := llm.ExtractJSON(responseText)
+	if err == nil {
+		err = json.Unmarshal([]byte(jsonText), &llmResp)
+	}
+	if err != nil {
+		log.Printf("[Anthropic] Failed to parse JSON: %v", err)
+		return nil, fmt.Errorf("%w: %v", llm.ErrInvalidJSON, err)
+	}
+
+	// Validate response
+	if err := llmResp.Validate(); err != nil {
+		log.Printf("[Anthropic] Response validation failed: %v", err)
+		return nil, err
+	}
+
+	// Build Analysis result
+	analysis := &llm.Analysis{
+		Domain:          query.Domain,
+		ClientID:        query.ClientID,
+		ClientName:      query.ClientName,
+		Classification:  llmResp.Classification,
+		Explanation:     llmResp.Explanation,
+		RiskScore:       llmResp.RiskScore,
+		SuggestedAction: llmResp.SuggestedAction,
+		AnalyzedAt:      time.Now(),
+		Provider:        p.Name(),
+		QueryType:       query.QueryType,
+		PromptVersion:   prompt.Version,
+	}
+
+	log.Printf("[Anthropic] Analysis complete: %s -> %s (risk: %d/10, action: %s)",
+		query.Domain, analysis.Classification, analysis.RiskScore, analysis.SuggestedAction)
+
+	return analysis, nil
+}
+
+// complete sends a prompt to the Messages API and returns the response text,
+// retrying rate-limited, overloaded and server errors with exponential backoff
+func (p *Provider) complete(ctx context.Context, prompt string, tokens int) (string, error) {
+	body, err := json.Marshal(messagesRequest{
+		Model:       p.model,
+		MaxTokens:   tokens,
+		System:      systemPrompt,
+		Messages:    []message{{Role: "user", Content: prompt}},
+		Temperature: 0, // Consistent, deterministic responses
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode Anthropic request: %w", err)
+	}
+
+	return p.retryPolicy().Do(ctx, func() (string, error) {
+		return p.send(ctx, body)
+	})
+}
+
+// retryPolicy returns how failed requests are retried
+func (p *Provider) retryPolicy() llm.RetryPolicy {
+	return llm.RetryPolicy{Name: "Anthropic", MaxRetries: MaxRetries, InitialBackoff: p.initialBackoff, MaxBackoff: MaxBackoff}
+}
+
+// send performs a single Messages API request and returns the concatenated
+// text blocks. Retryable errors carry the retry-after delay when the API
+// provides one.
+func (p *Provider) send(ctx context.Context, body []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(p.baseURL, "/")+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create Anthropic request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", p.apiKey)
+	req.Header.Set("Anthropic-Version", APIVersion)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", &llm.RetryableError{Err: fmt.Errorf("anthropic API request failed: %w", err)}
+	}
+	defer resp.Body.Close()
+	p.reportQuota(resp.Header)
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Anthropic response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr apiError
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Message != "" {
+			message = apiErr.Error.Message
+		}
+		err := fmt.Errorf("anthropic API returned %s: %s", resp.Status, message)
+
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests, apiErr.Error.Type == "rate_limit_error",
+			resp.StatusCode == 529, apiErr.Error.Type == "overloaded_error":
+			return "", &llm.RetryableError{Err: err, RateLimited: true, RetryAfter: llm.RetryAfter(resp.Header)}
+		case resp.StatusCode >= 500:
+			return "", &llm.RetryableError{Err: err, RetryAfter: llm.RetryAfter(resp.Header)}
+		default:
+			return "", err
+		}
+	}
+
+	var messages messagesResponse
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return "", fmt.Errorf("%w: %v", llm.ErrInvalidJSON, err)
+	}
+	llm.ReportUsage(ctx, llm.Usage{PromptTokens: messages.Usage.InputTokens, CompletionTokens: messages.Usage.OutputTokens})
+	if messages.StopReason == "max_tokens" {
+		return "", fmt.Errorf("%w: response was truncated", llm.ErrInvalidJSON)
+	}
+
+	var text strings.Builder
+	for _, block := range messages.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	if text.Len() == 0 {
+		return "", fmt.Errorf("no response from Anthropic")
+	}
+
+	return text.String(), nil
+}
+
+// SetQuotaObserver registers a callback for the rate-limit headers of each response
+func (p *Provider) SetQuotaObserver(observe func(llm.Quota)) {
+	p.observeQuota = observe
+}
+
+// reportQuota passes the anthropic-ratelimit-* headers to the quota observer
+func (p *Provider) reportQuota(header http.Header) {
+	if p.observeQuota == nil || header.Get("Anthropic-Ratelimit-Requests-Remaining") == "" {
+		return
+	}
+
+	p.observeQuota(llm.Quota{
+		RemainingRequests: llm.HeaderInt(header, "Anthropic-Ratelimit-Requests-Remaining"),
+		RemainingTokens:   llm.HeaderInt(header, "Anthropic-Ratelimit-Tokens-Remaining"),
+		RequestsReset:     untilHeader(header, "Anthropic-Ratelimit-Requests-Reset"),
+		TokensReset:       untilHeader(header, "Anthropic-Ratelimit-Tokens-Reset"),
+	})
+}
+
+// untilHeader parses an RFC 3339 reset time header into the time remaining until it
+func untilHeader(header http.Header, name string) time.Duration {
+	reset, err := time.Parse(time.RFC3339, header.Get(name))
+	if err != nil {
+		return 0
+	}
+	return max(time.Until(reset), 0)
+}
@@ -0,0 +1,223 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/llm"
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+// newTestProvider returns a provider pointed at a stand-in Messages API
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *Provider {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	p, err := NewProvider("test-key", "claude-test", 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	p.baseURL = server.URL
+	p.initialBackoff = time.Millisecond
+	return p
+}
+
+// writeMessage responds with a message whose single text block is text
+func writeMessage(t *testing.T, w http.ResponseWriter, text string) {
+	t.Helper()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"content":     []map[string]any{{"type": "text", "text": text}},
+		"stop_reason": "end_turn",
+		"usage":       map[string]any{"input_tokens": 300, "output_tokens": 40},
+	}); err != nil {
+		t.Errorf("encode message: %v", err)
+	}
+}
+
+// writeError responds with an API error of the given status and type
+func writeError(w http.ResponseWriter, status int, errType string) {
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"type":"error","error":{"type":%q,"message":"try again later"}}`, errType)
+}
+
+const safeVerdict = `{"classification":"Safe","explanation":"x","risk_score":1,"suggested_action":"Allow"}`
+
+func TestNewProviderRequiresAPIKey(t *testing.T) {
+	if _, err := NewProvider("", "", time.Second); err == nil {
+		t.Fatal("expected an error without an API key")
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("path = %s, want /v1/messages", r.URL.Path)
+		}
+		if got := r.Header.Get("X-Api-Key"); got != "test-key" {
+			t.Errorf("X-Api-Key = %q", got)
+		}
+		if got := r.Header.Get("Anthropic-Version"); got != APIVersion {
+			t.Errorf("Anthropic-Version = %q, want %s", got, APIVersion)
+		}
+
+		var req messagesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if req.Model != "claude-test" {
+			t.Errorf("model = %q, want claude-test", req.Model)
+		}
+		if len(req.Messages) != 1 || !strings.Contains(req.Messages[0].Content, "evil.example") {
+			t.Errorf("prompt does not mention the domain: %+v", req.Messages)
+		}
+
+		writeMessage(t, w, `{"classification":"Malicious","explanation":"Known C2","risk_score":9,"suggested_action":"Block"}`)
+	})
+
+	var usage llm.Usage
+	ctx := llm.WithUsageRecorder(t.Context(), func(u llm.Usage) { usage = u })
+
+	analysis, err := p.Analyze(ctx, storage.DNSQuery{Domain: "evil.example", ClientID: "10.0.0.5"}, nil)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if analysis.Classification != "Malicious" || analysis.RiskScore != 9 || analysis.Provider != "anthropic" {
+		t.Errorf("analysis = %+v", analysis)
+	}
+	if usage != (llm.Usage{PromptTokens: 300, CompletionTokens: 40}) {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestRetryableErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		errType string
+	}{
+		{"rate limited", http.StatusTooManyRequests, "rate_limit_error"},
+		{"overloaded", 529, "overloaded_error"},
+		{"server error", http.StatusInternalServerError, "api_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) <= 2 {
+					writeError(w, tt.status, tt.errType)
+					return
+				}
+				writeMessage(t, w, safeVerdict)
+			})
+
+			if _, err := p.Analyze(t.Context(), storage.DNSQuery{Domain: "example.com"}, nil); err != nil {
+				t.Fatalf("Analyze: %v", err)
+			}
+			if got := calls.Load(); got != 3 {
+				t.Errorf("calls = %d, want 3", got)
+			}
+		})
+	}
+}
+
+func TestRetryAfterHonored(t *testing.T) {
+	var calls atomic.Int32
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0.2")
+			writeError(w, http.StatusTooManyRequests, "rate_limit_error")
+			return
+		}
+		writeMessage(t, w, safeVerdict)
+	})
+
+	start := time.Now()
+	if _, err := p.Analyze(t.Context(), storage.DNSQuery{Domain: "example.com"}, nil); err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("retried after %s, before the server's retry-after of 200ms", elapsed)
+	}
+}
+
+func TestLongRetryAfterReturned(t *testing.T) {
+	var calls atomic.Int32
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "120")
+		writeError(w, http.StatusTooManyRequests, "rate_limit_error")
+	})
+
+	_, err := p.Analyze(t.Context(), storage.DNSQuery{Domain: "example.com"}, nil)
+	var limitErr *llm.RateLimitError
+	if !errors.As(err, &limitErr) || limitErr.RetryAfter != 2*time.Minute {
+		t.Errorf("err = %#v, want a RateLimitError with the server's retry-after", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("calls = %d, want 1 (the wait is left to the caller)", got)
+	}
+}
+
+func TestOverloadedExhausted(t *testing.T) {
+	var calls atomic.Int32
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		writeError(w, 529, "overloaded_error")
+	})
+
+	_, err := p.Analyze(t.Context(), storage.DNSQuery{Domain: "example.com"}, nil)
+	if !errors.Is(err, llm.ErrRateLimited) {
+		t.Errorf("err = %v, want ErrRateLimited", err)
+	}
+	if got := calls.Load(); got != MaxRetries+1 {
+		t.Errorf("calls = %d, want %d", got, MaxRetries+1)
+	}
+}
+
+func TestNonRetryableError(t *testing.T) {
+	var calls atomic.Int32
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		writeError(w, http.StatusUnauthorized, "authentication_error")
+	})
+
+	_, err := p.Analyze(t.Context(), storage.DNSQuery{Domain: "example.com"}, nil)
+	if err == nil || errors.Is(err, llm.ErrRateLimited) {
+		t.Errorf("err = %v, want a non-rate-limit error", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("calls = %d, want 1 (no retries)", got)
+	}
+}
+
+func TestQuotaHeaders(t *testing.T) {
+	reset := time.Now().Add(time.Minute).UTC().Format(time.RFC3339)
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Anthropic-Ratelimit-Requests-Remaining", "4")
+		w.Header().Set("Anthropic-Ratelimit-Tokens-Remaining", "1500")
+		w.Header().Set("Anthropic-Ratelimit-Requests-Reset", reset)
+		writeMessage(t, w, safeVerdict)
+	})
+
+	var quota llm.Quota
+	p.SetQuotaObserver(func(q llm.Quota) { quota = q })
+
+	if _, err := p.Analyze(t.Context(), storage.DNSQuery{Domain: "example.com"}, nil); err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if quota.RemainingRequests != 4 || quota.RemainingTokens != 1500 || quota.RequestsReset <= 0 || quota.TokensReset != 0 {
+		t.Errorf("quota = %+v", quota)
+	}
+}
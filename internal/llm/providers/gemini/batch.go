@@ -42,7 +42,10 @@ func (p *Provider) AnalyzeBatch(ctx context.Context, queries []storage.DNSQuery,
 	model.Temperature = &temp
 
 	// Build the batch prompt
-	prompt := llm.BuildBatchPrompt(queries, whoisData)
+	prompt, err := llm.BuildBatchPrompt(p.Name(), queries, whoisData)
+	if err != nil {
+		return nil, err
+	}
 
 	log.Printf("🚀 [Gemini] Analyzing batch of %d domains in single request", len(queries))
 
@@ -52,7 +55,7 @@ func (p *Provider) AnalyzeBatch(ctx context.Context, queries []storage.DNSQuery,
 
 	for attempt := 0; attempt <= MaxRetries; attempt++ {
 		// Generate content
-		resp, err = model.GenerateContent(analyzeCtx, genai.Text(prompt))
+		resp, err = model.GenerateContent(analyzeCtx, genai.Text(prompt.Text))
 
 		// Success - break out of retry loop
 		if err == nil {
@@ -99,6 +102,8 @@ func (p *Provider) AnalyzeBatch(ctx context.Context, queries []storage.DNSQuery,
 		return nil, fmt.Errorf("gemini batch API request failed: %w", err)
 	}
 
+	reportUsage(analyzeCtx, resp)
+
 	// Extract response text
 	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
 		return nil, fmt.Errorf("no response from Gemini for batch")
@@ -123,45 +128,10 @@ func (p *Provider) AnalyzeBatch(ctx context.Context, queries []storage.DNSQuery,
 		return nil, fmt.Errorf("%w: %v", llm.ErrInvalidJSON, err)
 	}
 
-	// Validate we got responses for all queries
-	if len(batchResponses) != len(queries) {
-		log.Printf("⚠️  [Gemini] Expected %d responses, got %d", len(queries), len(batchResponses))
-		return nil, fmt.Errorf("batch response count mismatch: expected %d, got %d",
-			len(queries), len(batchResponses))
-	}
-
-	// Convert batch responses to Analysis objects
-	analyses := make([]*llm.Analysis, len(queries))
-	for i, batchResp := range batchResponses {
-		// Validate the response
-		if err := batchResp.Validate(); err != nil {
-			log.Printf("⚠️  [Gemini] Batch response[%d] validation failed: %v", i, err)
-			return nil, fmt.Errorf("batch response[%d] validation failed: %w", i, err)
-		}
-
-		// Ensure domain matches (responses should be in order)
-		if batchResp.Domain != queries[i].Domain {
-			log.Printf("⚠️  [Gemini] Domain mismatch at index %d: expected %s, got %s",
-				i, queries[i].Domain, batchResp.Domain)
-			return nil, fmt.Errorf("domain mismatch at index %d", i)
-		}
-
-		// Build Analysis object
-		analyses[i] = &llm.Analysis{
-			Domain:          queries[i].Domain,
-			ClientID:        queries[i].ClientID,
-			ClientName:      queries[i].ClientName,
-			Classification:  batchResp.Classification,
-			Explanation:     batchResp.Explanation,
-			RiskScore:       batchResp.RiskScore,
-			SuggestedAction: batchResp.SuggestedAction,
-			AnalyzedAt:      time.Now(),
-			Provider:        p.Name(),
-			QueryType:       queries[i].QueryType,
-		}
-
-		log.Printf("  [%d/%d] %s -> %s (risk: %d/10)",
-			i+1, len(queries), analyses[i].Domain, analyses[i].Classification, analyses[i].RiskScore)
+	analyses, err := llm.BatchAnalyses(p.Name(), prompt.Version, queries, batchResponses)
+	if err != nil {
+		log.Printf("⚠️  [Gemini] %v", err)
+		return nil, err
 	}
 
 	log.Printf("✅ [Gemini] Batch analysis complete: %d domains analyzed in single request", len(analyses))
@@ -107,7 +107,10 @@ func (p *Provider) Analyze(ctx context.Context, query storage.DNSQuery, whois *s
 	model.Temperature = &temp
 
 	// Build the prompt
-	prompt := llm.BuildPrompt(query, whois)
+	prompt, err := llm.BuildPrompt(p.Name(), query, whois)
+	if err != nil {
+		return nil, err
+	}
 
 	log.Printf("[Gemini] Analyzing domain: %s (client: %s)", query.Domain, query.ClientID)
 
@@ -117,7 +120,7 @@ func (p *Provider) Analyze(ctx context.Context, query storage.DNSQuery, whois *s
 
 	for attempt := 0; attempt <= MaxRetries; attempt++ {
 		// Generate content
-		resp, err = model.GenerateContent(analyzeCtx, genai.Text(prompt))
+		resp, err = model.GenerateContent(analyzeCtx, genai.Text(prompt.Text))
 
 		// Success - break out of retry loop
 		if err == nil {
@@ -164,6 +167,8 @@ func (p *Provider) Analyze(ctx context.Context, query storage.DNSQuery, whois *s
 		return nil, fmt.Errorf("gemini API request failed: %w", err)
 	}
 
+	reportUsage(analyzeCtx, resp)
+
 	// Extract response text
 	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
 		return nil, fmt.Errorf("no response from Gemini")
@@ -205,6 +210,7 @@ func (p *Provider) Analyze(ctx context.Context, query storage.DNSQuery, whois *s
 		AnalyzedAt:      time.Now(),
 		Provider:        p.Name(),
 		QueryType:       query.QueryType,
+		PromptVersion:   prompt.Version,
 	}
 
 	log.Printf("[Gemini] Analysis complete: %s -> %s (risk: %d/10, action: %s)",
@@ -212,3 +218,14 @@ func (p *Provider) Analyze(ctx context.Context, query storage.DNSQuery, whois *s
 
 	return analysis, nil
 }
+
+// reportUsage reports the token counts in a response's usage metadata
+func reportUsage(ctx context.Context, resp *genai.GenerateContentResponse) {
+	if resp.UsageMetadata == nil {
+		return
+	}
+	llm.ReportUsage(ctx, llm.Usage{
+		PromptTokens:     int(resp.UsageMetadata.PromptTokenCount),
+		CompletionTokens: int(resp.UsageMetadata.CandidatesTokenCount),
+	})
+}
@@ -0,0 +1,55 @@
+package ollama
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/eiladin/guardian-log/internal/llm"
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+// AnalyzeBatch performs batch LLM analysis on multiple DNS queries using Ollama
+func (p *Provider) AnalyzeBatch(ctx context.Context, queries []storage.DNSQuery, whoisData map[string]*storage.WHOISData) ([]*llm.Analysis, error) {
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("no queries to analyze")
+	}
+
+	// Create context with timeout (longer for batches)
+	analyzeCtx, cancel := context.WithTimeout(ctx, p.timeout*2)
+	defer cancel()
+
+	log.Printf("🚀 [Ollama] Analyzing batch of %d domains in single request", len(queries))
+
+	prompt, err := llm.BuildBatchPrompt(p.Name(), queries, whoisData)
+	if err != nil {
+		return nil, err
+	}
+
+	// JSON mode favors a top-level object, so ask for the array to be wrapped
+	responseText, err := p.chat(analyzeCtx, prompt.Text+
+		"Wrap the array in an object: {\"results\": [...]}, one entry per domain in the same order.\n")
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("📥 [Ollama] Received batch response (%d bytes)", len(responseText))
+
+	// Parse JSON array response
+	batchResponses, err := llm.ParseBatchResponse(responseText)
+	if err != nil {
+		log.Printf("❌ [Ollama] Failed to parse batch JSON: %v", err)
+		log.Printf("Response was: %s", responseText)
+		return nil, fmt.Errorf("%w: %v", llm.ErrInvalidJSON, err)
+	}
+
+	analyses, err := llm.BatchAnalyses(p.Name(), prompt.Version, queries, batchResponses)
+	if err != nil {
+		log.Printf("⚠️  [Ollama] %v", err)
+		return nil, err
+	}
+
+	log.Printf("✅ [Ollama] Batch analysis complete: %d domains analyzed in single request", len(analyses))
+
+	return analyses, nil
+}
@@ -0,0 +1,306 @@
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/llm"
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+const (
+	// DefaultURL is the address of a local Ollama server
+	DefaultURL = "http://localhost:11434"
+
+	// DefaultContextWindow is the context size (num_ctx) requested for each call
+	DefaultContextWindow = 8192
+
+	// DefaultKeepAlive is how long Ollama keeps the model loaded between calls
+	DefaultKeepAlive = "5m"
+
+	// MaxRetries is the maximum number of retry attempts when the server is busy
+	MaxRetries = 3
+
+	// InitialBackoff is the initial backoff duration for retries
+	InitialBackoff = 1 * time.Second
+
+	// MaxBackoff is the maximum backoff duration
+	MaxBackoff = 30 * time.Second
+)
+
+// ErrModelNotFound is returned when the configured model has not been pulled
+var ErrModelNotFound = errors.New("ollama model not found")
+
+// Provider implements the LLM Provider interface for a local Ollama server
+type Provider struct {
+	baseURL       string
+	model         string
+	timeout       time.Duration
+	contextWindow int
+	keepAlive     string
+	client        *http.Client
+
+	initialBackoff time.Duration
+}
+
+// NewProvider creates a new Ollama provider
+func NewProvider(baseURL, model string, timeout time.Duration) (*Provider, error) {
+	if baseURL == "" {
+		baseURL = DefaultURL
+	}
+	if model == "" {
+		return nil, fmt.Errorf("ollama model is required")
+	}
+
+	return &Provider{
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+		model:          model,
+		timeout:        timeout,
+		contextWindow:  DefaultContextWindow,
+		keepAlive:      DefaultKeepAlive,
+		client:         &http.Client{},
+		initialBackoff: InitialBackoff,
+	}, nil
+}
+
+// SetContextWindow sets the context size (num_ctx) in tokens; larger batches need a larger window
+func (p *Provider) SetContextWindow(tokens int) {
+	if tokens > 0 {
+		p.contextWindow = tokens
+	}
+}
+
+// SetKeepAlive sets how long the model stays loaded after a call (e.g. "5m", "-1" for forever)
+func (p *Provider) SetKeepAlive(keepAlive string) {
+	if keepAlive != "" {
+		p.keepAlive = keepAlive
+	}
+}
+
+// Name returns the provider name
+func (p *Provider) Name() string {
+	return "ollama"
+}
+
+// SupportsBatch returns true indicating Ollama supports batch analysis
+func (p *Provider) SupportsBatch() bool {
+	return true
+}
+
+// chatRequest is the /api/chat request body
+type chatRequest struct {
+	Model     string         `json:"model"`
+	Messages  []chatMessage  `json:"messages"`
+	Format    string         `json:"format"`
+	Stream    bool           `json:"stream"`
+	KeepAlive any            `json:"keep_alive,omitempty"`
+	Options   map[string]any `json:"options"`
+}
+
+// chatMessage is a single message in a chat
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// chatResponse is the subset of the /api/chat response we use
+type chatResponse struct {
+	Message    chatMessage `json:"message"`
+	Done       bool        `json:"done"`
+	DoneReason string      `json:"done_reason"`
+
+	PromptEvalCount int `json:"prompt_eval_count"` // Prompt tokens
+	EvalCount       int `json:"eval_count"`        // Completion tokens
+}
+
+// apiError is the error body returned by Ollama
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// CheckModel verifies that the server is reachable and the model has been pulled,
+// so misconfiguration is reported at startup rather than on the first analysis
+func (p *Provider) CheckModel(ctx context.Context) error {
+	body, err := json.Marshal(map[string]string{"model": p.model})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/api/show", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create Ollama request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cannot reach Ollama at %s: %w", p.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %q is not available on %s, run `ollama pull %s`", ErrModelNotFound, p.model, p.baseURL, p.model)
+	default:
+		data, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("ollama returned %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+}
+
+// Analyze performs LLM analysis on a DNS query using Ollama
+func (p *Provider) Analyze(ctx context.Context, query storage.DNSQuery, whois *storage.WHOISData) (*llm.Analysis, error) {
+	// Create context with timeout
+	analyzeCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	log.Printf("[Ollama] Analyzing domain: %s (client: %s)", query.Domain, query.ClientID)
+
+	prompt, err := llm.BuildPrompt(p.Name(), query, whois)
+	if err != nil {
+		return nil, err
+	}
+
+	responseText, err := p.chat(analyzeCtx, prompt.Text)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[Ollama] Raw response: %s", responseText)
+
+	// Parse JSON response
+	var llmResp llm.LLMResponse
+	jsonText, err := llm.ExtractJSON(responseText)
+	if err == nil {
+		err = json.Unmarshal([]byte(jsonText), &llmResp)
+	}
+	if err != nil {
+		log.Printf("[Ollama] Failed to parse JSON: %v", err)
+		return nil, fmt.Errorf("%w: %v", llm.ErrInvalidJSON, err)
+	}
+
+	// Validate response
+	if err := llmResp.Validate(); err != nil {
+		log.Printf("[Ollama] Response validation failed: %v", err)
+		return nil, err
+	}
+
+	// Build Analysis result
+	analysis := &llm.Analysis{
+		Domain:          query.Domain,
+		ClientID:        query.ClientID,
+		ClientName:      query.ClientName,
+		Classification:  llmResp.Classification,
+		Explanation:     llmResp.Explanation,
+		RiskScore:       llmResp.RiskScore,
+		SuggestedAction: llmResp.SuggestedAction,
+		AnalyzedAt:      time.Now(),
+		Provider:        p.Name(),
+		QueryType:       query.QueryType,
+		PromptVersion:   prompt.Version,
+	}
+
+	log.Printf("[Ollama] Analysis complete: %s -> %s (risk: %d/10, action: %s)",
+		query.Domain, analysis.Classification, analysis.RiskScore, analysis.SuggestedAction)
+
+	return analysis, nil
+}
+
+// chat sends a prompt to /api/chat in JSON mode and returns the message content,
+// retrying with exponential backoff while the server is busy
+func (p *Provider) chat(ctx context.Context, prompt string) (string, error) {
+	request := chatRequest{
+		Model:    p.model,
+		Messages: []chatMessage{{Role: "user", Content: prompt}},
+		Format:   "json",
+		Stream:   false,
+		Options: map[string]any{
+			"temperature": 0, // Consistent, deterministic responses
+			"num_ctx":     p.contextWindow,
+		},
+	}
+	if p.keepAlive != "" {
+		// Ollama accepts a duration string or a number of seconds (-1 keeps the model loaded)
+		var seconds json.Number
+		if json.Unmarshal([]byte(p.keepAlive), &seconds) == nil {
+			request.KeepAlive = seconds
+		} else {
+			request.KeepAlive = p.keepAlive
+		}
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode Ollama request: %w", err)
+	}
+
+	return p.retryPolicy().Do(ctx, func() (string, error) {
+		return p.send(ctx, body)
+	})
+}
+
+// retryPolicy returns how busy-server responses are retried
+func (p *Provider) retryPolicy() llm.RetryPolicy {
+	return llm.RetryPolicy{Name: "Ollama", MaxRetries: MaxRetries, InitialBackoff: p.initialBackoff, MaxBackoff: MaxBackoff}
+}
+
+// send performs a single /api/chat request. A busy server returns a retryable error.
+func (p *Provider) send(ctx context.Context, body []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/api/chat", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create Ollama request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("ollama request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Ollama response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr apiError
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error != "" {
+			message = apiErr.Error
+		}
+
+		switch resp.StatusCode {
+		case http.StatusNotFound:
+			return "", fmt.Errorf("%w: %s (run `ollama pull %s`)", ErrModelNotFound, message, p.model)
+		case http.StatusServiceUnavailable, http.StatusTooManyRequests:
+			// Request queue is full
+			return "", &llm.RetryableError{Err: fmt.Errorf("ollama server busy: %s", message), RateLimited: true, RetryAfter: llm.RetryAfter(resp.Header)}
+		default:
+			return "", fmt.Errorf("ollama returned %s: %s", resp.Status, message)
+		}
+	}
+
+	var chat chatResponse
+	if err := json.Unmarshal(data, &chat); err != nil {
+		return "", fmt.Errorf("%w: %v", llm.ErrInvalidJSON, err)
+	}
+	llm.ReportUsage(ctx, llm.Usage{PromptTokens: chat.PromptEvalCount, CompletionTokens: chat.EvalCount})
+	if chat.DoneReason == "length" {
+		return "", fmt.Errorf("%w: response was truncated (increase OLLAMA_NUM_CTX)", llm.ErrInvalidJSON)
+	}
+	if strings.TrimSpace(chat.Message.Content) == "" {
+		return "", fmt.Errorf("no response from Ollama")
+	}
+
+	return chat.Message.Content, nil
+}
@@ -0,0 +1,215 @@
+package ollama
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/llm"
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+// newTestProvider returns a provider pointed at a stand-in Ollama server
+func newTestProvider(t *testing.T, handler http.HandlerFunc) *Provider {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	p, err := NewProvider(server.URL, "llama-test", 5*time.Second)
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	p.initialBackoff = time.Millisecond
+	return p
+}
+
+// writeChat responds with a finished chat whose message content is content
+func writeChat(t *testing.T, w http.ResponseWriter, content, doneReason string) {
+	t.Helper()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"message":           map[string]any{"role": "assistant", "content": content},
+		"done":              true,
+		"done_reason":       doneReason,
+		"prompt_eval_count": 250,
+		"eval_count":        30,
+	}); err != nil {
+		t.Errorf("encode chat: %v", err)
+	}
+}
+
+const safeVerdict = `{"classification":"Safe","explanation":"x","risk_score":1,"suggested_action":"Allow"}`
+
+func TestNewProviderRequiresModel(t *testing.T) {
+	if _, err := NewProvider("", "", time.Second); err == nil {
+		t.Fatal("expected an error without a model")
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("path = %s, want /api/chat", r.URL.Path)
+		}
+
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if req.Model != "llama-test" || req.Format != "json" || req.Stream {
+			t.Errorf("request = %+v", req)
+		}
+		if len(req.Messages) != 1 || !strings.Contains(req.Messages[0].Content, "evil.example") {
+			t.Errorf("prompt does not mention the domain: %+v", req.Messages)
+		}
+
+		writeChat(t, w, `{"classification":"Malicious","explanation":"Known C2","risk_score":9,"suggested_action":"Block"}`, "stop")
+	})
+
+	var usage llm.Usage
+	ctx := llm.WithUsageRecorder(t.Context(), func(u llm.Usage) { usage = u })
+
+	analysis, err := p.Analyze(ctx, storage.DNSQuery{Domain: "evil.example", ClientID: "10.0.0.5"}, nil)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if analysis.Classification != "Malicious" || analysis.RiskScore != 9 || analysis.Provider != "ollama" {
+		t.Errorf("analysis = %+v", analysis)
+	}
+	if usage != (llm.Usage{PromptTokens: 250, CompletionTokens: 30}) {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestAnalyzeBatch(t *testing.T) {
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		writeChat(t, w, `{"results":[`+
+			`{"domain":"a.example","classification":"Safe","explanation":"x","risk_score":1,"suggested_action":"Allow"},`+
+			`{"domain":"b.example","classification":"Suspicious","explanation":"y","risk_score":6,"suggested_action":"Investigate"}]}`, "stop")
+	})
+
+	queries := []storage.DNSQuery{{Domain: "a.example", ClientID: "c1"}, {Domain: "b.example", ClientID: "c2"}}
+	analyses, err := p.AnalyzeBatch(t.Context(), queries, nil)
+	if err != nil {
+		t.Fatalf("AnalyzeBatch: %v", err)
+	}
+	if len(analyses) != 2 || analyses[1].Classification != "Suspicious" || analyses[1].ClientID != "c2" {
+		t.Errorf("analyses = %+v", analyses)
+	}
+}
+
+func TestTruncatedResponse(t *testing.T) {
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		writeChat(t, w, `{"classification":"Sa`, "length")
+	})
+
+	_, err := p.Analyze(t.Context(), storage.DNSQuery{Domain: "example.com"}, nil)
+	if !errors.Is(err, llm.ErrInvalidJSON) || !strings.Contains(err.Error(), "OLLAMA_NUM_CTX") {
+		t.Errorf("err = %v, want ErrInvalidJSON suggesting a larger context", err)
+	}
+}
+
+func TestKeepAliveEncoding(t *testing.T) {
+	tests := []struct {
+		keepAlive string
+		want      string
+	}{
+		{"-1", `-1`},
+		{"300", `300`},
+		{"10m", `"10m"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.keepAlive, func(t *testing.T) {
+			p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				var req map[string]json.RawMessage
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					t.Fatalf("decode request: %v", err)
+				}
+				if got := string(req["keep_alive"]); got != tt.want {
+					t.Errorf("keep_alive = %s, want %s", got, tt.want)
+				}
+				writeChat(t, w, safeVerdict, "stop")
+			})
+			p.SetKeepAlive(tt.keepAlive)
+
+			if _, err := p.Analyze(t.Context(), storage.DNSQuery{Domain: "example.com"}, nil); err != nil {
+				t.Fatalf("Analyze: %v", err)
+			}
+		})
+	}
+}
+
+func TestServerBusyRetried(t *testing.T) {
+	var calls atomic.Int32
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":"server busy, please try again"}`))
+			return
+		}
+		writeChat(t, w, safeVerdict, "stop")
+	})
+
+	if _, err := p.Analyze(t.Context(), storage.DNSQuery{Domain: "example.com"}, nil); err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("calls = %d, want 3", got)
+	}
+}
+
+func TestServerBusyExhausted(t *testing.T) {
+	var calls atomic.Int32
+	p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":"too many requests"}`))
+	})
+
+	_, err := p.Analyze(t.Context(), storage.DNSQuery{Domain: "example.com"}, nil)
+	if !errors.Is(err, llm.ErrRateLimited) {
+		t.Errorf("err = %v, want ErrRateLimited", err)
+	}
+	if got := calls.Load(); got != MaxRetries+1 {
+		t.Errorf("calls = %d, want %d", got, MaxRetries+1)
+	}
+}
+
+func TestCheckModel(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr error
+	}{
+		{"pulled", http.StatusOK, nil},
+		{"not pulled", http.StatusNotFound, ErrModelNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/show" {
+					t.Errorf("path = %s, want /api/show", r.URL.Path)
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"error":"model 'llama-test' not found"}`))
+			})
+
+			err := p.CheckModel(t.Context())
+			if tt.wantErr == nil && err != nil {
+				t.Errorf("CheckModel: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/llm"
+)
+
+// OutputMode is how a provider asks the server for JSON output
+type OutputMode string
+
+const (
+	// OutputJSONSchema uses strict structured outputs (response_format json_schema)
+	OutputJSONSchema OutputMode = "json_schema"
+
+	// OutputJSONObject uses JSON mode (response_format json_object)
+	OutputJSONObject OutputMode = "json_object"
+
+	// OutputPrompt sends no response_format and relies on the prompt alone
+	OutputPrompt OutputMode = "prompt"
+)
+
+// CompatibleProviderName is the name recorded on analyses from OpenAI-compatible servers
+const CompatibleProviderName = "openai-compatible"
+
+// probeMaxTokens leaves room for models that think or add prose before the JSON
+const probeMaxTokens = 512
+
+// jsonObjectFormat requests any JSON object
+var jsonObjectFormat = &responseFormat{Type: "json_object"}
+
+// probeFormat is a minimal schema used to detect structured-output support
+var probeFormat = &responseFormat{
+	Type: "json_schema",
+	JSONSchema: &jsonSchema{
+		Name:   "probe",
+		Strict: true,
+		Schema: map[string]any{
+			"type":                 "object",
+			"properties":           map[string]any{"ok": map[string]any{"type": "boolean"}},
+			"required":             []string{"ok"},
+			"additionalProperties": false,
+		},
+	},
+}
+
+// NewCompatibleProvider creates a provider for a server exposing an OpenAI-compatible
+// Chat Completions API (llama.cpp server, vLLM, LM Studio, ...). The API key is optional.
+// Output starts in prompt-only mode until DetectOutputMode finds something better.
+func NewCompatibleProvider(baseURL, apiKey, model string, timeout time.Duration) (*Provider, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("openai-compatible base URL is required")
+	}
+	if model == "" {
+		return nil, fmt.Errorf("openai-compatible model is required")
+	}
+
+	return &Provider{
+		name:           CompatibleProviderName,
+		apiKey:         apiKey,
+		model:          model,
+		timeout:        timeout,
+		baseURL:        baseURL,
+		client:         &http.Client{},
+		outputMode:     OutputPrompt,
+		initialBackoff: InitialBackoff,
+	}, nil
+}
+
+// ParseHeaders parses "Name=Value" entries into request headers
+func ParseHeaders(specs []string) (map[string]string, error) {
+	headers := make(map[string]string, len(specs))
+	for _, spec := range specs {
+		name, value, ok := strings.Cut(spec, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q (expected Name=Value)", spec)
+		}
+		headers[http.CanonicalHeaderKey(name)] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// SetHeaders sets extra headers sent with every request
+func (p *Provider) SetHeaders(headers map[string]string) {
+	p.headers = headers
+}
+
+// OutputMode returns how the provider currently requests JSON output
+func (p *Provider) OutputMode() OutputMode {
+	return p.outputMode
+}
+
+// DetectOutputMode probes the server with a tiny request to find the strictest
+// response_format it accepts: json_schema, then json_object, then prompt-only.
+// Transport, authentication and model errors are returned and leave the mode unchanged.
+func (p *Provider) DetectOutputMode(ctx context.Context) (OutputMode, error) {
+	probeCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	for _, candidate := range []struct {
+		mode   OutputMode
+		format *responseFormat
+	}{
+		{OutputJSONSchema, probeFormat},
+		{OutputJSONObject, jsonObjectFormat},
+	} {
+		supported, err := p.probe(probeCtx, candidate.format)
+		if err != nil {
+			return p.outputMode, err
+		}
+		if supported {
+			p.outputMode = candidate.mode
+			return p.outputMode, nil
+		}
+		log.Printf("[OpenAI] %s does not support response_format %s", p.baseURL, candidate.mode)
+	}
+
+	p.outputMode = OutputPrompt
+	return p.outputMode, nil
+}
+
+// probe reports whether the server accepts format and answers with JSON
+func (p *Provider) probe(ctx context.Context, format *responseFormat) (bool, error) {
+	body, err := json.Marshal(chatRequest{
+		Model:          p.model,
+		Messages:       []chatMessage{{Role: "user", Content: `Reply with the JSON object {"ok": true} and nothing else.`}},
+		Temperature:    0,
+		MaxTokens:      probeMaxTokens,
+		ResponseFormat: format,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to encode OpenAI request: %w", err)
+	}
+
+	content, err := p.send(ctx, body)
+	if err != nil {
+		var status *statusError
+		if errors.As(err, &status) && isUnsupportedStatus(status.code) {
+			return false, nil
+		}
+		if errors.Is(err, errTruncated) {
+			// Reasoning and verbose models can run out of tokens before answering,
+			// which says nothing about response_format support
+			return false, fmt.Errorf("response_format probe was inconclusive: %w", err)
+		}
+		if errors.Is(err, llm.ErrInvalidJSON) {
+			return false, nil // Malformed reply
+		}
+		return false, err
+	}
+
+	// Some servers silently ignore response_format; only trust it if the reply is JSON
+	_, err = llm.ExtractJSON(content)
+	return err == nil, nil
+}
+
+// isUnsupportedStatus reports whether a status means the request shape was rejected,
+// as opposed to an authentication, routing or availability problem
+func isUnsupportedStatus(code int) bool {
+	switch code {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+// formatFor returns the response_format to send for a request that would use
+// schema under strict structured outputs
+func (p *Provider) formatFor(schema *responseFormat) *responseFormat {
+	switch p.outputMode {
+	case OutputJSONSchema:
+		return schema
+	case OutputJSONObject:
+		return jsonObjectFormat
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,157 @@
+package openai
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+func TestNewCompatibleProviderRequiresURLAndModel(t *testing.T) {
+	if _, err := NewCompatibleProvider("", "", "model", time.Second); err == nil {
+		t.Error("expected an error without a base URL")
+	}
+	if _, err := NewCompatibleProvider("http://localhost:8000/v1", "", "", time.Second); err == nil {
+		t.Error("expected an error without a model")
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders([]string{"x-api-version=2", " X-Team = dns "})
+	if err != nil {
+		t.Fatalf("ParseHeaders: %v", err)
+	}
+	if headers["X-Api-Version"] != "2" || headers["X-Team"] != "dns" {
+		t.Errorf("headers = %v", headers)
+	}
+
+	if _, err := ParseHeaders([]string{"missing-value"}); err == nil {
+		t.Error("expected an error for an entry without '='")
+	}
+}
+
+func TestDetectOutputMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		accepted map[string]bool // response_format types the server accepts ("" = none sent)
+		want     OutputMode
+	}{
+		{"structured outputs", map[string]bool{"json_schema": true, "json_object": true, "": true}, OutputJSONSchema},
+		{"json mode only", map[string]bool{"json_object": true, "": true}, OutputJSONObject},
+		{"no response_format", map[string]bool{"": true}, OutputPrompt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t, compatibleProvider, func(w http.ResponseWriter, r *http.Request) {
+				var req chatRequest
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					t.Fatalf("decode request: %v", err)
+				}
+				format := ""
+				if req.ResponseFormat != nil {
+					format = req.ResponseFormat.Type
+				}
+				if !tt.accepted[format] {
+					w.WriteHeader(http.StatusBadRequest)
+					fmt.Fprint(w, `{"error":{"message":"response_format is not supported"}}`)
+					return
+				}
+				writeCompletion(t, w, `{"ok": true}`)
+			})
+
+			mode, err := p.DetectOutputMode(t.Context())
+			if err != nil {
+				t.Fatalf("DetectOutputMode: %v", err)
+			}
+			if mode != tt.want || p.OutputMode() != tt.want {
+				t.Errorf("mode = %s, want %s", mode, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetectOutputModeIgnoredFormat(t *testing.T) {
+	// A server that accepts response_format but ignores it should not be trusted
+	p := newTestProvider(t, compatibleProvider, func(w http.ResponseWriter, r *http.Request) {
+		writeCompletion(t, w, "Sure! Here you go: ok")
+	})
+
+	mode, err := p.DetectOutputMode(t.Context())
+	if err != nil {
+		t.Fatalf("DetectOutputMode: %v", err)
+	}
+	if mode != OutputPrompt {
+		t.Errorf("mode = %s, want %s", mode, OutputPrompt)
+	}
+}
+
+func TestDetectOutputModeUnauthorized(t *testing.T) {
+	p := newTestProvider(t, compatibleProvider, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":{"message":"invalid api key"}}`)
+	})
+
+	if _, err := p.DetectOutputMode(t.Context()); err == nil {
+		t.Error("expected an authentication error")
+	}
+	if p.OutputMode() != OutputPrompt {
+		t.Errorf("mode = %s, want unchanged %s", p.OutputMode(), OutputPrompt)
+	}
+}
+
+func TestCompatibleRequests(t *testing.T) {
+	p := newTestProvider(t, compatibleProvider, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "" {
+			t.Errorf("Authorization = %q, want none without an API key", got)
+		}
+		if got := r.Header.Get("X-Team"); got != "dns" {
+			t.Errorf("X-Team = %q, want dns", got)
+		}
+
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if req.ResponseFormat != nil {
+			t.Errorf("response_format = %+v, want none in prompt mode", req.ResponseFormat)
+		}
+
+		writeCompletion(t, w, "```json\n"+`[{"domain":"a.example","classification":"Safe","explanation":"CDN","risk_score":1,"suggested_action":"Allow"}]`+"\n```")
+	})
+	p.SetHeaders(map[string]string{"X-Team": "dns"})
+
+	analyses, err := p.AnalyzeBatch(t.Context(), []storage.DNSQuery{{ClientID: "c1", Domain: "a.example"}}, nil)
+	if err != nil {
+		t.Fatalf("AnalyzeBatch: %v", err)
+	}
+	if len(analyses) != 1 || analyses[0].Provider != CompatibleProviderName {
+		t.Errorf("unexpected analyses: %+v", analyses)
+	}
+}
+
+func TestDetectOutputModeTruncated(t *testing.T) {
+	// A reply cut off by the token limit says nothing about response_format support
+	p := newTestProvider(t, compatibleProvider, func(w http.ResponseWriter, r *http.Request) {
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if req.MaxTokens < 256 {
+			t.Errorf("max_tokens = %d, too small for reasoning models", req.MaxTokens)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"<think>The user wants"},"finish_reason":"length"}]}`)
+	})
+
+	if _, err := p.DetectOutputMode(t.Context()); err == nil {
+		t.Error("expected an inconclusive probe error")
+	}
+	if p.OutputMode() != OutputPrompt {
+		t.Errorf("mode = %s, want unchanged %s", p.OutputMode(), OutputPrompt)
+	}
+}
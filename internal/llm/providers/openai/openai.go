@@ -0,0 +1,369 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/llm"
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+const (
+	// DefaultBaseURL is the OpenAI API endpoint
+	DefaultBaseURL = "https://api.openai.com/v1"
+
+	// MaxRetries is the maximum number of retry attempts for rate-limited requests
+	MaxRetries = 3
+
+	// InitialBackoff is the initial backoff duration for retries
+	InitialBackoff = 1 * time.Second
+
+	// MaxBackoff is the maximum backoff duration
+	MaxBackoff = 30 * time.Second
+)
+
+// Provider implements the LLM Provider interface for the OpenAI Chat Completions API
+type Provider struct {
+	name       string
+	apiKey     string
+	model      string
+	timeout    time.Duration
+	baseURL    string
+	headers    map[string]string
+	client     *http.Client
+	outputMode OutputMode
+
+	observeQuota func(llm.Quota) // Receives the rate-limit headers of each response
+
+	initialBackoff time.Duration
+}
+
+// NewProvider creates a new OpenAI provider
+func NewProvider(apiKey, model string, timeout time.Duration) (*Provider, error) {
+	if apiKey == "" {
+		return nil, fmt.Errorf("openai API key is required")
+	}
+
+	if model == "" {
+		model = "gpt-4o-mini" // Default to the small, inexpensive model
+	}
+
+	return &Provider{
+		name:           "openai",
+		apiKey:         apiKey,
+		model:          model,
+		timeout:        timeout,
+		baseURL:        DefaultBaseURL,
+		client:         &http.Client{},
+		outputMode:     OutputJSONSchema,
+		initialBackoff: InitialBackoff,
+	}, nil
+}
+
+// Name returns the provider name
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// SupportsBatch returns true indicating OpenAI supports batch analysis
+func (p *Provider) SupportsBatch() bool {
+	return true
+}
+
+// chatRequest is the Chat Completions request body
+type chatRequest struct {
+	Model          string          `json:"model"`
+	Messages       []chatMessage   `json:"messages"`
+	Temperature    float64         `json:"temperature"`
+	MaxTokens      int             `json:"max_tokens,omitempty"`
+	ResponseFormat *responseFormat `json:"response_format,omitempty"`
+}
+
+// chatMessage is a single message in a chat completion
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// responseFormat requests JSON or schema-constrained output
+type responseFormat struct {
+	Type       string      `json:"type"` // json_object or json_schema
+	JSONSchema *jsonSchema `json:"json_schema,omitempty"`
+}
+
+// jsonSchema is a named structured-output schema
+type jsonSchema struct {
+	Name   string         `json:"name"`
+	Strict bool           `json:"strict"`
+	Schema map[string]any `json:"schema"`
+}
+
+// chatResponse is the subset of the Chat Completions response we use
+type chatResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+			Refusal string `json:"refusal"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+}
+
+// apiError is the error body returned by the API
+type apiError struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    string `json:"code"`
+	} `json:"error"`
+}
+
+// verdictSchema describes a single analysis verdict
+func verdictSchema(withDomain bool) map[string]any {
+	properties := map[string]any{
+		"classification":   map[string]any{"type": "string", "enum": []string{"Safe", "Suspicious", "Malicious"}},
+		"explanation":      map[string]any{"type": "string"},
+		"risk_score":       map[string]any{"type": "integer"},
+		"suggested_action": map[string]any{"type": "string", "enum": []string{"Allow", "Investigate", "Block"}},
+	}
+	required := []string{"classification", "explanation", "risk_score", "suggested_action"}
+	if withDomain {
+		properties["domain"] = map[string]any{"type": "string"}
+		required = append([]string{"domain"}, required...)
+	}
+
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"required":             required,
+		"additionalProperties": false,
+	}
+}
+
+// analysisFormat constrains single-domain responses to one verdict
+var analysisFormat = &responseFormat{
+	Type: "json_schema",
+	JSONSchema: &jsonSchema{
+		Name:   "dns_analysis",
+		Strict: true,
+		Schema: verdictSchema(false),
+	},
+}
+
+// batchFormat constrains batch responses to {"results": [verdict, ...]}, since
+// structured outputs must have an object at the top level
+var batchFormat = &responseFormat{
+	Type: "json_schema",
+	JSONSchema: &jsonSchema{
+		Name:   "dns_batch_analysis",
+		Strict: true,
+		Schema: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"results": map[string]any{"type": "array", "items": verdictSchema(true)},
+			},
+			"required":             []string{"results"},
+			"additionalProperties": false,
+		},
+	},
+}
+
+// Analyze performs LLM analysis on a DNS query using OpenAI with retry logic
+func (p *Provider) Analyze(ctx context.Context, query storage.DNSQuery, whois *storage.WHOISData) (*llm.Analysis, error) {
+	// Create context with timeout
+	analyzeCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	log.Printf("[OpenAI] Analyzing domain: %s (client: %s)", query.Domain, query.ClientID)
+
+	prompt, err := llm.BuildPrompt(p.Name(), query, whois)
+	if err != nil {
+		return nil, err
+	}
+
+	responseText, err := p.complete(analyzeCtx, prompt.Text, p.formatFor(analysisFormat))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("[OpenAI] Raw response: %s", responseText)
+
+	// Parse JSON response
+	var llmResp llm.LLMResponse
+	jsonText, err := llm.ExtractJSON(responseText)
+	if err == nil {
+		err = json.Unmarshal([]byte(jsonText), &llmResp)
+	}
+	if err != nil {
+		log.Printf("[OpenAI] Failed to parse JSON: %v", err)
+		return nil, fmt.Errorf("%w: %v", llm.ErrInvalidJSON, err)
+	}
+
+	// Validate response
+	if err := llmResp.Validate(); err != nil {
+		log.Printf("[OpenAI] Response validation failed: %v", err)
+		return nil, err
+	}
+
+	// Build Analysis result
+	analysis := &llm.Analysis{
+		Domain:          query.Domain,
+		ClientID:        query.ClientID,
+		ClientName:      query.ClientName,
+		Classification:  llmResp.Classification,
+		Explanation:     llmResp.Explanation,
+		RiskScore:       llmResp.RiskScore,
+		SuggestedAction: llmResp.SuggestedAction,
+		AnalyzedAt:      time.Now(),
+		Provider:        p.Name(),
+		QueryType:       query.QueryType,
+		PromptVersion:   prompt.Version,
+	}
+
+	log.Printf("[OpenAI] Analysis complete: %s -> %s (risk: %d/10, action: %s)",
+		query.Domain, analysis.Classification, analysis.RiskScore, analysis.SuggestedAction)
+
+	return analysis, nil
+}
+
+// complete sends a prompt to the Chat Completions API and returns the message
+// content, retrying rate-limited and server errors with exponential backoff
+func (p *Provider) complete(ctx context.Context, prompt string, format *responseFormat) (string, error) {
+	body, err := json.Marshal(chatRequest{
+		Model:          p.model,
+		Messages:       []chatMessage{{Role: "user", Content: prompt}},
+		Temperature:    0, // Consistent, deterministic responses
+		ResponseFormat: format,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode OpenAI request: %w", err)
+	}
+
+	return p.retryPolicy().Do(ctx, func() (string, error) {
+		return p.send(ctx, body)
+	})
+}
+
+// statusError is a non-200 response from the API
+type statusError struct {
+	code int
+	err  error
+}
+
+func (e *statusError) Error() string { return e.err.Error() }
+
+func (e *statusError) Unwrap() error { return e.err }
+
+// retryPolicy returns how failed requests are retried
+func (p *Provider) retryPolicy() llm.RetryPolicy {
+	return llm.RetryPolicy{Name: "OpenAI", MaxRetries: MaxRetries, InitialBackoff: p.initialBackoff, MaxBackoff: MaxBackoff}
+}
+
+// errTruncated is returned when the response hit the token limit
+var errTruncated = fmt.Errorf("%w: response was truncated", llm.ErrInvalidJSON)
+
+// send performs a single Chat Completions request. Retryable errors carry the
+// Retry-After delay when the API provides one.
+func (p *Provider) send(ctx context.Context, body []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(p.baseURL, "/")+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create OpenAI request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	for name, value := range p.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", &llm.RetryableError{Err: fmt.Errorf("openai API request failed: %w", err)}
+	}
+	defer resp.Body.Close()
+	p.reportQuota(resp.Header)
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read OpenAI response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr apiError
+		message := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Message != "" {
+			message = apiErr.Error.Message
+		}
+		var err error = &statusError{code: resp.StatusCode, err: fmt.Errorf("openai API returned %s: %s", resp.Status, message)}
+
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests && apiErr.Error.Code == "insufficient_quota":
+			return "", err // Billing problem; retrying will not help
+		case resp.StatusCode == http.StatusTooManyRequests:
+			return "", &llm.RetryableError{Err: err, RateLimited: true, RetryAfter: llm.RetryAfter(resp.Header)}
+		case resp.StatusCode >= 500:
+			return "", &llm.RetryableError{Err: err, RetryAfter: llm.RetryAfter(resp.Header)}
+		default:
+			return "", err
+		}
+	}
+
+	var chat chatResponse
+	if err := json.Unmarshal(data, &chat); err != nil {
+		return "", fmt.Errorf("%w: %v", llm.ErrInvalidJSON, err)
+	}
+	llm.ReportUsage(ctx, llm.Usage{PromptTokens: chat.Usage.PromptTokens, CompletionTokens: chat.Usage.CompletionTokens})
+	if len(chat.Choices) == 0 {
+		return "", fmt.Errorf("no response from OpenAI")
+	}
+
+	choice := chat.Choices[0]
+	if choice.Message.Refusal != "" {
+		return "", fmt.Errorf("openai refused the request: %s", choice.Message.Refusal)
+	}
+	if choice.FinishReason == "length" {
+		return "", errTruncated
+	}
+
+	return choice.Message.Content, nil
+}
+
+// SetQuotaObserver registers a callback for the rate-limit headers of each response
+func (p *Provider) SetQuotaObserver(observe func(llm.Quota)) {
+	p.observeQuota = observe
+}
+
+// reportQuota passes the x-ratelimit-* headers to the quota observer
+func (p *Provider) reportQuota(header http.Header) {
+	if p.observeQuota == nil || header.Get("X-Ratelimit-Remaining-Requests") == "" {
+		return
+	}
+
+	p.observeQuota(llm.Quota{
+		RemainingRequests: llm.HeaderInt(header, "X-Ratelimit-Remaining-Requests"),
+		RemainingTokens:   llm.HeaderInt(header, "X-Ratelimit-Remaining-Tokens"),
+		RequestsReset:     headerDuration(header, "X-Ratelimit-Reset-Requests"),
+		TokensReset:       headerDuration(header, "X-Ratelimit-Reset-Tokens"),
+	})
+}
+
+// headerDuration parses a duration header such as "6m0s" or "20ms", returning 0 if absent
+func headerDuration(header http.Header, name string) time.Duration {
+	duration, err := time.ParseDuration(header.Get(name))
+	if err != nil {
+		return 0
+	}
+	return duration
+}
@@ -0,0 +1,320 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/llm"
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+// newTestProvider returns a provider created by newProvider and pointed at a
+// stand-in Chat Completions API
+func newTestProvider(t *testing.T, newProvider func(url string) (*Provider, error), handler http.HandlerFunc) *Provider {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	p, err := newProvider(server.URL)
+	if err != nil {
+		t.Fatalf("creating provider: %v", err)
+	}
+	p.initialBackoff = time.Millisecond
+	return p
+}
+
+// openAIProvider creates an OpenAI provider that sends requests to url
+func openAIProvider(url string) (*Provider, error) {
+	p, err := NewProvider("test-key", "gpt-test", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	p.baseURL = url
+	return p, nil
+}
+
+// compatibleProvider creates an OpenAI-compatible provider for a local model at url
+func compatibleProvider(url string) (*Provider, error) {
+	return NewCompatibleProvider(url, "", "local-model", 5*time.Second)
+}
+
+// writeCompletion responds with a chat completion whose message content is content
+func writeCompletion(t *testing.T, w http.ResponseWriter, content string) {
+	t.Helper()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]any{
+		"choices": []map[string]any{{
+			"message":       map[string]any{"role": "assistant", "content": content},
+			"finish_reason": "stop",
+		}},
+	}); err != nil {
+		t.Errorf("encode completion: %v", err)
+	}
+}
+
+func TestNewProviderRequiresAPIKey(t *testing.T) {
+	if _, err := NewProvider("", "", time.Second); err == nil {
+		t.Fatal("expected an error without an API key")
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	p := newTestProvider(t, openAIProvider, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat/completions" {
+			t.Errorf("path = %s, want /chat/completions", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer test-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+
+		var req chatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Fatalf("decode request: %v", err)
+		}
+		if req.Model != "gpt-test" {
+			t.Errorf("model = %q, want gpt-test", req.Model)
+		}
+		if req.ResponseFormat == nil || req.ResponseFormat.Type != "json_schema" || !req.ResponseFormat.JSONSchema.Strict {
+			t.Errorf("response_format = %+v, want strict json_schema", req.ResponseFormat)
+		}
+		if len(req.Messages) != 1 || !strings.Contains(req.Messages[0].Content, "evil.example") {
+			t.Errorf("prompt does not mention the domain: %+v", req.Messages)
+		}
+
+		writeCompletion(t, w, `{"classification":"Malicious","explanation":"Known C2","risk_score":9,"suggested_action":"Block"}`)
+	})
+
+	analysis, err := p.Analyze(t.Context(), storage.DNSQuery{
+		ClientID:  "192.168.1.10",
+		Domain:    "evil.example",
+		QueryType: "A",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+
+	if analysis.Classification != "Malicious" || analysis.RiskScore != 9 || analysis.SuggestedAction != "Block" {
+		t.Errorf("unexpected analysis: %+v", analysis)
+	}
+	if analysis.Provider != "openai" || analysis.Domain != "evil.example" || analysis.ClientID != "192.168.1.10" {
+		t.Errorf("unexpected analysis metadata: %+v", analysis)
+	}
+}
+
+func TestAnalyzeInvalidResponse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr error
+	}{
+		{"not json", "I think this domain is fine", llm.ErrInvalidJSON},
+		{"bad classification", `{"classification":"Fine","explanation":"x","risk_score":2,"suggested_action":"Allow"}`, llm.ErrInvalidClassification},
+		{"bad risk score", `{"classification":"Safe","explanation":"x","risk_score":0,"suggested_action":"Allow"}`, llm.ErrInvalidRiskScore},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestProvider(t, openAIProvider, func(w http.ResponseWriter, r *http.Request) {
+				writeCompletion(t, w, tt.content)
+			})
+
+			_, err := p.Analyze(t.Context(), storage.DNSQuery{Domain: "example.com"}, nil)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAnalyzeBatch(t *testing.T) {
+	for _, envelope := range []bool{true, false} {
+		t.Run(fmt.Sprintf("envelope=%v", envelope), func(t *testing.T) {
+			p := newTestProvider(t, openAIProvider, func(w http.ResponseWriter, r *http.Request) {
+				var req chatRequest
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					t.Fatalf("decode request: %v", err)
+				}
+				if req.ResponseFormat == nil || req.ResponseFormat.JSONSchema.Name != "dns_batch_analysis" {
+					t.Errorf("response_format = %+v, want batch schema", req.ResponseFormat)
+				}
+
+				results := `[
+					{"domain":"a.example","classification":"Safe","explanation":"CDN","risk_score":1,"suggested_action":"Allow"},
+					{"domain":"b.example","classification":"Suspicious","explanation":"New domain","risk_score":6,"suggested_action":"Investigate"}
+				]`
+				if envelope {
+					results = `{"results":` + results + `}`
+				}
+				writeCompletion(t, w, results)
+			})
+
+			analyses, err := p.AnalyzeBatch(t.Context(), []storage.DNSQuery{
+				{ClientID: "c1", Domain: "a.example"},
+				{ClientID: "c2", Domain: "b.example"},
+			}, map[string]*storage.WHOISData{})
+			if err != nil {
+				t.Fatalf("AnalyzeBatch: %v", err)
+			}
+
+			if len(analyses) != 2 {
+				t.Fatalf("got %d analyses, want 2", len(analyses))
+			}
+			if analyses[1].Domain != "b.example" || analyses[1].ClientID != "c2" || analyses[1].Classification != "Suspicious" {
+				t.Errorf("unexpected analysis: %+v", analyses[1])
+			}
+		})
+	}
+}
+
+func TestAnalyzeBatchCountMismatch(t *testing.T) {
+	p := newTestProvider(t, openAIProvider, func(w http.ResponseWriter, r *http.Request) {
+		writeCompletion(t, w, `{"results":[{"domain":"a.example","classification":"Safe","explanation":"x","risk_score":1,"suggested_action":"Allow"}]}`)
+	})
+
+	_, err := p.AnalyzeBatch(t.Context(), []storage.DNSQuery{{Domain: "a.example"}, {Domain: "b.example"}}, nil)
+	if err == nil || !strings.Contains(err.Error(), "count mismatch") {
+		t.Errorf("err = %v, want count mismatch", err)
+	}
+}
+
+func TestRateLimitRetry(t *testing.T) {
+	var calls atomic.Int32
+	p := newTestProvider(t, openAIProvider, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"error":{"message":"Rate limit reached","type":"requests","code":"rate_limit_exceeded"}}`)
+			return
+		}
+		writeCompletion(t, w, `{"classification":"Safe","explanation":"x","risk_score":1,"suggested_action":"Allow"}`)
+	})
+
+	if _, err := p.Analyze(t.Context(), storage.DNSQuery{Domain: "example.com"}, nil); err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("calls = %d, want 3", got)
+	}
+}
+
+func TestRateLimitExhausted(t *testing.T) {
+	var calls atomic.Int32
+	p := newTestProvider(t, openAIProvider, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error":{"message":"Rate limit reached","code":"rate_limit_exceeded"}}`)
+	})
+
+	_, err := p.Analyze(t.Context(), storage.DNSQuery{Domain: "example.com"}, nil)
+	if !errors.Is(err, llm.ErrRateLimited) {
+		t.Errorf("err = %v, want ErrRateLimited", err)
+	}
+	var limitErr *llm.RateLimitError
+	if !errors.As(err, &limitErr) || limitErr.RetryAfter != 0 {
+		t.Errorf("err = %#v, want a RateLimitError without Retry-After", err)
+	}
+	if got := calls.Load(); got != MaxRetries+1 {
+		t.Errorf("calls = %d, want %d", got, MaxRetries+1)
+	}
+}
+
+func TestNonRetryableErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"insufficient quota", http.StatusTooManyRequests, `{"error":{"message":"You exceeded your current quota","code":"insufficient_quota"}}`},
+		{"unauthorized", http.StatusUnauthorized, `{"error":{"message":"Incorrect API key provided","code":"invalid_api_key"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			p := newTestProvider(t, openAIProvider, func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			})
+
+			_, err := p.Analyze(t.Context(), storage.DNSQuery{Domain: "example.com"}, nil)
+			if err == nil || errors.Is(err, llm.ErrRateLimited) {
+				t.Errorf("err = %v, want a non-rate-limit error", err)
+			}
+			if got := calls.Load(); got != 1 {
+				t.Errorf("calls = %d, want 1 (no retries)", got)
+			}
+		})
+	}
+}
+
+func TestServerErrorRetry(t *testing.T) {
+	var calls atomic.Int32
+	p := newTestProvider(t, openAIProvider, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeCompletion(t, w, `{"classification":"Safe","explanation":"x","risk_score":1,"suggested_action":"Allow"}`)
+	})
+
+	if _, err := p.Analyze(t.Context(), storage.DNSQuery{Domain: "example.com"}, nil); err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("calls = %d, want 2", got)
+	}
+}
+
+func TestQuotaHeaders(t *testing.T) {
+	p := newTestProvider(t, openAIProvider, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Ratelimit-Remaining-Requests", "0")
+		w.Header().Set("X-Ratelimit-Remaining-Tokens", "1500")
+		w.Header().Set("X-Ratelimit-Reset-Requests", "6m0s")
+		w.Header().Set("X-Ratelimit-Reset-Tokens", "20ms")
+		writeCompletion(t, w, `{"classification":"Safe","explanation":"x","risk_score":1,"suggested_action":"Allow"}`)
+	})
+
+	var quota llm.Quota
+	p.SetQuotaObserver(func(q llm.Quota) { quota = q })
+
+	if _, err := p.Analyze(t.Context(), storage.DNSQuery{Domain: "example.com"}, nil); err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+
+	want := llm.Quota{RemainingRequests: 0, RemainingTokens: 1500, RequestsReset: 6 * time.Minute, TokensReset: 20 * time.Millisecond}
+	if quota != want {
+		t.Errorf("quota = %+v, want %+v", quota, want)
+	}
+}
+
+func TestUsageReported(t *testing.T) {
+	p := newTestProvider(t, openAIProvider, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{
+			"choices": [{"message": {"role": "assistant", "content": "{\"classification\":\"Safe\",\"explanation\":\"x\",\"risk_score\":1,\"suggested_action\":\"Allow\"}"}, "finish_reason": "stop"}],
+			"usage": {"prompt_tokens": 412, "completion_tokens": 37}
+		}`)
+	})
+
+	var usage llm.Usage
+	ctx := llm.WithUsageRecorder(t.Context(), func(u llm.Usage) { usage = u })
+
+	if _, err := p.Analyze(ctx, storage.DNSQuery{Domain: "example.com"}, nil); err != nil {
+		t.Fatalf("Analyze: %v", err)
+	}
+
+	want := llm.Usage{PromptTokens: 412, CompletionTokens: 37}
+	if usage != want {
+		t.Errorf("usage = %+v, want %+v", usage, want)
+	}
+}
@@ -0,0 +1,282 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+const (
+	// outputTokensPerDomain is the estimated response size of one verdict
+	outputTokensPerDomain = 120
+
+	// charsPerToken is the rough number of characters per token for English prompts
+	charsPerToken = 4
+)
+
+// RateLimitError is a rate-limit error that carries how long the provider asked
+// us to wait. It matches ErrRateLimited with errors.Is.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%s (retry after %s)", ErrRateLimited, e.RetryAfter)
+	}
+	return ErrRateLimited.Error()
+}
+
+// Is makes errors.Is(err, ErrRateLimited) true for rate-limit errors
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+// Quota is a provider's remaining budget as reported in response headers.
+// Negative values are unknown.
+type Quota struct {
+	RemainingRequests int
+	RemainingTokens   int
+	RequestsReset     time.Duration // Time until the request budget is fully restored
+	TokensReset       time.Duration // Time until the token budget is fully restored
+}
+
+// QuotaReporter is implemented by providers that report the remaining quota of each response
+type QuotaReporter interface {
+	SetQuotaObserver(observe func(Quota))
+}
+
+// Budgeted is implemented by providers that know how many tokens their next request can use
+type Budgeted interface {
+	// AvailableTokens returns the tokens the next request can use, or -1 if unlimited
+	AvailableTokens() int
+}
+
+// RateLimits are a provider's request and token budgets. Zero means unlimited.
+type RateLimits struct {
+	RequestsPerMinute int
+	TokensPerMinute   int
+}
+
+// RateLimiter is a token-bucket limiter for requests and tokens per minute
+type RateLimiter struct {
+	mu           sync.Mutex
+	limits       RateLimits
+	requests     float64 // Available requests
+	tokens       float64 // Available tokens
+	updated      time.Time
+	blockedUntil time.Time // Set from Retry-After and exhausted quota headers
+}
+
+// NewRateLimiter creates a rate limiter that starts with full budgets
+func NewRateLimiter(limits RateLimits) *RateLimiter {
+	return &RateLimiter{
+		limits:   limits,
+		requests: float64(limits.RequestsPerMinute),
+		tokens:   float64(limits.TokensPerMinute),
+		updated:  time.Now(),
+	}
+}
+
+// EstimateTokens roughly estimates the number of tokens in a prompt
+func EstimateTokens(text string) int {
+	return (len(text) + charsPerToken - 1) / charsPerToken
+}
+
+// refill adds the budget accrued since the last update. Callers must hold mu.
+func (l *RateLimiter) refill(now time.Time) {
+	elapsed := now.Sub(l.updated).Minutes()
+	l.updated = now
+	if elapsed <= 0 {
+		return
+	}
+
+	if l.limits.RequestsPerMinute > 0 {
+		l.requests = math.Min(float64(l.limits.RequestsPerMinute), l.requests+elapsed*float64(l.limits.RequestsPerMinute))
+	}
+	if l.limits.TokensPerMinute > 0 {
+		l.tokens = math.Min(float64(l.limits.TokensPerMinute), l.tokens+elapsed*float64(l.limits.TokensPerMinute))
+	}
+}
+
+// delay returns how long until one request of the given size fits the budget.
+// Callers must hold mu.
+func (l *RateLimiter) delay(now time.Time, tokens int) time.Duration {
+	wait := l.blockedUntil.Sub(now)
+
+	if perMinute := float64(l.limits.RequestsPerMinute); perMinute > 0 && l.requests < 1 {
+		wait = max(wait, time.Duration((1-l.requests)/perMinute*float64(time.Minute)))
+	}
+	if perMinute := float64(l.limits.TokensPerMinute); perMinute > 0 {
+		// A request larger than the whole budget waits for a full bucket
+		need := math.Min(float64(tokens), perMinute)
+		if l.tokens < need {
+			wait = max(wait, time.Duration((need-l.tokens)/perMinute*float64(time.Minute)))
+		}
+	}
+
+	return max(wait, 0)
+}
+
+// Wait blocks until the budget allows one request of the given number of tokens
+// and spends it. If the wait would outlast the context's deadline, it returns a
+// RateLimitError immediately so the caller can retry later.
+func (l *RateLimiter) Wait(ctx context.Context, tokens int) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		l.refill(now)
+		wait := l.delay(now, tokens)
+		if wait == 0 {
+			if l.limits.RequestsPerMinute > 0 {
+				l.requests--
+			}
+			if l.limits.TokensPerMinute > 0 {
+				l.tokens -= float64(tokens)
+			}
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		if deadline, ok := ctx.Deadline(); ok && now.Add(wait).After(deadline) {
+			return &RateLimitError{RetryAfter: wait}
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return ErrTimeout
+		}
+	}
+}
+
+// Backoff empties the request budget after the provider rate limited us and
+// pauses all requests for d (the provider's Retry-After, if any)
+func (l *RateLimiter) Backoff(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(time.Now())
+	if l.limits.RequestsPerMinute > 0 {
+		l.requests = math.Min(l.requests, 0)
+	}
+
+	if until := time.Now().Add(d); until.After(l.blockedUntil) {
+		l.blockedUntil = until
+	}
+}
+
+// Observe reconciles the budget with the quota a provider reported
+func (l *RateLimiter) Observe(quota Quota) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.refill(now)
+
+	if quota.RemainingRequests >= 0 {
+		if l.limits.RequestsPerMinute > 0 {
+			l.requests = math.Min(l.requests, float64(quota.RemainingRequests))
+		}
+		if quota.RemainingRequests == 0 && now.Add(quota.RequestsReset).After(l.blockedUntil) {
+			l.blockedUntil = now.Add(quota.RequestsReset)
+		}
+	}
+	if quota.RemainingTokens >= 0 && l.limits.TokensPerMinute > 0 {
+		l.tokens = math.Min(l.tokens, float64(quota.RemainingTokens))
+	}
+}
+
+// AvailableTokens returns the tokens available when the next request is allowed,
+// or -1 if tokens are unlimited. Waiting on the request budget lets the token
+// budget refill, so request-bound providers get larger batches.
+func (l *RateLimiter) AvailableTokens() int {
+	if l.limits.TokensPerMinute <= 0 {
+		return -1
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.refill(now)
+
+	wait := l.delay(now, 0)
+	available := l.tokens + wait.Minutes()*float64(l.limits.TokensPerMinute)
+	return int(math.Min(available, float64(l.limits.TokensPerMinute)))
+}
+
+// LimitedProvider wraps a Provider so every request waits for its rate limiter
+type LimitedProvider struct {
+	Provider
+	limiter *RateLimiter
+}
+
+// NewLimitedProvider wraps a provider with a rate limiter. Providers that report
+// their remaining quota keep the limiter in sync with the server.
+func NewLimitedProvider(provider Provider, limiter *RateLimiter) *LimitedProvider {
+	if reporter, ok := provider.(QuotaReporter); ok {
+		reporter.SetQuotaObserver(limiter.Observe)
+	}
+	return &LimitedProvider{Provider: provider, limiter: limiter}
+}
+
+// Analyze waits for budget, then analyzes the query
+func (p *LimitedProvider) Analyze(ctx context.Context, query storage.DNSQuery, whois *storage.WHOISData) (*Analysis, error) {
+	prompt, err := BuildPrompt(p.Name(), query, whois)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.limiter.Wait(ctx, EstimateTokens(prompt.Text)+outputTokensPerDomain); err != nil {
+		return nil, err
+	}
+
+	analysis, err := p.Provider.Analyze(ctx, query, whois)
+	p.observe(err)
+	return analysis, err
+}
+
+// AnalyzeBatch waits for budget, then analyzes the batch
+func (p *LimitedProvider) AnalyzeBatch(ctx context.Context, queries []storage.DNSQuery, whoisData map[string]*storage.WHOISData) ([]*Analysis, error) {
+	prompt, err := BuildBatchPrompt(p.Name(), queries, whoisData)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.limiter.Wait(ctx, EstimateTokens(prompt.Text)+outputTokensPerDomain*len(queries)); err != nil {
+		return nil, err
+	}
+
+	analyses, err := p.Provider.AnalyzeBatch(ctx, queries, whoisData)
+	p.observe(err)
+	return analyses, err
+}
+
+// observe backs the limiter off when the provider rate limited us anyway
+func (p *LimitedProvider) observe(err error) {
+	if !errors.Is(err, ErrRateLimited) {
+		return
+	}
+
+	var rateLimited *RateLimitError
+	if errors.As(err, &rateLimited) {
+		p.limiter.Backoff(rateLimited.RetryAfter)
+	} else {
+		p.limiter.Backoff(0)
+	}
+}
+
+// AvailableTokens returns the tokens the next request can use, or -1 if unlimited
+func (p *LimitedProvider) AvailableTokens() int {
+	return p.limiter.AvailableTokens()
+}
+
+// Unwrap returns the wrapped provider
+func (p *LimitedProvider) Unwrap() Provider {
+	return p.Provider
+}
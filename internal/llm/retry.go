@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls how a provider retries transient API failures
+type RetryPolicy struct {
+	Name           string // Provider name used in log and error messages, e.g. "OpenAI"
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration // Longest the provider waits itself; longer Retry-After delays are left to the caller
+}
+
+// RetryableError is a transient API failure such as a rate limit, an overloaded
+// server or a server error
+type RetryableError struct {
+	Err         error
+	RateLimited bool
+	RetryAfter  time.Duration // Delay the server asked for, if any
+}
+
+func (e *RetryableError) Error() string { return e.Err.Error() }
+
+func (e *RetryableError) Unwrap() error { return e.Err }
+
+// Do calls send until it succeeds or fails with an error that is not a
+// *RetryableError, backing off exponentially between attempts. A retry never
+// comes sooner than the server's Retry-After. When that delay is longer than
+// MaxBackoff or the retries run out, rate-limited requests fail with a
+// *RateLimitError so the caller can retry later.
+func (p RetryPolicy) Do(ctx context.Context, send func() (string, error)) (string, error) {
+	backoff := p.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		text, err := send()
+		if err == nil {
+			return text, nil
+		}
+
+		// Check for timeout
+		if ctx.Err() != nil {
+			return "", ErrTimeout
+		}
+
+		var retryable *RetryableError
+		if !errors.As(err, &retryable) {
+			return "", err
+		}
+
+		// Give up when retries are exhausted or the server wants a longer pause
+		if attempt == p.MaxRetries || retryable.RetryAfter > p.MaxBackoff {
+			if retryable.RateLimited {
+				log.Printf("⚠️  [%s] Rate limit exceeded after %d retries", p.Name, attempt)
+				return "", &RateLimitError{RetryAfter: retryable.RetryAfter}
+			}
+			return "", fmt.Errorf("%s API request failed after %d retries: %w", strings.ToLower(p.Name), attempt, retryable.Err)
+		}
+
+		sleepDuration := max(min(backoff, p.MaxBackoff), retryable.RetryAfter)
+		log.Printf("⏳ [%s] %v, retry %d/%d after %v", p.Name, retryable.Err, attempt+1, p.MaxRetries, sleepDuration)
+
+		select {
+		case <-time.After(sleepDuration):
+			// Continue to next retry
+		case <-ctx.Done():
+			return "", ErrTimeout
+		}
+
+		// Double the backoff for next retry (exponential backoff)
+		backoff *= 2
+	}
+}
+
+// RetryAfter parses the Retry-After header (in seconds), returning 0 if absent
+func RetryAfter(header http.Header) time.Duration {
+	seconds, err := strconv.ParseFloat(header.Get("Retry-After"), 64)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// HeaderInt parses an integer header, returning -1 if absent or invalid
+func HeaderInt(header http.Header, name string) int {
+	value, err := strconv.Atoi(header.Get(name))
+	if err != nil {
+		return -1
+	}
+	return value
+}
@@ -0,0 +1,145 @@
+package llm
+
+import (
+	"context"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+// EscalationCriteria decides which first-stage verdicts are re-analyzed by the
+// escalation provider in tiered analysis
+type EscalationCriteria struct {
+	Classifications []string // Escalate verdicts with any of these classifications
+	MinRiskScore    int      // Escalate verdicts at or above this risk score (0 disables)
+}
+
+// DefaultEscalationCriteria escalates every verdict that is not Safe
+var DefaultEscalationCriteria = EscalationCriteria{
+	Classifications: []string{"Suspicious", "Malicious"},
+}
+
+// Matches reports whether a first-stage verdict should be escalated
+func (c EscalationCriteria) Matches(analysis *Analysis) bool {
+	if slices.Contains(c.Classifications, analysis.Classification) {
+		return true
+	}
+	return c.MinRiskScore > 0 && analysis.RiskScore >= c.MinRiskScore
+}
+
+// SetEscalation enables tiered analysis: the analyzer's provider triages every
+// domain and verdicts matching the criteria are re-analyzed by the escalation
+// provider, whose verdict is final
+func (a *Analyzer) SetEscalation(provider Provider, criteria EscalationCriteria) {
+	a.escalation = provider
+	a.escalationCriteria = criteria
+}
+
+// SetTierRetention keeps tier comparisons for the given period, pruning older
+// ones every hour until the analyzer stops. Zero keeps them forever.
+func (a *Analyzer) SetTierRetention(retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+
+	a.pruneEvery(func() {
+		if deleted, err := a.store.CleanOldTierComparisons(retention); err != nil {
+			log.Printf("⚠️  [Analyzer] Failed to prune tier comparisons: %v", err)
+		} else if deleted > 0 {
+			log.Printf("🧹 [Analyzer] Pruned %d tier comparisons older than %s", deleted, retention)
+		}
+	})
+}
+
+// escalate re-analyzes the first-stage verdicts that match the escalation criteria
+// and returns the final verdicts in query order. Both verdicts of each escalated
+// domain are stored for tier comparison. If escalation fails, the first-stage
+// verdicts are kept.
+func (a *Analyzer) escalate(batchNum int, queries []storage.DNSQuery, analyses []*Analysis, whoisData map[string]*storage.WHOISData) []*Analysis {
+	if a.escalation == nil || len(analyses) != len(queries) {
+		return analyses
+	}
+
+	var indexes []int
+	var escalated []storage.DNSQuery
+	for i, analysis := range analyses {
+		if analysis != nil && a.escalationCriteria.Matches(analysis) {
+			indexes = append(indexes, i)
+			escalated = append(escalated, queries[i])
+		}
+	}
+	if len(escalated) == 0 {
+		return analyses
+	}
+
+	log.Printf("⬆️  [Batch #%d] Escalating %d of %d verdicts to %s", batchNum, len(escalated), len(queries), a.escalation.Name())
+
+	ctx, cancel := context.WithTimeout(a.ctx, 90*time.Second)
+	defer cancel()
+
+	var results []*Analysis
+	var err error
+	if a.escalation.SupportsBatch() {
+		results, err = a.escalation.AnalyzeBatch(ctx, escalated, whoisData)
+	} else {
+		results = make([]*Analysis, len(escalated))
+		for i, query := range escalated {
+			if results[i], err = a.escalation.Analyze(ctx, query, whoisData[query.Domain]); err != nil {
+				break
+			}
+		}
+	}
+	if err == nil && len(results) != len(escalated) {
+		err = ErrInvalidJSON
+	}
+	if err != nil {
+		log.Printf("⚠️  [Batch #%d] Escalation failed, keeping first-stage verdicts: %v", batchNum, err)
+		a.mu.Lock()
+		a.escalationFailures += len(escalated)
+		a.mu.Unlock()
+		return analyses
+	}
+
+	final := slices.Clone(analyses)
+	for j, i := range indexes {
+		triage, verdict := analyses[i], results[j]
+		if verdict == nil {
+			continue
+		}
+		verdict.Escalated = true
+		final[i] = verdict
+
+		comparison := &storage.TierComparison{
+			Domain:     queries[i].Domain,
+			ClientID:   queries[i].ClientID,
+			Triage:     tierVerdict(triage),
+			Escalation: tierVerdict(verdict),
+		}
+		if err := a.store.SaveTierComparison(comparison); err != nil {
+			log.Printf("⚠️  Failed to save tier comparison for %s: %v", queries[i].Domain, err)
+		}
+		if !comparison.Agreed {
+			log.Printf("  ↕️  %s: %s (%s) -> %s (%s)", queries[i].Domain,
+				triage.Classification, triage.Provider, verdict.Classification, verdict.Provider)
+		}
+	}
+
+	a.mu.Lock()
+	a.escalations += len(escalated)
+	a.mu.Unlock()
+
+	return final
+}
+
+// tierVerdict converts an analysis to a stored tier verdict
+func tierVerdict(analysis *Analysis) storage.TierVerdict {
+	return storage.TierVerdict{
+		Provider:        analysis.Provider,
+		Classification:  analysis.Classification,
+		RiskScore:       analysis.RiskScore,
+		SuggestedAction: analysis.SuggestedAction,
+		Explanation:     analysis.Explanation,
+	}
+}
@@ -42,6 +42,11 @@ type Analysis struct {
 	AnalyzedAt time.Time `json:"analyzed_at"`
 	Provider   string    `json:"provider"` // Which LLM provider was used
 	QueryType  string    `json:"query_type,omitempty"`
+	Escalated  bool      `json:"escalated,omitempty"` // Re-analyzed by the escalation provider in tiered analysis
+	Cached     bool      `json:"cached,omitempty"`    // Reused from another client's recent verdict
+
+	// PromptVersion identifies the prompt template that produced the verdict
+	PromptVersion string `json:"prompt_version,omitempty"`
 }
 
 // LLMResponse represents the raw JSON response from an LLM
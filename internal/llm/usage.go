@@ -0,0 +1,333 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+// Usage is the number of tokens one provider request used
+type Usage struct {
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// usageKey is the context key of a request's usage recorder
+type usageKey struct{}
+
+// WithUsageRecorder returns a context whose requests report their usage to record
+func WithUsageRecorder(ctx context.Context, record func(Usage)) context.Context {
+	return context.WithValue(ctx, usageKey{}, record)
+}
+
+// ReportUsage records the tokens a request used. Providers call it with the
+// request's context after every response that reports token counts.
+func ReportUsage(ctx context.Context, usage Usage) {
+	if record, ok := ctx.Value(usageKey{}).(func(Usage)); ok {
+		record(usage)
+	}
+}
+
+// Price is a model's price in USD per million prompt and completion tokens
+type Price struct {
+	Prompt     float64
+	Completion float64
+}
+
+// DefaultPrices are the list prices of common models. Models are matched by
+// their longest price-table prefix, so dated versions share a price.
+var DefaultPrices = map[string]Price{
+	"gemini-1.5-flash":      {Prompt: 0.075, Completion: 0.30},
+	"gemini-1.5-pro":        {Prompt: 1.25, Completion: 5.00},
+	"gemini-2.0-flash":      {Prompt: 0.10, Completion: 0.40},
+	"gemini-2.5-flash":      {Prompt: 0.30, Completion: 2.50},
+	"gemini-2.5-flash-lite": {Prompt: 0.10, Completion: 0.40},
+	"gemini-2.5-pro":        {Prompt: 1.25, Completion: 10.00},
+	"gpt-4o":                {Prompt: 2.50, Completion: 10.00},
+	"gpt-4o-mini":           {Prompt: 0.15, Completion: 0.60},
+	"gpt-4.1":               {Prompt: 2.00, Completion: 8.00},
+	"gpt-4.1-mini":          {Prompt: 0.40, Completion: 1.60},
+	"gpt-4.1-nano":          {Prompt: 0.10, Completion: 0.40},
+	"claude-3-haiku":        {Prompt: 0.25, Completion: 1.25},
+	"claude-3-5-haiku":      {Prompt: 0.80, Completion: 4.00},
+	"claude-3-5-sonnet":     {Prompt: 3.00, Completion: 15.00},
+	"claude-3-7-sonnet":     {Prompt: 3.00, Completion: 15.00},
+	"claude-sonnet-4":       {Prompt: 3.00, Completion: 15.00},
+}
+
+// ParsePrices parses "model=prompt/completion" entries (USD per million tokens)
+// and merges them over DefaultPrices
+func ParsePrices(specs []string) (map[string]Price, error) {
+	prices := make(map[string]Price, len(DefaultPrices)+len(specs))
+	for model, price := range DefaultPrices {
+		prices[model] = price
+	}
+
+	for _, spec := range specs {
+		model, rates, ok := strings.Cut(spec, "=")
+		model = strings.TrimSpace(model)
+		promptRate, completionRate, ok2 := strings.Cut(rates, "/")
+		if !ok || !ok2 || model == "" {
+			return nil, fmt.Errorf("invalid price %q (expected model=prompt/completion)", spec)
+		}
+
+		prompt, err := strconv.ParseFloat(strings.TrimSpace(promptRate), 64)
+		if err != nil || prompt < 0 {
+			return nil, fmt.Errorf("invalid prompt price in %q", spec)
+		}
+		completion, err := strconv.ParseFloat(strings.TrimSpace(completionRate), 64)
+		if err != nil || completion < 0 {
+			return nil, fmt.Errorf("invalid completion price in %q", spec)
+		}
+		prices[model] = Price{Prompt: prompt, Completion: completion}
+	}
+	return prices, nil
+}
+
+// Budget is the most the LLM providers may spend, in USD. Zero means no limit.
+type Budget struct {
+	Daily   float64
+	Monthly float64
+}
+
+// BudgetStatus reports spend against the daily and monthly budgets
+type BudgetStatus struct {
+	DailyLimit   float64 `json:"daily_limit_usd"`
+	DailySpent   float64 `json:"daily_spent_usd"`
+	MonthlyLimit float64 `json:"monthly_limit_usd"`
+	MonthlySpent float64 `json:"monthly_spent_usd"`
+	Paused       bool    `json:"paused"` // Paid providers are paused until the budget resets
+}
+
+// Meter records the token usage and cost of LLM requests and enforces the budget
+type Meter struct {
+	mu     sync.Mutex
+	store  *storage.BoltStore
+	prices map[string]Price
+	budget Budget
+
+	day        string // Date of daySpent
+	month      string // Month of monthSpent
+	daySpent   float64
+	monthSpent float64
+}
+
+// NewMeter creates a usage meter, loading this month's spend from storage
+func NewMeter(store *storage.BoltStore, prices map[string]Price, budget Budget) (*Meter, error) {
+	now := time.Now()
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	days, err := store.GetUsage(monthStart, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load LLM usage: %w", err)
+	}
+
+	m := &Meter{
+		store:  store,
+		prices: prices,
+		budget: budget,
+		day:    now.Format(storage.UsageDateFormat),
+		month:  now.Format("2006-01"),
+	}
+	for _, day := range days {
+		m.monthSpent += day.Cost
+		if day.Date == m.day {
+			m.daySpent = day.Cost
+		}
+	}
+	return m, nil
+}
+
+// Price returns the price of a model by its longest matching price-table prefix.
+// Unknown models are free.
+func (m *Meter) Price(model string) Price {
+	var price Price
+	matched := -1
+	for prefix, p := range m.prices {
+		if strings.HasPrefix(model, prefix) && len(prefix) > matched {
+			price, matched = p, len(prefix)
+		}
+	}
+	return price
+}
+
+// cost returns the USD cost of a request's usage
+func (m *Meter) cost(model string, usage Usage) float64 {
+	price := m.Price(model)
+	return (float64(usage.PromptTokens)*price.Prompt + float64(usage.CompletionTokens)*price.Completion) / 1e6
+}
+
+// roll resets the spend when a new day or month starts. Callers must hold mu.
+func (m *Meter) roll(now time.Time) {
+	if day := now.Format(storage.UsageDateFormat); day != m.day {
+		m.day = day
+		m.daySpent = 0
+	}
+	if month := now.Format("2006-01"); month != m.month {
+		m.month = month
+		m.monthSpent = 0
+	}
+}
+
+// exceeded returns ErrBudgetExceeded if the daily or monthly budget is spent.
+// Callers must hold mu.
+func (m *Meter) exceeded() error {
+	if m.budget.Daily > 0 && m.daySpent >= m.budget.Daily {
+		return fmt.Errorf("%w: spent $%.2f of $%.2f today", ErrBudgetExceeded, m.daySpent, m.budget.Daily)
+	}
+	if m.budget.Monthly > 0 && m.monthSpent >= m.budget.Monthly {
+		return fmt.Errorf("%w: spent $%.2f of $%.2f this month", ErrBudgetExceeded, m.monthSpent, m.budget.Monthly)
+	}
+	return nil
+}
+
+// Allow returns ErrBudgetExceeded while the daily or monthly budget is spent
+func (m *Meter) Allow() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roll(time.Now())
+	return m.exceeded()
+}
+
+// HasBudget returns true if a daily or monthly budget is set
+func (m *Meter) HasBudget() bool {
+	return m.budget.Daily > 0 || m.budget.Monthly > 0
+}
+
+// Status returns the spend against the daily and monthly budgets
+func (m *Meter) Status() BudgetStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roll(time.Now())
+
+	return BudgetStatus{
+		DailyLimit:   m.budget.Daily,
+		DailySpent:   m.daySpent,
+		MonthlyLimit: m.budget.Monthly,
+		MonthlySpent: m.monthSpent,
+		Paused:       m.exceeded() != nil,
+	}
+}
+
+// Record stores the usage of one request, splitting it evenly between the
+// clients whose queries it analyzed
+func (m *Meter) Record(provider, model string, usage Usage, queries []storage.DNSQuery) {
+	now := time.Now()
+	cost := m.cost(model, usage)
+
+	clients := make([]storage.ClientUsage, len(queries))
+	for i, query := range queries {
+		share := storage.TokenUsage{
+			PromptTokens:     int64(usage.PromptTokens / len(queries)),
+			CompletionTokens: int64(usage.CompletionTokens / len(queries)),
+			Cost:             cost / float64(len(queries)),
+		}
+		if i == 0 {
+			// The first query takes the tokens that do not divide evenly
+			share.PromptTokens += int64(usage.PromptTokens % len(queries))
+			share.CompletionTokens += int64(usage.CompletionTokens % len(queries))
+		}
+		clients[i] = storage.ClientUsage{
+			ClientID:   query.ClientID,
+			ClientName: query.ClientName,
+			Domains:    1,
+			TokenUsage: share,
+		}
+	}
+
+	err := m.store.RecordUsage(now, storage.ProviderUsage{
+		Provider: provider,
+		Model:    model,
+		Requests: 1,
+		TokenUsage: storage.TokenUsage{
+			PromptTokens:     int64(usage.PromptTokens),
+			CompletionTokens: int64(usage.CompletionTokens),
+			Cost:             cost,
+		},
+	}, clients)
+	if err != nil {
+		log.Printf("⚠️  [Meter] Failed to record LLM usage: %v", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.roll(now)
+
+	wasExceeded := m.exceeded() != nil
+	m.daySpent += cost
+	m.monthSpent += cost
+	if err := m.exceeded(); err != nil && !wasExceeded {
+		log.Printf("💸 [Meter] %v, pausing paid LLM providers", err)
+	}
+}
+
+// track returns a context that records the usage of requests for the given queries
+func (m *Meter) track(ctx context.Context, provider, model string, queries []storage.DNSQuery) context.Context {
+	return WithUsageRecorder(ctx, func(usage Usage) {
+		m.Record(provider, model, usage, queries)
+	})
+}
+
+// MeteredProvider wraps a Provider to record its usage and, if it is paid,
+// pause it while the budget is spent. Free (local) providers are never paused.
+type MeteredProvider struct {
+	Provider
+	meter *Meter
+	model string
+	paid  bool
+}
+
+// NewMeteredProvider wraps a provider that runs the given model with a usage meter
+func NewMeteredProvider(provider Provider, model string, paid bool, meter *Meter) *MeteredProvider {
+	return &MeteredProvider{
+		Provider: provider,
+		meter:    meter,
+		model:    model,
+		paid:     paid,
+	}
+}
+
+// Analyze analyzes the query unless the budget is spent
+func (p *MeteredProvider) Analyze(ctx context.Context, query storage.DNSQuery, whois *storage.WHOISData) (*Analysis, error) {
+	if err := p.allow(); err != nil {
+		return nil, err
+	}
+	ctx = p.meter.track(ctx, p.Name(), p.model, []storage.DNSQuery{query})
+	return p.Provider.Analyze(ctx, query, whois)
+}
+
+// AnalyzeBatch analyzes the batch unless the budget is spent
+func (p *MeteredProvider) AnalyzeBatch(ctx context.Context, queries []storage.DNSQuery, whoisData map[string]*storage.WHOISData) ([]*Analysis, error) {
+	if err := p.allow(); err != nil {
+		return nil, err
+	}
+	ctx = p.meter.track(ctx, p.Name(), p.model, queries)
+	return p.Provider.AnalyzeBatch(ctx, queries, whoisData)
+}
+
+// allow returns ErrBudgetExceeded if the provider is paid and the budget is spent
+func (p *MeteredProvider) allow() error {
+	if !p.paid {
+		return nil
+	}
+	return p.meter.Allow()
+}
+
+// AvailableTokens returns the wrapped provider's token budget, or -1 if unlimited
+func (p *MeteredProvider) AvailableTokens() int {
+	if budgeted, ok := p.Provider.(Budgeted); ok {
+		return budgeted.AvailableTokens()
+	}
+	return -1
+}
+
+// Unwrap returns the wrapped provider
+func (p *MeteredProvider) Unwrap() Provider {
+	return p.Provider
+}
@@ -0,0 +1,219 @@
+package rules
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+// Result describes a rule that fired for a query
+type Result struct {
+	Rule  *Rule
+	Query storage.DNSQuery
+	Count int // Matching queries inside the threshold window
+}
+
+// Engine evaluates declarative rules against every ingested query
+type Engine struct {
+	dir string
+
+	mu        sync.Mutex
+	rules     []*Rule
+	windows   map[string][]time.Time // Timestamps of matching queries per group key
+	lastFired map[string]time.Time
+	loadedAt  time.Time
+}
+
+// NewEngine creates a rule engine and loads the rules in dir
+func NewEngine(dir string) (*Engine, error) {
+	e := &Engine{
+		dir:       dir,
+		windows:   make(map[string][]time.Time),
+		lastFired: make(map[string]time.Time),
+	}
+
+	if err := e.Reload(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// NewEngineWithRules creates a rule engine from already loaded rules
+func NewEngineWithRules(rules []*Rule) *Engine {
+	return &Engine{
+		rules:     rules,
+		windows:   make(map[string][]time.Time),
+		lastFired: make(map[string]time.Time),
+		loadedAt:  time.Now(),
+	}
+}
+
+// Reload re-reads the rules directory. On error the current rules are kept.
+func (e *Engine) Reload() error {
+	rules, err := LoadDir(e.dir)
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.rules = rules
+	e.windows = make(map[string][]time.Time)
+	e.lastFired = make(map[string]time.Time)
+	e.loadedAt = time.Now()
+
+	log.Printf("📜 [Rules] Loaded %d rules from %s", len(rules), e.dir)
+	return nil
+}
+
+// Rules returns the currently loaded rules
+func (e *Engine) Rules() []*Rule {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]*Rule(nil), e.rules...)
+}
+
+// LoadedAt returns when the rules were last (re)loaded
+func (e *Engine) LoadedAt() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.loadedAt
+}
+
+// Evaluate runs every enabled rule against the query and returns the rules that fired.
+// Threshold windows and cooldowns use the query timestamp, so replaying sample
+// queries gives the same results as live traffic.
+func (e *Engine) Evaluate(query storage.DNSQuery) []Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var results []Result
+
+	for _, rule := range e.rules {
+		if !rule.IsEnabled() || !rule.Matches(query) {
+			continue
+		}
+
+		key := rule.groupKey(query)
+		count := 1
+
+		if rule.Threshold.Count > 1 {
+			timestamps := insertSorted(e.windows[key], query.Timestamp)
+
+			// Keep only matches inside the window ending at the newest one
+			cutoff := timestamps[len(timestamps)-1].Add(-rule.window)
+			start := sort.Search(len(timestamps), func(i int) bool {
+				return timestamps[i].After(cutoff)
+			})
+			timestamps = timestamps[start:]
+			e.windows[key] = timestamps
+
+			count = len(timestamps)
+			if count < rule.Threshold.Count {
+				continue
+			}
+		}
+
+		if last, ok := e.lastFired[key]; ok && query.Timestamp.Sub(last) < rule.cooldown {
+			continue
+		}
+		e.lastFired[key] = query.Timestamp
+		delete(e.windows, key)
+
+		results = append(results, Result{Rule: rule, Query: query, Count: count})
+	}
+
+	return results
+}
+
+// Prune removes threshold windows and cooldowns that have expired by now,
+// and state left behind by rules that no longer exist
+func (e *Engine) Prune(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	byName := make(map[string]*Rule, len(e.rules))
+	for _, rule := range e.rules {
+		byName[rule.Name] = rule
+	}
+	ruleFor := func(key string) *Rule {
+		name, _, _ := strings.Cut(key, "|")
+		return byName[name]
+	}
+
+	for key, timestamps := range e.windows {
+		rule := ruleFor(key)
+		if rule == nil || len(timestamps) == 0 || !timestamps[len(timestamps)-1].After(now.Add(-rule.window)) {
+			delete(e.windows, key)
+		}
+	}
+	for key, last := range e.lastFired {
+		rule := ruleFor(key)
+		if rule == nil || now.Sub(last) >= rule.cooldown {
+			delete(e.lastFired, key)
+		}
+	}
+}
+
+// Anomaly builds the anomaly record for a fired rule
+func (r Result) Anomaly() *storage.Anomaly {
+	classification, riskScore := r.Rule.classification()
+
+	suggestedAction := "Investigate"
+	if r.Rule.Action == ActionBlock {
+		suggestedAction = "Block"
+	}
+
+	explanation := r.Rule.Description
+	if explanation == "" {
+		explanation = fmt.Sprintf("Matched rule %q", r.Rule.Name)
+	}
+	if r.Rule.Threshold.Count > 1 {
+		explanation = fmt.Sprintf("%s (%d matching queries within %s)", explanation, r.Count, r.Rule.window)
+	}
+
+	detectedAt := time.Now()
+
+	return &storage.Anomaly{
+		// Include the rule so several rules matching one query keep separate anomalies
+		ID: fmt.Sprintf("%s|%s|%s|%s|%s", storage.AnomalyTypeRule, r.Rule.Name,
+			r.Query.ClientID, r.Query.Domain, detectedAt.Format(time.RFC3339)),
+		Type:            storage.AnomalyTypeRule,
+		Domain:          r.Query.Domain,
+		ClientID:        r.Query.ClientID,
+		ClientName:      r.Query.ClientName,
+		QueryType:       r.Query.QueryType,
+		Classification:  classification,
+		RiskScore:       riskScore,
+		Explanation:     explanation,
+		SuggestedAction: suggestedAction,
+		DetectedAt:      detectedAt,
+		OffHours:        r.Query.OffHours,
+		Details: map[string]string{
+			"rule":     r.Rule.Name,
+			"severity": r.Rule.Severity,
+			"action":   r.Rule.Action,
+			"count":    fmt.Sprintf("%d", r.Count),
+		},
+	}
+}
+
+// insertSorted inserts t into a sorted slice of timestamps
+func insertSorted(timestamps []time.Time, t time.Time) []time.Time {
+	idx := sort.Search(len(timestamps), func(i int) bool {
+		return timestamps[i].After(t)
+	})
+	timestamps = append(timestamps, time.Time{})
+	copy(timestamps[idx+1:], timestamps[idx:])
+	timestamps[idx] = t
+	return timestamps
+}
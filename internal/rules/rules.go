@@ -0,0 +1,311 @@
+package rules
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+// Rule actions
+const (
+	ActionAlert = "alert" // Record an anomaly
+	ActionBlock = "block" // Record an anomaly and block the domain in AdGuard Home
+	ActionLog   = "log"   // Only write a log line
+)
+
+// Group-by keys for thresholds
+const (
+	GroupByClient       = "client"
+	GroupByDomain       = "domain"
+	GroupByClientDomain = "client_domain"
+)
+
+// severities maps a rule severity to an anomaly classification and risk score
+var severities = map[string]struct {
+	classification string
+	riskScore      int
+}{
+	"low":      {"Suspicious", 4},
+	"medium":   {"Suspicious", 6},
+	"high":     {"Malicious", 8},
+	"critical": {"Malicious", 10},
+}
+
+// ruleFile is the YAML layout of a rules file
+type ruleFile struct {
+	Rules []*Rule `yaml:"rules"`
+}
+
+// Rule is a declarative detection rule loaded from YAML
+type Rule struct {
+	Name        string    `yaml:"name" json:"name"`
+	Description string    `yaml:"description,omitempty" json:"description,omitempty"`
+	Enabled     *bool     `yaml:"enabled,omitempty" json:"enabled,omitempty"`
+	Match       Match     `yaml:"match" json:"match"`
+	Threshold   Threshold `yaml:"threshold,omitempty" json:"threshold,omitempty"`
+	Severity    string    `yaml:"severity" json:"severity"` // low, medium, high, critical
+	Action      string    `yaml:"action" json:"action"`     // alert, block, log
+	Cooldown    string    `yaml:"cooldown,omitempty" json:"cooldown,omitempty"`
+
+	// Source file the rule was loaded from
+	File string `yaml:"-" json:"file"`
+
+	domainRegex *regexp.Regexp
+	window      time.Duration
+	cooldown    time.Duration
+}
+
+// Match holds the conditions a query must satisfy; empty conditions match anything.
+// List conditions match if any entry matches.
+type Match struct {
+	Domain      StringList `yaml:"domain,omitempty" json:"domain,omitempty"` // Glob patterns, e.g. "*.xyz"
+	DomainRegex string     `yaml:"domain_regex,omitempty" json:"domain_regex,omitempty"`
+	QueryType   StringList `yaml:"query_type,omitempty" json:"query_type,omitempty"`
+	Client      StringList `yaml:"client,omitempty" json:"client,omitempty"` // Globs on client ID or name
+	Reason      StringList `yaml:"reason,omitempty" json:"reason,omitempty"`
+	Response    StringList `yaml:"response,omitempty" json:"response,omitempty"`
+	Answer      StringList `yaml:"answer,omitempty" json:"answer,omitempty"` // Glob patterns
+}
+
+// StringList is a list of strings that may also be written as a single YAML scalar
+type StringList []string
+
+// UnmarshalYAML accepts either a scalar or a sequence
+func (l *StringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = StringList{value.Value}
+		return nil
+	}
+
+	var items []string
+	if err := value.Decode(&items); err != nil {
+		return err
+	}
+	*l = items
+	return nil
+}
+
+// Threshold requires Count matching queries within Window before the rule fires
+type Threshold struct {
+	Count   int    `yaml:"count,omitempty" json:"count,omitempty"`
+	Window  string `yaml:"window,omitempty" json:"window,omitempty"`
+	GroupBy string `yaml:"group_by,omitempty" json:"group_by,omitempty"` // client, domain, client_domain
+}
+
+// LoadDir loads all *.yml and *.yaml rule files from a directory
+func LoadDir(dir string) ([]*Rule, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules directory: %w", err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if !entry.IsDir() && (ext == ".yml" || ext == ".yaml") {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+
+	var rules []*Rule
+	names := make(map[string]string)
+	for _, file := range files {
+		loaded, err := LoadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range loaded {
+			if other, ok := names[rule.Name]; ok {
+				return nil, fmt.Errorf("%s: duplicate rule name %q (also defined in %s)", file, rule.Name, other)
+			}
+			names[rule.Name] = file
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, nil
+}
+
+// LoadFile loads and validates the rules in a single YAML file
+func LoadFile(file string) ([]*Rule, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+
+	var parsed ruleFile
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("%s: invalid YAML: %w", file, err)
+	}
+
+	for i, rule := range parsed.Rules {
+		rule.File = file
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("%s: rule %d (%s): %w", file, i+1, rule.Name, err)
+		}
+	}
+
+	return parsed.Rules, nil
+}
+
+// compile validates a rule and prepares its regular expression and durations
+func (r *Rule) compile() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if strings.Contains(r.Name, "|") {
+		return fmt.Errorf("name must not contain '|'") // Separates the fields of group keys and anomaly IDs
+	}
+
+	r.Severity = strings.ToLower(r.Severity)
+	if r.Severity == "" {
+		r.Severity = "medium"
+	}
+	if _, ok := severities[r.Severity]; !ok {
+		return fmt.Errorf("invalid severity %q (must be low, medium, high, or critical)", r.Severity)
+	}
+
+	r.Action = strings.ToLower(r.Action)
+	if r.Action == "" {
+		r.Action = ActionAlert
+	}
+	if r.Action != ActionAlert && r.Action != ActionBlock && r.Action != ActionLog {
+		return fmt.Errorf("invalid action %q (must be alert, block, or log)", r.Action)
+	}
+
+	if r.Match.DomainRegex != "" {
+		re, err := regexp.Compile(r.Match.DomainRegex)
+		if err != nil {
+			return fmt.Errorf("invalid domain_regex: %w", err)
+		}
+		r.domainRegex = re
+	}
+
+	for _, patterns := range []StringList{r.Match.Domain, r.Match.Client, r.Match.Answer} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid glob %q: %w", pattern, err)
+			}
+		}
+	}
+
+	if r.Threshold.Count > 1 {
+		window, err := time.ParseDuration(r.Threshold.Window)
+		if err != nil || window <= 0 {
+			return fmt.Errorf("threshold.window must be a positive duration when threshold.count is set")
+		}
+		r.window = window
+	}
+
+	switch r.Threshold.GroupBy {
+	case "":
+		// Thresholds count per client; single-query rules alert per client and domain
+		r.Threshold.GroupBy = GroupByClientDomain
+		if r.Threshold.Count > 1 {
+			r.Threshold.GroupBy = GroupByClient
+		}
+	case GroupByClient, GroupByDomain, GroupByClientDomain:
+	default:
+		return fmt.Errorf("invalid threshold.group_by %q (must be client, domain, or client_domain)", r.Threshold.GroupBy)
+	}
+
+	// Without an explicit cooldown, fire at most once per window (or hour)
+	r.cooldown = r.window
+	if r.cooldown == 0 {
+		r.cooldown = time.Hour
+	}
+	if r.Cooldown != "" {
+		cooldown, err := time.ParseDuration(r.Cooldown)
+		if err != nil {
+			return fmt.Errorf("invalid cooldown: %w", err)
+		}
+		r.cooldown = cooldown
+	}
+
+	return nil
+}
+
+// IsEnabled returns true unless the rule is explicitly disabled
+func (r *Rule) IsEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+// Matches returns true if the query satisfies all of the rule's match conditions
+func (r *Rule) Matches(query storage.DNSQuery) bool {
+	domain := strings.ToLower(strings.TrimSuffix(query.Domain, "."))
+
+	if len(r.Match.Domain) > 0 && !matchAnyGlob(r.Match.Domain, domain) {
+		return false
+	}
+	if r.domainRegex != nil && !r.domainRegex.MatchString(domain) {
+		return false
+	}
+	if len(r.Match.QueryType) > 0 && !matchAnyFold(r.Match.QueryType, query.QueryType) {
+		return false
+	}
+	if len(r.Match.Client) > 0 &&
+		!matchAnyGlob(r.Match.Client, query.ClientID) &&
+		!matchAnyGlob(r.Match.Client, query.ClientName) {
+		return false
+	}
+	if len(r.Match.Reason) > 0 && !matchAnyFold(r.Match.Reason, query.Reason) {
+		return false
+	}
+	if len(r.Match.Response) > 0 && !matchAnyFold(r.Match.Response, query.Response) {
+		return false
+	}
+	if len(r.Match.Answer) > 0 && !matchAnyGlob(r.Match.Answer, query.Answer) {
+		return false
+	}
+
+	return true
+}
+
+// classification returns the anomaly classification and risk score for the rule's severity
+func (r *Rule) classification() (string, int) {
+	s := severities[r.Severity]
+	return s.classification, s.riskScore
+}
+
+// groupKey returns the key thresholds are counted under for a query
+func (r *Rule) groupKey(query storage.DNSQuery) string {
+	switch r.Threshold.GroupBy {
+	case GroupByDomain:
+		return r.Name + "|" + query.Domain
+	case GroupByClientDomain:
+		return r.Name + "|" + query.ClientID + "|" + query.Domain
+	default:
+		return r.Name + "|" + query.ClientID
+	}
+}
+
+// matchAnyGlob returns true if value matches any of the glob patterns (case-insensitive)
+func matchAnyGlob(patterns []string, value string) bool {
+	value = strings.ToLower(value)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnyFold returns true if value equals any of the options (case-insensitive)
+func matchAnyFold(options []string, value string) bool {
+	for _, option := range options {
+		if strings.EqualFold(option, value) {
+			return true
+		}
+	}
+	return false
+}
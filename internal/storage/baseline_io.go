@@ -0,0 +1,251 @@
+package storage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Baseline export formats
+const (
+	BaselineFormatJSON = "json"
+	BaselineFormatCSV  = "csv"
+)
+
+// Baseline import modes
+const (
+	ImportModeMerge   = "merge"   // Add imported domains to existing baselines
+	ImportModeReplace = "replace" // Imported clients get exactly the imported domains
+)
+
+// baselineExportVersion is bumped when the export layout changes
+const baselineExportVersion = 1
+
+// baselineCSVHeader is the header row of CSV exports
+var baselineCSVHeader = []string{"client_id", "client_name", "domain", "last_updated"}
+
+// BaselineExport is the JSON document produced by a baseline export
+type BaselineExport struct {
+	Version    int        `json:"version"`
+	ExportedAt time.Time  `json:"exported_at"`
+	Baselines  []Baseline `json:"baselines"`
+}
+
+// ImportResult summarizes a baseline import
+type ImportResult struct {
+	Mode         string `json:"mode"`
+	Clients      int    `json:"clients"`
+	DomainsAdded int    `json:"domains_added"`
+}
+
+// WriteBaselines writes baselines in the given format, sorted by client and domain
+func WriteBaselines(w io.Writer, baselines []Baseline, format string) error {
+	baselines = sortedBaselines(baselines)
+
+	switch format {
+	case BaselineFormatJSON, "":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(BaselineExport{
+			Version:    baselineExportVersion,
+			ExportedAt: time.Now(),
+			Baselines:  baselines,
+		})
+
+	case BaselineFormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(baselineCSVHeader); err != nil {
+			return err
+		}
+		for _, baseline := range baselines {
+			updated := ""
+			if !baseline.LastUpdated.IsZero() {
+				updated = baseline.LastUpdated.Format(time.RFC3339)
+			}
+			for _, domain := range baseline.Domains {
+				if err := writer.Write([]string{baseline.ClientID, baseline.ClientName, domain, updated}); err != nil {
+					return err
+				}
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+
+	default:
+		return fmt.Errorf("unsupported baseline format %q (must be json or csv)", format)
+	}
+}
+
+// ReadBaselines parses baselines exported by WriteBaselines
+func ReadBaselines(r io.Reader, format string) ([]Baseline, error) {
+	switch format {
+	case BaselineFormatJSON, "":
+		var export BaselineExport
+		if err := json.NewDecoder(r).Decode(&export); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON baseline export: %w", err)
+		}
+		if export.Version > baselineExportVersion {
+			return nil, fmt.Errorf("unsupported baseline export version %d", export.Version)
+		}
+		for _, baseline := range export.Baselines {
+			if baseline.ClientID == "" {
+				return nil, fmt.Errorf("baseline without client_id")
+			}
+		}
+		return export.Baselines, nil
+
+	case BaselineFormatCSV:
+		return readBaselinesCSV(r)
+
+	default:
+		return nil, fmt.Errorf("unsupported baseline format %q (must be json or csv)", format)
+	}
+}
+
+// readBaselinesCSV parses client_id,client_name,domain[,last_updated] rows
+func readBaselinesCSV(r io.Reader) ([]Baseline, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	byClient := make(map[string]*Baseline)
+	var order []string
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CSV baseline export: %w", err)
+		}
+		if line == 1 && strings.EqualFold(record[0], baselineCSVHeader[0]) {
+			continue
+		}
+		if len(record) < 3 {
+			return nil, fmt.Errorf("line %d: expected client_id,client_name,domain", line)
+		}
+
+		clientID := strings.TrimSpace(record[0])
+		domain := strings.ToLower(strings.TrimSpace(record[2]))
+		if clientID == "" || domain == "" {
+			return nil, fmt.Errorf("line %d: client_id and domain are required", line)
+		}
+
+		baseline, ok := byClient[clientID]
+		if !ok {
+			baseline = &Baseline{ClientID: clientID, Domains: []string{}}
+			byClient[clientID] = baseline
+			order = append(order, clientID)
+		}
+		if name := strings.TrimSpace(record[1]); name != "" {
+			baseline.ClientName = name
+		}
+		if len(record) > 3 {
+			if updated, err := time.Parse(time.RFC3339, strings.TrimSpace(record[3])); err == nil && updated.After(baseline.LastUpdated) {
+				baseline.LastUpdated = updated
+			}
+		}
+		baseline.Domains = append(baseline.Domains, domain)
+	}
+
+	baselines := make([]Baseline, 0, len(order))
+	for _, clientID := range order {
+		baselines = append(baselines, *byClient[clientID])
+	}
+	return baselines, nil
+}
+
+// ImportBaselines loads baselines into the store. Merge mode adds the imported
+// domains to each client's baseline; replace mode overwrites the baselines of
+// the imported clients. Clients not in the import are left untouched. The import
+// runs in one transaction, so a failure leaves every baseline unchanged.
+func (s *BoltStore) ImportBaselines(baselines []Baseline, mode string) (*ImportResult, error) {
+	if mode == "" {
+		mode = ImportModeMerge
+	}
+	if mode != ImportModeMerge && mode != ImportModeReplace {
+		return nil, fmt.Errorf("unsupported import mode %q (must be merge or replace)", mode)
+	}
+
+	result := &ImportResult{Mode: mode}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, baseline := range baselines {
+			// JSON imports are not normalized while parsing, unlike CSV
+			baseline.Domains = normalizeDomains(baseline.Domains)
+
+			switch mode {
+			case ImportModeMerge:
+				added, err := addDomainsToBaseline(tx, baseline.ClientID, baseline.ClientName, baseline.Domains)
+				if err != nil {
+					return fmt.Errorf("failed to merge baseline for %s: %w", baseline.ClientID, err)
+				}
+				result.DomainsAdded += added
+
+			case ImportModeReplace:
+				replacement := Baseline{
+					ClientID:    baseline.ClientID,
+					ClientName:  baseline.ClientName,
+					Domains:     uniqueDomains(baseline.Domains),
+					LastUpdated: time.Now(),
+				}
+				if err := putBaseline(tx, &replacement); err != nil {
+					return fmt.Errorf("failed to replace baseline for %s: %w", baseline.ClientID, err)
+				}
+				result.DomainsAdded += len(replacement.Domains)
+			}
+			result.Clients++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// sortedBaselines returns copies of the baselines ordered by client ID with sorted domains,
+// so exports diff cleanly under version control
+func sortedBaselines(baselines []Baseline) []Baseline {
+	sorted := make([]Baseline, len(baselines))
+	for i, baseline := range baselines {
+		baseline.Domains = append([]string(nil), baseline.Domains...)
+		sort.Strings(baseline.Domains)
+		sorted[i] = baseline
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ClientID < sorted[j].ClientID
+	})
+	return sorted
+}
+
+// uniqueDomains removes empty and duplicate domains, keeping the first occurrence
+func uniqueDomains(domains []string) []string {
+	seen := make(map[string]bool, len(domains))
+	unique := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		if domain == "" || seen[domain] {
+			continue
+		}
+		seen[domain] = true
+		unique = append(unique, domain)
+	}
+	return unique
+}
+
+// normalizeDomains lowercases domains and strips whitespace and the trailing
+// dot, so imported baselines match queries regardless of either
+func normalizeDomains(domains []string) []string {
+	normalized := make([]string, len(domains))
+	for i, domain := range domains {
+		normalized[i] = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(domain), "."))
+	}
+	return normalized
+}
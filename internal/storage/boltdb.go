@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	bolt "go.etcd.io/bbolt"
@@ -15,6 +16,19 @@ var (
 	whoisCacheBucket       = []byte("whois_cache")
 	anomaliesBucket        = []byte("anomalies")
 	analysesBucket         = []byte("analyses")
+	clientStatsBucket      = []byte("client_stats")
+	activityBucket         = []byte("activity_profiles")
+	countersBucket         = []byte("counters")
+	incidentsBucket        = []byte("incidents")
+	snapshotsBucket        = []byte("baseline_snapshots")
+	eventsBucket           = []byte("first_seen_events")
+	eventIndexBucket       = []byte("first_seen_index")
+	llmQueueBucket         = []byte("llm_queue")
+	llmQueueIndexBucket    = []byte("llm_queue_due")
+	llmDeadLetterBucket    = []byte("llm_dead_letters")
+	tierComparisonsBucket  = []byte("tier_comparisons")
+	verdictCacheBucket     = []byte("verdict_cache")
+	llmUsageBucket         = []byte("llm_usage")
 )
 
 // BoltStore provides persistent storage using BoltDB
@@ -46,13 +60,26 @@ func NewBoltStore(dbPath string) (*BoltStore, error) {
 			whoisCacheBucket,
 			anomaliesBucket,
 			analysesBucket,
+			clientStatsBucket,
+			activityBucket,
+			countersBucket,
+			incidentsBucket,
+			snapshotsBucket,
+			eventsBucket,
+			eventIndexBucket,
+			llmQueueBucket,
+			llmQueueIndexBucket,
+			llmDeadLetterBucket,
+			tierComparisonsBucket,
+			verdictCacheBucket,
+			llmUsageBucket,
 		}
 		for _, bucket := range buckets {
 			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
 				return fmt.Errorf("failed to create bucket %s: %w", string(bucket), err)
 			}
 		}
-		return nil
+		return rebuildQueueIndex(tx)
 	})
 	if err != nil {
 		db.Close()
@@ -97,46 +124,88 @@ func (s *BoltStore) GetClientBaseline(clientID string) (*Baseline, error) {
 
 // AddDomainToBaseline adds a domain to a client's baseline
 func (s *BoltStore) AddDomainToBaseline(clientID, clientName, domain string) error {
-	return s.db.Update(func(tx *bolt.Tx) error {
-		b := tx.Bucket(baselineBucket)
+	_, err := s.AddDomainsToBaseline(clientID, clientName, []string{domain})
+	return err
+}
 
-		// Get existing baseline
-		var baseline Baseline
-		data := b.Get([]byte(clientID))
+// AddDomainsToBaseline adds several domains to a client's baseline in one transaction
+// Returns the number of domains that were not already in the baseline
+func (s *BoltStore) AddDomainsToBaseline(clientID, clientName string, domains []string) (int, error) {
+	added := 0
 
-		if data != nil {
-			if err := json.Unmarshal(data, &baseline); err != nil {
-				return fmt.Errorf("failed to unmarshal baseline: %w", err)
-			}
-		} else {
-			baseline = Baseline{
-				ClientID:   clientID,
-				ClientName: clientName,
-				Domains:    []string{},
-			}
-		}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		added, err = addDomainsToBaseline(tx, clientID, clientName, domains)
+		return err
+	})
 
-		// Check if domain already exists
-		for _, d := range baseline.Domains {
-			if d == domain {
-				return nil // Already exists
-			}
+	return added, err
+}
+
+// addDomainsToBaseline adds domains to a client's baseline within tx
+func addDomainsToBaseline(tx *bolt.Tx, clientID, clientName string, domains []string) (int, error) {
+	b := tx.Bucket(baselineBucket)
+
+	// Get existing baseline
+	var baseline Baseline
+	data := b.Get([]byte(clientID))
+
+	if data != nil {
+		if err := json.Unmarshal(data, &baseline); err != nil {
+			return 0, fmt.Errorf("failed to unmarshal baseline: %w", err)
 		}
+	} else {
+		baseline = Baseline{
+			ClientID:   clientID,
+			ClientName: clientName,
+			Domains:    []string{},
+		}
+	}
 
-		// Add domain
+	// Skip domains that already exist
+	added := 0
+	known := make(map[string]bool, len(baseline.Domains))
+	for _, d := range baseline.Domains {
+		known[d] = true
+	}
+	for _, domain := range domains {
+		if domain == "" || known[domain] {
+			continue
+		}
+		known[domain] = true
 		baseline.Domains = append(baseline.Domains, domain)
-		baseline.LastUpdated = time.Now()
+		added++
+	}
 
-		// Save back to database
-		encoded, err := json.Marshal(baseline)
-		if err != nil {
-			return fmt.Errorf("failed to marshal baseline: %w", err)
-		}
+	if added == 0 && data != nil {
+		return 0, nil // Nothing new
+	}
+	if baseline.ClientName == "" {
+		baseline.ClientName = clientName
+	}
+	baseline.LastUpdated = time.Now()
+
+	// Save back to database
+	return added, putBaseline(tx, &baseline)
+}
 
-		return b.Put([]byte(clientID), encoded)
+// SaveBaseline stores a client's baseline, replacing any existing one
+func (s *BoltStore) SaveBaseline(baseline *Baseline) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putBaseline(tx, baseline)
 	})
 }
 
+// putBaseline stores a client's baseline within tx
+func putBaseline(tx *bolt.Tx, baseline *Baseline) error {
+	encoded, err := json.Marshal(baseline)
+	if err != nil {
+		return fmt.Errorf("failed to marshal baseline: %w", err)
+	}
+
+	return tx.Bucket(baselineBucket).Put([]byte(baseline.ClientID), encoded)
+}
+
 // HasSeenQuery checks if a query has been processed before
 func (s *BoltStore) HasSeenQuery(queryID string) (bool, error) {
 	var exists bool
@@ -177,6 +246,13 @@ func (s *BoltStore) HasDomainInBaseline(clientID, domain string) (bool, error) {
 	return false, nil
 }
 
+// DeleteBaseline removes a client's baseline
+func (s *BoltStore) DeleteBaseline(clientID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(baselineBucket).Delete([]byte(clientID))
+	})
+}
+
 // GetAllBaselines retrieves all client baselines
 func (s *BoltStore) GetAllBaselines() ([]Baseline, error) {
 	var baselines []Baseline
@@ -298,12 +374,48 @@ func (s *BoltStore) SaveAnomaly(anomaly *Anomaly) error {
 	return s.db.Update(func(tx *bolt.Tx) error {
 		b := tx.Bucket(anomaliesBucket)
 
+		// Set default type if not set
+		if anomaly.Type == "" {
+			anomaly.Type = AnomalyTypeLLM
+		}
+
+		// LLM verdicts are keyed by domain; merge with an existing verdict so
+		// clients and review status carry over. A reviewed verdict is kept as is
+		// unless the new classification is more severe, which goes back to pending.
+		if anomaly.ID == "" && anomaly.Type == AnomalyTypeLLM {
+			anomaly.ID = LLMAnomalyID(anomaly.Domain)
+
+			if data := b.Get([]byte(anomaly.ID)); data != nil {
+				var existing Anomaly
+				if err := json.Unmarshal(data, &existing); err == nil {
+					for _, client := range anomaly.Clients() {
+						existing.AddClient(client)
+					}
+					if anomaly.Status == "" && !escalates(&existing, anomaly) {
+						if existing.Status != "" && existing.Status != "pending" {
+							*anomaly = existing
+						} else {
+							anomaly.Status = existing.Status
+						}
+					}
+					anomaly.AffectedClients = existing.AffectedClients
+					anomaly.ClientID = existing.ClientID
+					anomaly.ClientName = existing.ClientName
+				}
+			}
+		}
+
 		// Generate ID if not set
 		if anomaly.ID == "" {
 			anomaly.ID = fmt.Sprintf("%s|%s|%s",
 				anomaly.ClientID,
 				anomaly.Domain,
 				anomaly.DetectedAt.Format(time.RFC3339))
+
+			// Prefix detector anomalies so they never collide with LLM verdicts
+			if anomaly.Type != AnomalyTypeLLM {
+				anomaly.ID = anomaly.Type + "|" + anomaly.ID
+			}
 		}
 
 		// Set default status if not set
@@ -320,6 +432,47 @@ func (s *BoltStore) SaveAnomaly(anomaly *Anomaly) error {
 	})
 }
 
+// classificationRank orders LLM classifications by severity
+var classificationRank = map[string]int{"Safe": 0, "Suspicious": 1, "Malicious": 2}
+
+// escalates reports whether a new verdict has a more severe classification than an existing one
+func escalates(existing, verdict *Anomaly) bool {
+	return classificationRank[verdict.Classification] > classificationRank[existing.Classification]
+}
+
+// AttachClientToAnomaly adds a client to the LLM anomaly for a domain, whether
+// pending or already reviewed. It returns attached=false if the domain has no LLM anomaly.
+func (s *BoltStore) AttachClientToAnomaly(domain string, client AffectedClient) (attached bool, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(anomaliesBucket)
+		id := LLMAnomalyID(domain)
+
+		data := b.Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+
+		var anomaly Anomaly
+		if err := json.Unmarshal(data, &anomaly); err != nil {
+			return fmt.Errorf("failed to unmarshal anomaly: %w", err)
+		}
+		attached = true
+
+		if !anomaly.AddClient(client) {
+			return nil
+		}
+
+		encoded, err := json.Marshal(anomaly)
+		if err != nil {
+			return fmt.Errorf("failed to marshal anomaly: %w", err)
+		}
+
+		return b.Put([]byte(id), encoded)
+	})
+
+	return attached, err
+}
+
 // GetRecentAnomalies retrieves anomalies detected within the specified duration
 func (s *BoltStore) GetRecentAnomalies(since time.Duration) ([]Anomaly, error) {
 	var anomalies []Anomaly
@@ -487,8 +640,200 @@ func (s *BoltStore) GetStats() (map[string]interface{}, error) {
 		analysisCount := tx.Bucket(analysesBucket).Stats().KeyN
 		stats["total_analyses"] = analysisCount
 
+		// Count first-seen events
+		stats["first_seen_events"] = tx.Bucket(eventsBucket).Stats().KeyN
+
+		// Persistent counters
+		stats["llm_calls_saved"] = int(getCounter(tx, CounterLLMCallsSaved))
+
 		return nil
 	})
 
 	return stats, err
 }
+
+// SaveClientStats stores the rolling query statistics for a client
+func (s *BoltStore) SaveClientStats(stats *ClientStats) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(clientStatsBucket)
+
+		encoded, err := json.Marshal(stats)
+		if err != nil {
+			return fmt.Errorf("failed to marshal client stats: %w", err)
+		}
+
+		return b.Put([]byte(stats.ClientID), encoded)
+	})
+}
+
+// GetAllClientStats retrieves the rolling query statistics for all clients
+func (s *BoltStore) GetAllClientStats() ([]ClientStats, error) {
+	var allStats []ClientStats
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(clientStatsBucket)
+
+		return b.ForEach(func(k, v []byte) error {
+			var stats ClientStats
+			if err := json.Unmarshal(v, &stats); err != nil {
+				return fmt.Errorf("failed to unmarshal client stats: %w", err)
+			}
+			allStats = append(allStats, stats)
+			return nil
+		})
+	})
+
+	return allStats, err
+}
+
+// GetActivityProfile retrieves the activity profile for a client
+func (s *BoltStore) GetActivityProfile(clientID string) (*ActivityProfile, error) {
+	var profile *ActivityProfile
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(activityBucket)
+		data := b.Get([]byte(clientID))
+
+		if data == nil {
+			// No activity recorded yet for this client
+			profile = &ActivityProfile{ClientID: clientID}
+			return nil
+		}
+
+		profile = &ActivityProfile{}
+		if err := json.Unmarshal(data, profile); err != nil {
+			return fmt.Errorf("failed to unmarshal activity profile: %w", err)
+		}
+		return nil
+	})
+
+	return profile, err
+}
+
+// SaveActivityProfiles stores activity profiles in a single transaction
+func (s *BoltStore) SaveActivityProfiles(profiles []*ActivityProfile) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(activityBucket)
+
+		for _, profile := range profiles {
+			encoded, err := json.Marshal(profile)
+			if err != nil {
+				return fmt.Errorf("failed to marshal activity profile: %w", err)
+			}
+			if err := b.Put([]byte(profile.ClientID), encoded); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// IncrementCounter adds one to a named persistent counter
+func (s *BoltStore) IncrementCounter(name string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(countersBucket)
+		value := getCounter(tx, name) + 1
+		return b.Put([]byte(name), []byte(strconv.FormatInt(value, 10)))
+	})
+}
+
+// getCounter reads a named persistent counter, returning 0 if it is unset
+func getCounter(tx *bolt.Tx, name string) int64 {
+	data := tx.Bucket(countersBucket).Get([]byte(name))
+	if data == nil {
+		return 0
+	}
+	value, _ := strconv.ParseInt(string(data), 10, 64)
+	return value
+}
+
+// SaveIncident stores an incident
+func (s *BoltStore) SaveIncident(incident *Incident) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(incidentsBucket)
+
+		encoded, err := json.Marshal(incident)
+		if err != nil {
+			return fmt.Errorf("failed to marshal incident: %w", err)
+		}
+
+		return b.Put([]byte(incident.ID), encoded)
+	})
+}
+
+// DeleteIncident removes an incident
+func (s *BoltStore) DeleteIncident(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(incidentsBucket).Delete([]byte(id))
+	})
+}
+
+// GetAllIncidents retrieves all incidents, optionally filtered by status
+func (s *BoltStore) GetAllIncidents(statusFilter string) ([]Incident, error) {
+	var incidents []Incident
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(incidentsBucket)
+
+		return b.ForEach(func(k, v []byte) error {
+			var incident Incident
+			if err := json.Unmarshal(v, &incident); err != nil {
+				return fmt.Errorf("failed to unmarshal incident: %w", err)
+			}
+
+			if statusFilter == "" || incident.Status == statusFilter {
+				incidents = append(incidents, incident)
+			}
+			return nil
+		})
+	})
+
+	return incidents, err
+}
+
+// GetIncidentByID retrieves a specific incident by its ID
+func (s *BoltStore) GetIncidentByID(id string) (*Incident, error) {
+	var incident *Incident
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(incidentsBucket).Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("incident not found: %s", id)
+		}
+
+		incident = &Incident{}
+		if err := json.Unmarshal(data, incident); err != nil {
+			return fmt.Errorf("failed to unmarshal incident: %w", err)
+		}
+		return nil
+	})
+
+	return incident, err
+}
+
+// UpdateIncidentStatus updates the status of an incident
+func (s *BoltStore) UpdateIncidentStatus(id, status string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(incidentsBucket)
+
+		data := b.Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("incident not found: %s", id)
+		}
+
+		var incident Incident
+		if err := json.Unmarshal(data, &incident); err != nil {
+			return fmt.Errorf("failed to unmarshal incident: %w", err)
+		}
+
+		incident.Status = status
+		incident.UpdatedAt = time.Now()
+
+		encoded, err := json.Marshal(incident)
+		if err != nil {
+			return fmt.Errorf("failed to marshal incident: %w", err)
+		}
+
+		return b.Put([]byte(id), encoded)
+	})
+}
@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// DefaultEventPageSize is the number of events returned when no limit is given
+	DefaultEventPageSize = 50
+
+	// MaxEventPageSize caps the number of events returned in one page
+	MaxEventPageSize = 500
+)
+
+// eventTimeFormat is a fixed-width UTC timestamp so event keys sort chronologically
+const eventTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// EventFilter selects a page of first-seen events, newest first
+type EventFilter struct {
+	ClientID string // Only events for this client
+	Before   string // Only events older than this event ID (the previous page's cursor)
+	Analyzed *bool  // Only analyzed (true) or unanalyzed (false) events
+	Limit    int    // Page size, defaults to DefaultEventPageSize
+}
+
+// EventPage is one page of first-seen events
+type EventPage struct {
+	Events     []AnomalyEvent `json:"events"`
+	NextCursor string         `json:"next_cursor,omitempty"` // Pass as Before to get the next page
+}
+
+// eventID builds the chronologically sortable key of a first-seen event
+func eventID(event *AnomalyEvent) string {
+	return fmt.Sprintf("%s|%s|%s", event.DetectedAt.UTC().Format(eventTimeFormat), event.Query.ClientID, event.Query.Domain)
+}
+
+// eventIndexKey identifies the first-seen event of a client and domain
+func eventIndexKey(clientID, domain string) []byte {
+	return []byte(clientID + "|" + strings.ToLower(strings.TrimSuffix(domain, ".")))
+}
+
+// SaveEvent stores a first-seen event, assigning its ID
+func (s *BoltStore) SaveEvent(event *AnomalyEvent) error {
+	if event.DetectedAt.IsZero() {
+		event.DetectedAt = time.Now()
+	}
+	event.ID = eventID(event)
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+
+		if err := tx.Bucket(eventsBucket).Put([]byte(event.ID), data); err != nil {
+			return err
+		}
+		return tx.Bucket(eventIndexBucket).Put(eventIndexKey(event.Query.ClientID, event.Query.Domain), []byte(event.ID))
+	})
+}
+
+// MarkEventAnalyzed flags the first-seen event of a client and domain as analyzed.
+// It is a no-op if no event was recorded.
+func (s *BoltStore) MarkEventAnalyzed(clientID, domain string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		id := tx.Bucket(eventIndexBucket).Get(eventIndexKey(clientID, domain))
+		if id == nil {
+			return nil
+		}
+
+		b := tx.Bucket(eventsBucket)
+		data := b.Get(id)
+		if data == nil {
+			return nil
+		}
+
+		var event AnomalyEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("failed to unmarshal event: %w", err)
+		}
+		if event.Analyzed {
+			return nil
+		}
+		event.Analyzed = true
+
+		updated, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal event: %w", err)
+		}
+		return b.Put(id, updated)
+	})
+}
+
+// GetUnanalyzedEvents returns the unanalyzed first-seen events detected in
+// [from, to), oldest first. A zero from or to leaves that end open.
+func (s *BoltStore) GetUnanalyzedEvents(from, to time.Time) ([]AnomalyEvent, error) {
+	var events []AnomalyEvent
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(eventsBucket).Cursor()
+
+		var end []byte
+		if !to.IsZero() {
+			end = []byte(to.UTC().Format(eventTimeFormat))
+		}
+
+		k, v := c.First()
+		if !from.IsZero() {
+			k, v = c.Seek([]byte(from.UTC().Format(eventTimeFormat)))
+		}
+
+		for ; k != nil && (end == nil || bytes.Compare(k, end) < 0); k, v = c.Next() {
+			var event AnomalyEvent
+			if err := json.Unmarshal(v, &event); err != nil {
+				continue // Skip malformed entries
+			}
+			if !event.Analyzed {
+				events = append(events, event)
+			}
+		}
+
+		return nil
+	})
+
+	return events, err
+}
+
+// GetEvents returns a page of first-seen events, newest first
+func (s *BoltStore) GetEvents(filter EventFilter) (*EventPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultEventPageSize
+	}
+	limit = min(limit, MaxEventPageSize)
+
+	page := &EventPage{Events: []AnomalyEvent{}}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(eventsBucket).Cursor()
+
+		var k, v []byte
+		if filter.Before != "" {
+			// Seek lands on the first key >= Before; step back to the older side
+			if k, _ = c.Seek([]byte(filter.Before)); k == nil {
+				k, v = c.Last()
+			} else {
+				k, v = c.Prev()
+			}
+		} else {
+			k, v = c.Last()
+		}
+
+		for ; k != nil; k, v = c.Prev() {
+			var event AnomalyEvent
+			if err := json.Unmarshal(v, &event); err != nil {
+				continue // Skip malformed entries
+			}
+			if filter.ClientID != "" && event.Query.ClientID != filter.ClientID {
+				continue
+			}
+			if filter.Analyzed != nil && event.Analyzed != *filter.Analyzed {
+				continue
+			}
+
+			if len(page.Events) == limit {
+				page.NextCursor = page.Events[limit-1].ID
+				break
+			}
+			page.Events = append(page.Events, event)
+		}
+
+		return nil
+	})
+
+	return page, err
+}
+
+// CleanOldEvents removes first-seen events detected more than olderThan ago
+func (s *BoltStore) CleanOldEvents(olderThan time.Duration) (int, error) {
+	cutoff := []byte(time.Now().Add(-olderThan).UTC().Format(eventTimeFormat))
+
+	deleted := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(eventsBucket)
+		index := tx.Bucket(eventIndexBucket)
+
+		// Keys start with the detection time, so old events are a prefix of the bucket
+		var keysToDelete [][]byte
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k, cutoff) < 0; k, _ = c.Next() {
+			keysToDelete = append(keysToDelete, append([]byte(nil), k...))
+		}
+
+		for _, key := range keysToDelete {
+			var event AnomalyEvent
+			if err := json.Unmarshal(b.Get(key), &event); err == nil {
+				indexKey := eventIndexKey(event.Query.ClientID, event.Query.Domain)
+				if bytes.Equal(index.Get(indexKey), key) {
+					if err := index.Delete(indexKey); err != nil {
+						return err
+					}
+				}
+			}
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+		}
+		deleted = len(keysToDelete)
+		return nil
+	})
+
+	return deleted, err
+}
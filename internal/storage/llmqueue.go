@@ -0,0 +1,328 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// QueuedAnalysis is a domain waiting for LLM analysis. Items stay in the queue
+// until a verdict is saved, so analyses survive restarts (at-least-once).
+type QueuedAnalysis struct {
+	Domain      string     `json:"domain"` // Normalized queue key
+	Query       DNSQuery   `json:"query"`
+	Waiting     []DNSQuery `json:"waiting,omitempty"` // Other clients that share the verdict
+	Attempts    int        `json:"attempts"`          // Failed analysis attempts
+	EnqueuedAt  time.Time  `json:"enqueued_at"`
+	NextAttempt time.Time  `json:"next_attempt"`
+	LastError   string     `json:"last_error,omitempty"`
+	FailedAt    time.Time  `json:"failed_at,omitzero"` // Set when dead-lettered
+}
+
+// Queries returns the analyzed query followed by the queries waiting on it
+func (q *QueuedAnalysis) Queries() []DNSQuery {
+	return append([]DNSQuery{q.Query}, q.Waiting...)
+}
+
+// queueKey normalizes a domain for the analysis queue
+func queueKey(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(domain, "."))
+}
+
+// queueIndexKey orders the pending queue by next attempt, then domain
+func queueIndexKey(item *QueuedAnalysis) []byte {
+	return []byte(item.NextAttempt.UTC().Format(eventTimeFormat) + "|" + item.Domain)
+}
+
+// EnqueueAnalysis adds a query to the analysis queue. If the domain is already
+// queued the query waits on that verdict instead and false is returned. A
+// dead-lettered domain queried by a new client goes back into the queue with a
+// fresh attempt count.
+func (s *BoltStore) EnqueueAnalysis(query DNSQuery) (bool, error) {
+	key := []byte(queueKey(query.Domain))
+	queued := false
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		queue := tx.Bucket(llmQueueBucket)
+		if data := queue.Get(key); data != nil {
+			var item QueuedAnalysis
+			if err := json.Unmarshal(data, &item); err != nil {
+				return fmt.Errorf("failed to unmarshal queued analysis: %w", err)
+			}
+			if !addWaiting(&item, query) {
+				return nil // Already queued for this client
+			}
+			return putQueuedAnalysis(queue, &item)
+		}
+
+		dead := tx.Bucket(llmDeadLetterBucket)
+		if data := dead.Get(key); data != nil {
+			var item QueuedAnalysis
+			if err := json.Unmarshal(data, &item); err != nil {
+				return fmt.Errorf("failed to unmarshal queued analysis: %w", err)
+			}
+			if !addWaiting(&item, query) {
+				return nil // Already waiting on the dead letter
+			}
+			if err := dead.Delete(key); err != nil {
+				return err
+			}
+			queued = true
+			resetAttempts(&item)
+			return enqueue(tx, &item)
+		}
+
+		now := time.Now()
+		queued = true
+		return enqueue(tx, &QueuedAnalysis{
+			Domain:      string(key),
+			Query:       query,
+			EnqueuedAt:  now,
+			NextAttempt: now,
+		})
+	})
+
+	return queued, err
+}
+
+// addWaiting adds a query to the clients waiting on an item. It returns false
+// if the query's client is already waiting.
+func addWaiting(item *QueuedAnalysis, query DNSQuery) bool {
+	for _, existing := range item.Queries() {
+		if existing.ClientID == query.ClientID {
+			return false
+		}
+	}
+	item.Waiting = append(item.Waiting, query)
+	return true
+}
+
+// DueAnalyses returns up to limit queued analyses whose next attempt is due,
+// in the order they became due, skipping domains for which skip returns true
+func (s *BoltStore) DueAnalyses(now time.Time, limit int, skip func(domain string) bool) ([]QueuedAnalysis, error) {
+	var due []QueuedAnalysis
+	end := now.UTC().Format(eventTimeFormat)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		queue := tx.Bucket(llmQueueBucket)
+		c := tx.Bucket(llmQueueIndexBucket).Cursor()
+		for k, domain := c.First(); k != nil && len(due) < limit; k, domain = c.Next() {
+			if len(k) < len(end) || string(k[:len(end)]) > end {
+				break // Index is ordered by next attempt, the rest are not due yet
+			}
+			if skip != nil && skip(string(domain)) {
+				continue
+			}
+
+			data := queue.Get(domain)
+			if data == nil {
+				continue
+			}
+			var item QueuedAnalysis
+			if err := json.Unmarshal(data, &item); err != nil {
+				continue // Skip malformed entries
+			}
+			due = append(due, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+// CompleteAnalysis removes a domain from the queue once its verdict is saved and
+// returns the item, including clients that started waiting while it was analyzed
+func (s *BoltStore) CompleteAnalysis(domain string) (*QueuedAnalysis, error) {
+	key := []byte(queueKey(domain))
+	var item *QueuedAnalysis
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(llmQueueBucket)
+		data := b.Get(key)
+		if data == nil {
+			return nil
+		}
+
+		item = &QueuedAnalysis{}
+		if err := json.Unmarshal(data, item); err != nil {
+			return fmt.Errorf("failed to unmarshal queued analysis: %w", err)
+		}
+		return dequeue(tx, item)
+	})
+
+	return item, err
+}
+
+// RescheduleAnalysis delays a queued analysis by delay(attempts). Failed attempts
+// are counted unless countAttempt is false (e.g. rate limiting); after maxAttempts
+// failures the item is moved to the dead-letter bucket and true is returned.
+func (s *BoltStore) RescheduleAnalysis(domain, lastError string, countAttempt bool, maxAttempts int, delay func(attempts int) time.Duration) (bool, error) {
+	key := []byte(queueKey(domain))
+	deadLettered := false
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(llmQueueBucket)
+		data := b.Get(key)
+		if data == nil {
+			return nil
+		}
+
+		var item QueuedAnalysis
+		if err := json.Unmarshal(data, &item); err != nil {
+			return fmt.Errorf("failed to unmarshal queued analysis: %w", err)
+		}
+		if err := dequeue(tx, &item); err != nil {
+			return err
+		}
+
+		item.LastError = lastError
+		if countAttempt {
+			item.Attempts++
+		}
+		item.NextAttempt = time.Now().Add(delay(item.Attempts))
+
+		if maxAttempts > 0 && item.Attempts >= maxAttempts {
+			item.FailedAt = time.Now()
+			deadLettered = true
+			return putQueuedAnalysis(tx.Bucket(llmDeadLetterBucket), &item)
+		}
+
+		return enqueue(tx, &item)
+	})
+
+	return deadLettered, err
+}
+
+// GetQueuedAnalyses returns the pending analyses, oldest first
+func (s *BoltStore) GetQueuedAnalyses() ([]QueuedAnalysis, error) {
+	return s.listQueuedAnalyses(llmQueueBucket)
+}
+
+// GetDeadLetters returns the analyses that exhausted their attempts, oldest first
+func (s *BoltStore) GetDeadLetters() ([]QueuedAnalysis, error) {
+	return s.listQueuedAnalyses(llmDeadLetterBucket)
+}
+
+// RetryDeadLetters moves all dead-lettered analyses back into the queue with a fresh attempt count
+func (s *BoltStore) RetryDeadLetters() (int, error) {
+	retried := 0
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		dead := tx.Bucket(llmDeadLetterBucket)
+
+		var keys [][]byte
+		if err := dead.ForEach(func(k, v []byte) error {
+			var item QueuedAnalysis
+			if err := json.Unmarshal(v, &item); err != nil {
+				return nil // Skip malformed entries
+			}
+			resetAttempts(&item)
+			if err := enqueue(tx, &item); err != nil {
+				return err
+			}
+			keys = append(keys, append([]byte(nil), k...))
+			return nil
+		}); err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if err := dead.Delete(key); err != nil {
+				return err
+			}
+		}
+		retried = len(keys)
+		return nil
+	})
+
+	return retried, err
+}
+
+// CountQueuedAnalyses returns the number of pending and dead-lettered analyses
+func (s *BoltStore) CountQueuedAnalyses() (pending, dead int, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		pending = tx.Bucket(llmQueueBucket).Stats().KeyN
+		dead = tx.Bucket(llmDeadLetterBucket).Stats().KeyN
+		return nil
+	})
+	return pending, dead, err
+}
+
+// listQueuedAnalyses reads every item of a queue bucket, oldest first
+func (s *BoltStore) listQueuedAnalyses(bucket []byte) ([]QueuedAnalysis, error) {
+	items := []QueuedAnalysis{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(k, v []byte) error {
+			var item QueuedAnalysis
+			if err := json.Unmarshal(v, &item); err != nil {
+				return nil // Skip malformed entries
+			}
+			items = append(items, item)
+			return nil
+		})
+	})
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].EnqueuedAt.Before(items[j].EnqueuedAt)
+	})
+	return items, err
+}
+
+// resetAttempts makes a dead-lettered item due now with a fresh attempt count
+func resetAttempts(item *QueuedAnalysis) {
+	item.Attempts = 0
+	item.NextAttempt = time.Now()
+	item.FailedAt = time.Time{}
+}
+
+// enqueue stores a pending item and indexes it by its next attempt
+func enqueue(tx *bolt.Tx, item *QueuedAnalysis) error {
+	if err := putQueuedAnalysis(tx.Bucket(llmQueueBucket), item); err != nil {
+		return err
+	}
+	return tx.Bucket(llmQueueIndexBucket).Put(queueIndexKey(item), []byte(item.Domain))
+}
+
+// dequeue removes a pending item and its index entry
+func dequeue(tx *bolt.Tx, item *QueuedAnalysis) error {
+	if err := tx.Bucket(llmQueueIndexBucket).Delete(queueIndexKey(item)); err != nil {
+		return err
+	}
+	return tx.Bucket(llmQueueBucket).Delete([]byte(item.Domain))
+}
+
+// rebuildQueueIndex indexes every pending item by its next attempt, e.g. for
+// queues written before the index existed
+func rebuildQueueIndex(tx *bolt.Tx) error {
+	if err := tx.DeleteBucket(llmQueueIndexBucket); err != nil {
+		return fmt.Errorf("failed to reset analysis queue index: %w", err)
+	}
+	index, err := tx.CreateBucket(llmQueueIndexBucket)
+	if err != nil {
+		return fmt.Errorf("failed to create analysis queue index: %w", err)
+	}
+
+	return tx.Bucket(llmQueueBucket).ForEach(func(k, v []byte) error {
+		var item QueuedAnalysis
+		if err := json.Unmarshal(v, &item); err != nil {
+			return nil // Skip malformed entries
+		}
+		return index.Put(queueIndexKey(&item), k)
+	})
+}
+
+// putQueuedAnalysis stores a queue item under its domain
+func putQueuedAnalysis(b *bolt.Bucket, item *QueuedAnalysis) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return fmt.Errorf("failed to marshal queued analysis: %w", err)
+	}
+	return b.Put([]byte(item.Domain), data)
+}
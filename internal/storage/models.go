@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"strings"
 	"time"
 )
 
@@ -10,11 +11,17 @@ type DNSQuery struct {
 	ClientName string    `json:"client_name"`
 	Domain     string    `json:"domain"`
 	Timestamp  time.Time `json:"timestamp"`
-	QueryType  string    `json:"query_type"`
-	Answer     string    `json:"answer,omitempty"`
-	Reason     string    `json:"reason,omitempty"` // AdGuard's filtering reason
-	Response   string    `json:"response,omitempty"`
-	Upstream   string    `json:"upstream,omitempty"`
+	// UnicodeDomain is the decoded form of an internationalized (punycode) domain
+	UnicodeDomain string `json:"unicode_domain,omitempty"`
+	QueryType     string `json:"query_type"`
+	Answer        string `json:"answer,omitempty"`
+	Reason        string `json:"reason,omitempty"`   // AdGuard's filtering reason
+	Response      string `json:"response,omitempty"` // DNS response code (NOERROR, NXDOMAIN, ...)
+	Upstream      string `json:"upstream,omitempty"`
+
+	// OffHours is set by the baseline analyzer when the query happened outside
+	// the client's normal active hours
+	OffHours bool `json:"off_hours,omitempty"`
 }
 
 // Baseline represents the known domains for a specific client
@@ -27,13 +34,43 @@ type Baseline struct {
 
 // AnomalyEvent represents a first-seen domain for a client
 type AnomalyEvent struct {
+	ID         string    `json:"id"`
 	Query      DNSQuery  `json:"query"`
 	DetectedAt time.Time `json:"detected_at"`
+
+	// Analyzed is set once the domain has a verdict for this client (from the
+	// LLM, an existing anomaly or the top-sites list)
+	Analyzed bool `json:"analyzed"`
+}
+
+// CounterLLMCallsSaved counts first-seen domains that skipped LLM analysis
+// because they are on the top-sites list
+const CounterLLMCallsSaved = "llm_calls_saved"
+
+// Anomaly types identify which detector raised an anomaly
+const (
+	AnomalyTypeLLM         = "llm"
+	AnomalyTypeBeaconing   = "beaconing"
+	AnomalyTypeVolume      = "volume"
+	AnomalyTypeNewDomain   = "new_domain"
+	AnomalyTypeTyposquat   = "typosquat"
+	AnomalyTypeRule        = "rule"
+	AnomalyTypeThreatIntel = "threat_intel"
+)
+
+// NormalizeAnomalyType returns the anomaly type, treating records saved before
+// types existed as LLM verdicts
+func NormalizeAnomalyType(t string) string {
+	if t == "" {
+		return AnomalyTypeLLM
+	}
+	return t
 }
 
-// Anomaly represents a detected security threat from LLM analysis
+// Anomaly represents a detected security threat from LLM analysis or a detector
 type Anomaly struct {
 	ID              string    `json:"id,omitempty"`
+	Type            string    `json:"type,omitempty"` // llm (default), beaconing, volume, new_domain, typosquat, rule
 	Domain          string    `json:"domain"`
 	ClientID        string    `json:"client_id"`
 	ClientName      string    `json:"client_name"`
@@ -44,6 +81,124 @@ type Anomaly struct {
 	SuggestedAction string    `json:"suggested_action"` // Investigate or Block
 	DetectedAt      time.Time `json:"detected_at"`
 	Status          string    `json:"status"` // pending, approved, blocked
+	OffHours        bool      `json:"off_hours,omitempty"`
+	DomainCreatedAt time.Time `json:"domain_created_at,omitzero"` // From WHOIS, when known
+
+	// Details holds detector-specific measurements (e.g. beaconing interval)
+	Details map[string]string `json:"details,omitempty"`
+
+	// AffectedClients lists every client that queried the domain. LLM anomalies
+	// are keyed by domain, so later clients attach here instead of being re-analyzed.
+	AffectedClients []AffectedClient `json:"affected_clients,omitempty"`
+}
+
+// AffectedClient is a client that queried an anomalous domain
+type AffectedClient struct {
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	FirstSeen  time.Time `json:"first_seen"`
+}
+
+// LLMAnomalyID returns the storage key of the LLM anomaly for a domain
+func LLMAnomalyID(domain string) string {
+	return AnomalyTypeLLM + "|" + strings.ToLower(strings.TrimSuffix(domain, "."))
+}
+
+// Clients returns the affected clients, falling back to the anomaly's own
+// client for records saved before clients were grouped
+func (a *Anomaly) Clients() []AffectedClient {
+	if len(a.AffectedClients) > 0 {
+		return a.AffectedClients
+	}
+	return []AffectedClient{{ClientID: a.ClientID, ClientName: a.ClientName, FirstSeen: a.DetectedAt}}
+}
+
+// AddClient records a client as affected, keeping the earliest first-seen time.
+// Returns true if the client was not already listed.
+func (a *Anomaly) AddClient(client AffectedClient) bool {
+	a.AffectedClients = a.Clients()
+	for i, existing := range a.AffectedClients {
+		if existing.ClientID == client.ClientID {
+			if client.FirstSeen.Before(existing.FirstSeen) {
+				a.AffectedClients[i].FirstSeen = client.FirstSeen
+			}
+			return false
+		}
+	}
+	a.AffectedClients = append(a.AffectedClients, client)
+	return true
+}
+
+// Incident statuses
+const (
+	IncidentStatusOpen          = "open"
+	IncidentStatusInvestigating = "investigating"
+	IncidentStatusResolved      = "resolved"
+	IncidentStatusDismissed     = "dismissed"
+)
+
+// Incident groups anomalies from one client that happened close together in time
+type Incident struct {
+	ID           string          `json:"id"`
+	ClientID     string          `json:"client_id"`
+	ClientName   string          `json:"client_name"`
+	Severity     string          `json:"severity"` // low, medium, high, critical
+	MaxRiskScore int             `json:"max_risk_score"`
+	Status       string          `json:"status"` // open, investigating, resolved, dismissed
+	StartedAt    time.Time       `json:"started_at"`
+	LastSeenAt   time.Time       `json:"last_seen_at"`
+	AnomalyIDs   []string        `json:"anomaly_ids"`
+	Timeline     []IncidentEvent `json:"timeline"`
+	CreatedAt    time.Time       `json:"created_at"`
+	UpdatedAt    time.Time       `json:"updated_at"`
+}
+
+// IncidentEvent is one anomaly on an incident's timeline
+type IncidentEvent struct {
+	Time           time.Time `json:"time"`
+	AnomalyID      string    `json:"anomaly_id"`
+	Type           string    `json:"type"`
+	Domain         string    `json:"domain"`
+	Classification string    `json:"classification"`
+	RiskScore      int       `json:"risk_score"`
+	Explanation    string    `json:"explanation"`
+}
+
+// ClientStats holds rolling per-client query statistics and their EWMA baselines
+type ClientStats struct {
+	ClientID   string `json:"client_id"`
+	ClientName string `json:"client_name"`
+
+	// Most recently completed minute
+	LastMinute       time.Time `json:"last_minute"`
+	QueriesPerMinute int       `json:"queries_per_minute"`
+	NXDomainRatio    float64   `json:"nxdomain_ratio"`
+	BlockedRatio     float64   `json:"blocked_ratio"`
+
+	// EWMA baselines
+	QPMMean        float64 `json:"qpm_mean"`
+	QPMStdDev      float64 `json:"qpm_stddev"`
+	NXDomainMean   float64 `json:"nxdomain_mean"`
+	NXDomainStdDev float64 `json:"nxdomain_stddev"`
+	BlockedMean    float64 `json:"blocked_mean"`
+	BlockedStdDev  float64 `json:"blocked_stddev"`
+
+	// Lifetime totals
+	MinutesObserved int   `json:"minutes_observed"`
+	TotalQueries    int64 `json:"total_queries"`
+	NXDomainQueries int64 `json:"nxdomain_queries"`
+	BlockedQueries  int64 `json:"blocked_queries"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ActivityProfile is an hourly and weekday histogram of a client's queries
+type ActivityProfile struct {
+	ClientID  string    `json:"client_id"`
+	Hourly    [24]int64 `json:"hourly"`  // Queries per hour of day (local time)
+	Weekday   [7]int64  `json:"weekday"` // Queries per day of week, Sunday = 0
+	Total     int64     `json:"total"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
 
 // WHOISData contains enrichment information about a domain
@@ -56,6 +211,29 @@ type WHOISData struct {
 	ExpiryDate  string    `json:"expiry_date,omitempty"`
 	NameServers []string  `json:"name_servers,omitempty"`
 	LookedUpAt  time.Time `json:"looked_up_at"`
+
+	// Parsed dates (zero when the raw date could not be parsed)
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// AgeDays returns the domain's age in whole days, or false if the creation date is unknown
+func (w *WHOISData) AgeDays(now time.Time) (int, bool) {
+	if w == nil || w.CreatedAt.IsZero() {
+		return 0, false
+	}
+	return int(now.Sub(w.CreatedAt).Hours() / 24), true
+}
+
+// IsNXDomain returns true if the upstream answered NXDOMAIN
+func (q *DNSQuery) IsNXDomain() bool {
+	return q.Response == "NXDOMAIN"
+}
+
+// IsBlocked returns true if AdGuard Home filtered the query
+func (q *DNSQuery) IsBlocked() bool {
+	return strings.HasPrefix(q.Reason, "Filtered")
 }
 
 // QueryID generates a unique ID for deduplication
@@ -0,0 +1,294 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Snapshot reasons
+const (
+	SnapshotReasonScheduled   = "scheduled"
+	SnapshotReasonManual      = "manual"
+	SnapshotReasonPreRollback = "pre-rollback"
+)
+
+// BaselineSnapshot is a point-in-time copy of every client baseline
+type BaselineSnapshot struct {
+	ID        string     `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Reason    string     `json:"reason"` // scheduled, manual, pre-rollback
+	Baselines []Baseline `json:"baselines"`
+}
+
+// SnapshotSummary describes a snapshot without its baselines
+type SnapshotSummary struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Reason    string    `json:"reason"`
+	Clients   int       `json:"clients"`
+	Domains   int       `json:"domains"`
+}
+
+// BaselineDiff lists the domains added and removed between two versions of a client's baseline
+type BaselineDiff struct {
+	ClientID string   `json:"client_id"`
+	From     string   `json:"from"`
+	To       string   `json:"to"`
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+}
+
+// Summary returns the snapshot's summary
+func (s *BaselineSnapshot) Summary() SnapshotSummary {
+	summary := SnapshotSummary{
+		ID:        s.ID,
+		CreatedAt: s.CreatedAt,
+		Reason:    s.Reason,
+		Clients:   len(s.Baselines),
+	}
+	for _, baseline := range s.Baselines {
+		summary.Domains += len(baseline.Domains)
+	}
+	return summary
+}
+
+// ClientBaseline returns the client's baseline in the snapshot, or nil if the
+// client had no baseline when the snapshot was taken
+func (s *BaselineSnapshot) ClientBaseline(clientID string) *Baseline {
+	for i := range s.Baselines {
+		if s.Baselines[i].ClientID == clientID {
+			return &s.Baselines[i]
+		}
+	}
+	return nil
+}
+
+// CreateBaselineSnapshot copies all baselines into a new snapshot and deletes
+// the oldest snapshots beyond retain (0 keeps all)
+func (s *BoltStore) CreateBaselineSnapshot(reason string, retain int) (*BaselineSnapshot, error) {
+	var snapshot *BaselineSnapshot
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		var err error
+		snapshot, err = createSnapshot(tx, reason, retain, "")
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// createSnapshot copies all baselines into a new snapshot within tx and prunes
+// the oldest snapshots beyond retain, never pruning the snapshot with ID keep
+func createSnapshot(tx *bolt.Tx, reason string, retain int, keep string) (*BaselineSnapshot, error) {
+	snapshot := &BaselineSnapshot{
+		CreatedAt: time.Now().UTC(),
+		Reason:    reason,
+		Baselines: []Baseline{},
+	}
+	// Fixed-width timestamps sort chronologically ("...:00Z" would sort after "...:00.1Z")
+	snapshot.ID = snapshot.CreatedAt.Format(eventTimeFormat)
+
+	if err := tx.Bucket(baselineBucket).ForEach(func(k, v []byte) error {
+		var baseline Baseline
+		if err := json.Unmarshal(v, &baseline); err != nil {
+			return fmt.Errorf("failed to unmarshal baseline: %w", err)
+		}
+		snapshot.Baselines = append(snapshot.Baselines, baseline)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	b := tx.Bucket(snapshotsBucket)
+	encoded, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+	if err := b.Put([]byte(snapshot.ID), encoded); err != nil {
+		return nil, err
+	}
+
+	if retain > 0 {
+		if err := pruneSnapshots(b, retain, keep); err != nil {
+			return nil, err
+		}
+	}
+	return snapshot, nil
+}
+
+// pruneSnapshots deletes the oldest snapshots beyond retain, except keep.
+// Keys are ordered by the time they encode, since snapshots taken before IDs
+// were fixed-width do not sort lexically.
+func pruneSnapshots(b *bolt.Bucket, retain int, keep string) error {
+	type snapshotKey struct {
+		key []byte
+		at  time.Time
+	}
+
+	var keys []snapshotKey
+	if err := b.ForEach(func(k, _ []byte) error {
+		at, err := time.Parse(time.RFC3339Nano, string(k))
+		if err != nil {
+			return nil // Leave unrecognized keys alone
+		}
+		keys = append(keys, snapshotKey{key: append([]byte(nil), k...), at: at})
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].at.Before(keys[j].at) })
+	for _, k := range keys[:max(0, len(keys)-retain)] {
+		if string(k.key) == keep {
+			continue
+		}
+		if err := b.Delete(k.key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListBaselineSnapshots returns summaries of all snapshots, newest first
+func (s *BoltStore) ListBaselineSnapshots() ([]SnapshotSummary, error) {
+	var summaries []SnapshotSummary
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(snapshotsBucket).ForEach(func(k, v []byte) error {
+			var snapshot BaselineSnapshot
+			if err := json.Unmarshal(v, &snapshot); err != nil {
+				return fmt.Errorf("failed to unmarshal snapshot: %w", err)
+			}
+			summaries = append(summaries, snapshot.Summary())
+			return nil
+		})
+	})
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].CreatedAt.After(summaries[j].CreatedAt)
+	})
+	return summaries, err
+}
+
+// GetBaselineSnapshot retrieves a snapshot by ID
+func (s *BoltStore) GetBaselineSnapshot(id string) (*BaselineSnapshot, error) {
+	var snapshot *BaselineSnapshot
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(snapshotsBucket).Get([]byte(id))
+		if data == nil {
+			return fmt.Errorf("snapshot not found: %s", id)
+		}
+
+		snapshot = &BaselineSnapshot{}
+		if err := json.Unmarshal(data, snapshot); err != nil {
+			return fmt.Errorf("failed to unmarshal snapshot: %w", err)
+		}
+		return nil
+	})
+
+	return snapshot, err
+}
+
+// RollbackClientBaseline restores a client's baseline to its state in a snapshot.
+// The current baselines are snapshotted first, in the same transaction, so the
+// rollback can be undone.
+func (s *BoltStore) RollbackClientBaseline(snapshotID, clientID string, retain int) (*BaselineDiff, error) {
+	var diff *BaselineDiff
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		data := tx.Bucket(snapshotsBucket).Get([]byte(snapshotID))
+		if data == nil {
+			return fmt.Errorf("snapshot not found: %s", snapshotID)
+		}
+		var snapshot BaselineSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return fmt.Errorf("failed to unmarshal snapshot: %w", err)
+		}
+
+		b := tx.Bucket(baselineBucket)
+		var current *Baseline
+		if data := b.Get([]byte(clientID)); data != nil {
+			current = &Baseline{}
+			if err := json.Unmarshal(data, current); err != nil {
+				return fmt.Errorf("failed to unmarshal baseline: %w", err)
+			}
+		}
+
+		// Keep the snapshot being restored even if retention would prune it
+		if _, err := createSnapshot(tx, SnapshotReasonPreRollback, retain, snapshotID); err != nil {
+			return fmt.Errorf("failed to snapshot current baselines: %w", err)
+		}
+
+		target := snapshot.ClientBaseline(clientID)
+		diff = DiffBaselines(clientID, "current", snapshotID, current, target)
+		if target == nil {
+			// The client had no baseline yet; removing it makes every domain first-seen again
+			if err := b.Delete([]byte(clientID)); err != nil {
+				return fmt.Errorf("failed to delete baseline: %w", err)
+			}
+			return nil
+		}
+
+		restored := *target
+		restored.LastUpdated = time.Now()
+		encoded, err := json.Marshal(&restored)
+		if err != nil {
+			return fmt.Errorf("failed to marshal baseline: %w", err)
+		}
+		if err := b.Put([]byte(clientID), encoded); err != nil {
+			return fmt.Errorf("failed to restore baseline: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return diff, nil
+}
+
+// DiffBaselines compares two versions of a client's baseline; nil means no baseline
+func DiffBaselines(clientID, fromLabel, toLabel string, from, to *Baseline) *BaselineDiff {
+	diff := &BaselineDiff{
+		ClientID: clientID,
+		From:     fromLabel,
+		To:       toLabel,
+		Added:    []string{},
+		Removed:  []string{},
+	}
+
+	before := make(map[string]bool)
+	if from != nil {
+		for _, domain := range from.Domains {
+			before[domain] = true
+		}
+	}
+	after := make(map[string]bool)
+	if to != nil {
+		for _, domain := range to.Domains {
+			after[domain] = true
+		}
+	}
+
+	for domain := range after {
+		if !before[domain] {
+			diff.Added = append(diff.Added, domain)
+		}
+	}
+	for domain := range before {
+		if !after[domain] {
+			diff.Removed = append(diff.Removed, domain)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+
+	return diff
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultTierComparisonLimit is the number of comparisons returned when no limit is given
+const DefaultTierComparisonLimit = 50
+
+// TierVerdict is one stage's verdict in tiered analysis
+type TierVerdict struct {
+	Provider        string `json:"provider"`
+	Classification  string `json:"classification"`
+	RiskScore       int    `json:"risk_score"`
+	SuggestedAction string `json:"suggested_action"`
+	Explanation     string `json:"explanation"`
+}
+
+// TierComparison records the first-stage and escalation verdicts for an escalated domain
+type TierComparison struct {
+	ID         string      `json:"id"`
+	Domain     string      `json:"domain"`
+	ClientID   string      `json:"client_id"`
+	ComparedAt time.Time   `json:"compared_at"`
+	Triage     TierVerdict `json:"triage"`
+	Escalation TierVerdict `json:"escalation"`
+	Agreed     bool        `json:"agreed"` // Both stages gave the same classification
+}
+
+// TierAgreement summarizes how often the escalation stage agreed with the first stage
+type TierAgreement struct {
+	Escalations   int                       `json:"escalations"`
+	Agreed        int                       `json:"agreed"`
+	AgreementRate float64                   `json:"agreement_rate"`
+	Matrix        map[string]map[string]int `json:"matrix"` // Triage classification -> escalation classification -> count
+}
+
+// SaveTierComparison stores the verdicts of both tiers for an escalated domain
+func (s *BoltStore) SaveTierComparison(comparison *TierComparison) error {
+	if comparison.ComparedAt.IsZero() {
+		comparison.ComparedAt = time.Now()
+	}
+	comparison.Agreed = comparison.Triage.Classification == comparison.Escalation.Classification
+	comparison.ID = fmt.Sprintf("%s|%s|%s", comparison.ComparedAt.UTC().Format(eventTimeFormat), comparison.ClientID, comparison.Domain)
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(comparison)
+		if err != nil {
+			return fmt.Errorf("failed to marshal tier comparison: %w", err)
+		}
+		return tx.Bucket(tierComparisonsBucket).Put([]byte(comparison.ID), data)
+	})
+}
+
+// CleanOldTierComparisons removes tier comparisons recorded more than olderThan ago
+func (s *BoltStore) CleanOldTierComparisons(olderThan time.Duration) (int, error) {
+	cutoff := []byte(time.Now().Add(-olderThan).UTC().Format(eventTimeFormat))
+
+	deleted := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(tierComparisonsBucket)
+
+		// Keys start with the comparison time, so old comparisons are a prefix of the bucket
+		var keysToDelete [][]byte
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k, cutoff) < 0; k, _ = c.Next() {
+			keysToDelete = append(keysToDelete, append([]byte(nil), k...))
+		}
+
+		for _, key := range keysToDelete {
+			if err := b.Delete(key); err != nil {
+				return err
+			}
+		}
+		deleted = len(keysToDelete)
+		return nil
+	})
+
+	return deleted, err
+}
+
+// GetTierComparisons returns the most recent tier comparisons, newest first
+func (s *BoltStore) GetTierComparisons(limit int) ([]TierComparison, error) {
+	if limit <= 0 {
+		limit = DefaultTierComparisonLimit
+	}
+
+	comparisons := []TierComparison{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(tierComparisonsBucket).Cursor()
+		for k, v := c.Last(); k != nil && len(comparisons) < limit; k, v = c.Prev() {
+			var comparison TierComparison
+			if err := json.Unmarshal(v, &comparison); err != nil {
+				continue
+			}
+			comparisons = append(comparisons, comparison)
+		}
+		return nil
+	})
+	return comparisons, err
+}
+
+// GetTierAgreement summarizes tier agreement over comparisons recorded since the
+// given time (the zero time includes all comparisons)
+func (s *BoltStore) GetTierAgreement(since time.Time) (*TierAgreement, error) {
+	agreement := &TierAgreement{Matrix: make(map[string]map[string]int)}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(tierComparisonsBucket).Cursor()
+		for k, v := c.Seek([]byte(since.UTC().Format(eventTimeFormat))); k != nil; k, v = c.Next() {
+			var comparison TierComparison
+			if err := json.Unmarshal(v, &comparison); err != nil {
+				continue
+			}
+
+			agreement.Escalations++
+			if comparison.Agreed {
+				agreement.Agreed++
+			}
+
+			row := agreement.Matrix[comparison.Triage.Classification]
+			if row == nil {
+				row = make(map[string]int)
+				agreement.Matrix[comparison.Triage.Classification] = row
+			}
+			row[comparison.Escalation.Classification]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if agreement.Escalations > 0 {
+		agreement.AgreementRate = float64(agreement.Agreed) / float64(agreement.Escalations)
+	}
+	return agreement, nil
+}
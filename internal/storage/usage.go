@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// UsageDateFormat is the format of daily usage dates
+const UsageDateFormat = "2006-01-02"
+
+// TokenUsage counts the tokens an LLM used and what they cost
+type TokenUsage struct {
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	Cost             float64 `json:"cost_usd"`
+}
+
+// add adds other's tokens and cost to u
+func (u *TokenUsage) add(other TokenUsage) {
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.Cost += other.Cost
+}
+
+// ProviderUsage is the usage of one provider and model
+type ProviderUsage struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	Requests int    `json:"requests"`
+	TokenUsage
+}
+
+// ClientUsage is the usage attributed to one client's queries. Batch requests
+// are split evenly between the queries in the batch.
+type ClientUsage struct {
+	ClientID   string `json:"client_id"`
+	ClientName string `json:"client_name"`
+	Domains    int    `json:"domains"`
+	TokenUsage
+}
+
+// DailyUsage is the LLM usage of one day (in local time), by provider and client
+type DailyUsage struct {
+	Date      string          `json:"date,omitempty"`
+	Requests  int             `json:"requests"`
+	Providers []ProviderUsage `json:"providers"`
+	Clients   []ClientUsage   `json:"clients"`
+	TokenUsage
+}
+
+// Add merges one request's provider usage and per-client usage into the day
+func (d *DailyUsage) Add(provider ProviderUsage, clients []ClientUsage) {
+	d.Requests += provider.Requests
+	d.TokenUsage.add(provider.TokenUsage)
+	d.addProvider(provider)
+	for _, client := range clients {
+		d.addClient(client)
+	}
+}
+
+// Merge adds another day's usage to d
+func (d *DailyUsage) Merge(other DailyUsage) {
+	d.Requests += other.Requests
+	d.TokenUsage.add(other.TokenUsage)
+	for _, provider := range other.Providers {
+		d.addProvider(provider)
+	}
+	for _, client := range other.Clients {
+		d.addClient(client)
+	}
+}
+
+// addProvider adds usage to the day's entry for a provider and model
+func (d *DailyUsage) addProvider(provider ProviderUsage) {
+	for i := range d.Providers {
+		if d.Providers[i].Provider == provider.Provider && d.Providers[i].Model == provider.Model {
+			d.Providers[i].Requests += provider.Requests
+			d.Providers[i].TokenUsage.add(provider.TokenUsage)
+			return
+		}
+	}
+	d.Providers = append(d.Providers, provider)
+}
+
+// addClient adds usage to the day's entry for a client
+func (d *DailyUsage) addClient(client ClientUsage) {
+	for i := range d.Clients {
+		if d.Clients[i].ClientID == client.ClientID {
+			d.Clients[i].ClientName = client.ClientName
+			d.Clients[i].Domains += client.Domains
+			d.Clients[i].TokenUsage.add(client.TokenUsage)
+			return
+		}
+	}
+	d.Clients = append(d.Clients, client)
+}
+
+// RecordUsage adds one request's usage to the day it was made
+func (s *BoltStore) RecordUsage(at time.Time, provider ProviderUsage, clients []ClientUsage) error {
+	date := at.Format(UsageDateFormat)
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(llmUsageBucket)
+
+		day := DailyUsage{Date: date}
+		if data := b.Get([]byte(date)); data != nil {
+			if err := json.Unmarshal(data, &day); err != nil {
+				return fmt.Errorf("failed to unmarshal usage: %w", err)
+			}
+		}
+		day.Add(provider, clients)
+
+		data, err := json.Marshal(day)
+		if err != nil {
+			return fmt.Errorf("failed to marshal usage: %w", err)
+		}
+		return b.Put([]byte(date), data)
+	})
+}
+
+// GetUsage returns the daily usage from one date to another (inclusive), oldest first
+func (s *BoltStore) GetUsage(from, to time.Time) ([]DailyUsage, error) {
+	start := []byte(from.Format(UsageDateFormat))
+	end := to.Format(UsageDateFormat)
+
+	days := []DailyUsage{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(llmUsageBucket).Cursor()
+		for k, v := c.Seek(start); k != nil && string(k) <= end; k, v = c.Next() {
+			var day DailyUsage
+			if err := json.Unmarshal(v, &day); err != nil {
+				continue
+			}
+			SortUsage(&day)
+			days = append(days, day)
+		}
+		return nil
+	})
+	return days, err
+}
+
+// SortUsage orders a day's providers and clients by cost, highest first
+func SortUsage(day *DailyUsage) {
+	sort.SliceStable(day.Providers, func(i, j int) bool { return day.Providers[i].Cost > day.Providers[j].Cost })
+	sort.SliceStable(day.Clients, func(i, j int) bool { return day.Clients[i].Cost > day.Clients[j].Cost })
+}
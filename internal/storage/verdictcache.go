@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// cachedVerdict is a stored LLM verdict and when it was cached
+type cachedVerdict struct {
+	CachedAt time.Time       `json:"cached_at"`
+	Verdict  json.RawMessage `json:"verdict"`
+}
+
+// verdictCacheKey identifies a cached verdict by domain and, optionally, query type
+func verdictCacheKey(domain, queryType string) []byte {
+	key := strings.ToLower(strings.TrimSuffix(domain, "."))
+	if queryType != "" {
+		key += "|" + strings.ToUpper(queryType)
+	}
+	return []byte(key)
+}
+
+// CacheVerdict stores the latest verdict for a domain (and query type, if given)
+func (s *BoltStore) CacheVerdict(domain, queryType string, verdict interface{}) error {
+	encoded, err := json.Marshal(verdict)
+	if err != nil {
+		return fmt.Errorf("failed to marshal verdict: %w", err)
+	}
+	data, err := json.Marshal(cachedVerdict{CachedAt: time.Now(), Verdict: encoded})
+	if err != nil {
+		return fmt.Errorf("failed to marshal cached verdict: %w", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(verdictCacheBucket).Put(verdictCacheKey(domain, queryType), data)
+	})
+}
+
+// GetCachedVerdict decodes the cached verdict for a domain (and query type, if given)
+// into verdict. It returns false if there is no verdict cached within the TTL.
+func (s *BoltStore) GetCachedVerdict(domain, queryType string, ttl time.Duration, verdict interface{}) (bool, error) {
+	var cached cachedVerdict
+	found := false
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(verdictCacheBucket).Get(verdictCacheKey(domain, queryType))
+		if data == nil {
+			return nil
+		}
+		if err := json.Unmarshal(data, &cached); err != nil {
+			return fmt.Errorf("failed to unmarshal cached verdict: %w", err)
+		}
+		found = time.Since(cached.CachedAt) <= ttl
+		return nil
+	})
+	if err != nil || !found {
+		return false, err
+	}
+
+	if err := json.Unmarshal(cached.Verdict, verdict); err != nil {
+		return false, fmt.Errorf("failed to unmarshal cached verdict: %w", err)
+	}
+	return true, nil
+}
+
+// CleanVerdictCache removes verdicts cached longer than the TTL ago
+func (s *BoltStore) CleanVerdictCache(ttl time.Duration) (int, error) {
+	cutoff := time.Now().Add(-ttl)
+	removed := 0
+
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(verdictCacheBucket)
+		var expired [][]byte
+		err := b.ForEach(func(k, v []byte) error {
+			var cached cachedVerdict
+			if json.Unmarshal(v, &cached) != nil || cached.CachedAt.Before(cutoff) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range expired {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		removed = len(expired)
+		return nil
+	})
+	return removed, err
+}
@@ -0,0 +1,139 @@
+package threatintel
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Feed is a blocklist of malicious domains loaded from a URL or local file
+type Feed struct {
+	Name   string `json:"name"`
+	Source string `json:"source"` // http(s) URL or file path
+}
+
+// ParseFeeds parses "name=source" feed definitions. A definition without a
+// name uses the source itself as the feed name; a name never contains "/".
+func ParseFeeds(specs []string) ([]Feed, error) {
+	var feeds []Feed
+	names := make(map[string]bool)
+
+	for _, spec := range specs {
+		// Text before the first "=" is only a name if it is not part of a URL or
+		// path, so "https://host/list?format=hosts" stays a single source
+		name, source, ok := strings.Cut(spec, "=")
+		if !ok || strings.Contains(name, "://") || strings.Contains(name, "/") {
+			name, source = spec, spec
+		}
+		name = strings.TrimSpace(name)
+		source = strings.TrimSpace(source)
+
+		if name == "" || source == "" {
+			return nil, fmt.Errorf("invalid feed %q (expected name=url or name=path)", spec)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicate feed name %q", name)
+		}
+		names[name] = true
+
+		feeds = append(feeds, Feed{Name: name, Source: source})
+	}
+
+	return feeds, nil
+}
+
+// IsRemote returns true if the feed is fetched over HTTP
+func (f Feed) IsRemote() bool {
+	return strings.HasPrefix(f.Source, "http://") || strings.HasPrefix(f.Source, "https://")
+}
+
+// fetch loads and parses the feed's domains
+func (f Feed) fetch(client *http.Client) ([]string, error) {
+	if !f.IsRemote() {
+		file, err := os.Open(f.Source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open feed file: %w", err)
+		}
+		defer file.Close()
+
+		return parseDomains(file)
+	}
+
+	resp, err := client.Get(f.Source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download feed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("feed returned status %d", resp.StatusCode)
+	}
+
+	return parseDomains(resp.Body)
+}
+
+// parseDomains reads domains from a hosts-format or plain-domain list.
+// Comments (# and !), IP addresses and localhost entries are skipped.
+func parseDomains(r io.Reader) ([]string, error) {
+	var domains []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "!") {
+			continue
+		}
+
+		// Hosts format lists one or more hostnames after an IP address
+		fields := strings.Fields(line)
+		if net.ParseIP(fields[0]) != nil {
+			fields = fields[1:]
+		}
+
+		for _, field := range fields {
+			if domain := normalizeDomain(field); isListableDomain(domain) {
+				domains = append(domains, domain)
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read feed: %w", err)
+	}
+
+	return domains, nil
+}
+
+// normalizeDomain lowercases a domain and strips wildcard prefixes and trailing dots
+func normalizeDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	domain = strings.TrimPrefix(domain, "*.")
+	domain = strings.TrimPrefix(domain, ".")
+	return strings.TrimSuffix(domain, ".")
+}
+
+// isListableDomain filters out entries that would match far too much traffic
+func isListableDomain(domain string) bool {
+	switch domain {
+	case "", "localhost", "localhost.localdomain", "local", "broadcasthost", "ip6-localhost", "ip6-loopback":
+		return false
+	}
+	return strings.Contains(domain, ".") && net.ParseIP(domain) == nil
+}
+
+// feedState tracks the last load of a feed
+type feedState struct {
+	domains   []string
+	loadedAt  time.Time
+	lastError string
+}
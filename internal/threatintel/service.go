@@ -0,0 +1,220 @@
+package threatintel
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eiladin/guardian-log/internal/storage"
+)
+
+// alertCooldown prevents repeated anomalies for the same client and domain
+const alertCooldown = time.Hour
+
+// FeedStatus reports the state of a feed for the API
+type FeedStatus struct {
+	Feed
+	Entries   int       `json:"entries"`
+	LoadedAt  time.Time `json:"loaded_at,omitzero"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Service loads threat-intel feeds, keeps them refreshed and matches queries against them
+type Service struct {
+	feeds   []Feed
+	refresh time.Duration
+	client  *http.Client
+
+	mu        sync.RWMutex
+	states    map[string]*feedState
+	index     map[string][]string // Listed domain -> names of the feeds listing it
+	lastAlert map[string]time.Time
+}
+
+// NewService creates a threat-intel service and performs the initial load.
+// Feeds that fail to load are logged and retried on the next refresh.
+func NewService(feeds []Feed, refresh time.Duration) *Service {
+	if refresh <= 0 {
+		refresh = 6 * time.Hour // Default
+	}
+
+	s := &Service{
+		feeds:   feeds,
+		refresh: refresh,
+		client: &http.Client{
+			Timeout: 60 * time.Second,
+		},
+		states:    make(map[string]*feedState),
+		index:     make(map[string][]string),
+		lastAlert: make(map[string]time.Time),
+	}
+
+	s.Refresh()
+	return s
+}
+
+// Start refreshes the feeds on the configured interval until ctx is cancelled
+func (s *Service) Start(ctx context.Context) {
+	ticker := time.NewTicker(s.refresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Refresh()
+		}
+	}
+}
+
+// Refresh reloads every feed and rebuilds the index.
+// A feed that fails to load keeps its previously loaded entries.
+func (s *Service) Refresh() {
+	for _, feed := range s.feeds {
+		domains, err := feed.fetch(s.client)
+
+		s.mu.Lock()
+		state, ok := s.states[feed.Name]
+		if !ok {
+			state = &feedState{}
+			s.states[feed.Name] = state
+		}
+		if err != nil {
+			state.lastError = err.Error()
+		} else {
+			state.domains = domains
+			state.loadedAt = time.Now()
+			state.lastError = ""
+		}
+		s.mu.Unlock()
+
+		if err != nil {
+			log.Printf("⚠️  [ThreatIntel] Failed to load feed %s: %v", feed.Name, err)
+		} else {
+			log.Printf("🛡️  [ThreatIntel] Loaded %d domains from feed %s", len(domains), feed.Name)
+		}
+	}
+
+	s.rebuildIndex()
+}
+
+// rebuildIndex merges the domains of all feeds into a single lookup table
+func (s *Service) rebuildIndex() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := make(map[string][]string)
+	for _, feed := range s.feeds {
+		state, ok := s.states[feed.Name]
+		if !ok {
+			continue
+		}
+		for _, domain := range state.domains {
+			names := index[domain]
+			if len(names) == 0 || names[len(names)-1] != feed.Name {
+				index[domain] = append(names, feed.Name)
+			}
+		}
+	}
+
+	s.index = index
+}
+
+// Lookup matches a domain and each of its parent domains against the index.
+// It returns the listed entry that matched and the feeds listing it.
+func (s *Service) Lookup(domain string) (string, []string, bool) {
+	domain = normalizeDomain(domain)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for candidate := domain; strings.Contains(candidate, "."); {
+		if feeds, ok := s.index[candidate]; ok {
+			return candidate, feeds, true
+		}
+		_, candidate, _ = strings.Cut(candidate, ".")
+	}
+
+	return "", nil, false
+}
+
+// Check returns a Malicious anomaly if the query's domain is listed in any feed
+func (s *Service) Check(query storage.DNSQuery) *storage.Anomaly {
+	listed, feeds, ok := s.Lookup(query.Domain)
+	if !ok {
+		return nil
+	}
+
+	key := query.ClientID + "|" + listed
+	s.mu.Lock()
+	if last, ok := s.lastAlert[key]; ok && time.Since(last) < alertCooldown {
+		s.mu.Unlock()
+		return nil
+	}
+	s.lastAlert[key] = time.Now()
+	for k, last := range s.lastAlert {
+		if time.Since(last) >= alertCooldown {
+			delete(s.lastAlert, k)
+		}
+	}
+	s.mu.Unlock()
+
+	match := "is listed"
+	if listed != normalizeDomain(query.Domain) {
+		match = fmt.Sprintf("is a subdomain of %s, which is listed", listed)
+	}
+
+	return &storage.Anomaly{
+		Type:            storage.AnomalyTypeThreatIntel,
+		Domain:          query.Domain,
+		ClientID:        query.ClientID,
+		ClientName:      query.ClientName,
+		QueryType:       query.QueryType,
+		Classification:  "Malicious",
+		RiskScore:       10,
+		Explanation:     fmt.Sprintf("%s %s in threat-intel feed %s", query.Domain, match, strings.Join(feeds, ", ")),
+		SuggestedAction: "Block",
+		DetectedAt:      time.Now(),
+		OffHours:        query.OffHours,
+		Details: map[string]string{
+			"feeds":         strings.Join(feeds, ","),
+			"listed_domain": listed,
+		},
+	}
+}
+
+// Status returns the state of each configured feed
+func (s *Service) Status() []FeedStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	statuses := make([]FeedStatus, 0, len(s.feeds))
+	for _, feed := range s.feeds {
+		status := FeedStatus{Feed: feed}
+		if state, ok := s.states[feed.Name]; ok {
+			status.Entries = len(state.domains)
+			status.LoadedAt = state.loadedAt
+			status.LastError = state.lastError
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.SliceStable(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// IndexSize returns the number of unique domains across all feeds
+func (s *Service) IndexSize() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.index)
+}
@@ -1,6 +1,7 @@
 import { useState, useEffect } from 'react';
 import { GuardianAPI } from './api';
 import { AnomalyCard } from './components/AnomalyCard';
+import { FirstSeenFeed } from './components/FirstSeenFeed';
 import { StatsPanel } from './components/StatsPanel';
 import type { Anomaly, Stats } from './types';
 import './App.css';
@@ -154,6 +155,8 @@ function App() {
                         </div>
                     )}
                 </div>
+
+                <FirstSeenFeed />
             </main>
 
             <footer className="footer">
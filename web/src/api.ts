@@ -1,4 +1,4 @@
-import type { Anomaly, Stats, Settings } from './types';
+import type { Anomaly, BackfillResult, EventPage, Incident, Stats, Settings } from './types';
 
 // Use relative URL so it works both in dev (with Vite proxy) and production (served by Go)
 const API_BASE_URL = '/api';
@@ -37,6 +37,75 @@ export class GuardianAPI {
     }
   }
 
+  // Get incidents, optionally filtered by status
+  static async getIncidents(status?: string): Promise<Incident[]> {
+    const url = status
+      ? `${API_BASE_URL}/incidents?status=${status}`
+      : `${API_BASE_URL}/incidents`;
+
+    const response = await fetch(url);
+    if (!response.ok) {
+      throw new Error(`Failed to fetch incidents: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  // Get a single incident with its anomalies
+  static async getIncident(id: string): Promise<Incident> {
+    const response = await fetch(`${API_BASE_URL}/incidents/${encodeURIComponent(id)}`);
+    if (!response.ok) {
+      throw new Error(`Failed to fetch incident: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  // Move an incident through its status workflow
+  static async updateIncident(
+    id: string,
+    action: 'investigate' | 'resolve' | 'dismiss' | 'reopen'
+  ): Promise<void> {
+    const response = await fetch(`${API_BASE_URL}/incidents/${encodeURIComponent(id)}/${action}`, {
+      method: 'POST',
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to update incident: ${response.statusText}`);
+    }
+  }
+
+  // Get a page of first-seen events, newest first; pass next_cursor as before for older events
+  static async getEvents(
+    options: { client?: string; before?: string; limit?: number; analyzed?: boolean } = {}
+  ): Promise<EventPage> {
+    const params = new URLSearchParams();
+    if (options.client) params.set('client', options.client);
+    if (options.analyzed !== undefined) params.set('analyzed', String(options.analyzed));
+    if (options.before) params.set('before', options.before);
+    if (options.limit) params.set('limit', String(options.limit));
+
+    const query = params.toString();
+    const response = await fetch(`${API_BASE_URL}/events${query ? `?${query}` : ''}`);
+    if (!response.ok) {
+      throw new Error(`Failed to fetch first-seen events: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
+  // Queue unanalyzed first-seen events in a time range (RFC3339) for LLM analysis
+  static async backfillEvents(from?: string, to?: string): Promise<BackfillResult> {
+    const params = new URLSearchParams();
+    if (from) params.set('from', from);
+    if (to) params.set('to', to);
+
+    const query = params.toString();
+    const response = await fetch(`${API_BASE_URL}/events/backfill${query ? `?${query}` : ''}`, {
+      method: 'POST',
+    });
+    if (!response.ok) {
+      throw new Error(`Failed to start backfill: ${response.statusText}`);
+    }
+    return response.json();
+  }
+
   // Get system statistics
   static async getStats(): Promise<Stats> {
     const response = await fetch(`${API_BASE_URL}/stats`);
@@ -78,9 +78,19 @@ export function AnomalyCard({ anomaly, onApprove, onBlock }: AnomalyCardProps) {
 
       <div className="anomaly-details">
         <div className="detail-row">
-          <span className="detail-label">Client:</span>
+          <span className="detail-label">
+            {anomaly.client_count > 1 ? `Clients (${anomaly.client_count}):` : 'Client:'}
+          </span>
           <span className="detail-value">
-            {anomaly.client_name} ({anomaly.client_id})
+            {anomaly.affected_clients.map((client) => (
+              <span
+                key={client.client_id}
+                className="affected-client"
+                title={`First seen ${new Date(client.first_seen).toLocaleString()}`}
+              >
+                {client.client_name || client.client_id} ({client.client_id})
+              </span>
+            ))}
           </span>
         </div>
         <div className="detail-row">
@@ -0,0 +1,106 @@
+import { useState, useEffect, useRef } from 'react';
+import { GuardianAPI } from '../api';
+import type { FirstSeenEvent } from '../types';
+import '../styles/FirstSeenFeed.css';
+
+const PAGE_SIZE = 50;
+
+export function FirstSeenFeed() {
+  const [events, setEvents] = useState<FirstSeenEvent[]>([]);
+  const [cursor, setCursor] = useState<string | undefined>(undefined);
+  const [loading, setLoading] = useState(true);
+  const [loadingMore, setLoadingMore] = useState(false);
+  const [error, setError] = useState<string | null>(null);
+  const loaded = useRef(false);
+
+  // Fetch the newest page, keeping any older pages already loaded
+  const fetchLatest = async () => {
+    try {
+      const page = await GuardianAPI.getEvents({ limit: PAGE_SIZE });
+      if (!loaded.current) {
+        loaded.current = true;
+        setEvents(page.events);
+        setCursor(page.next_cursor);
+      } else {
+        setEvents((current) => {
+          const known = new Set(current.map((event) => event.id));
+          return [...page.events.filter((event) => !known.has(event.id)), ...current];
+        });
+      }
+      setError(null);
+    } catch (err) {
+      console.error('Failed to fetch first-seen events:', err);
+      setError('Failed to load first-seen events');
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  // Append the next page of older events
+  const loadMore = async () => {
+    if (!cursor) return;
+    setLoadingMore(true);
+    try {
+      const page = await GuardianAPI.getEvents({ before: cursor, limit: PAGE_SIZE });
+      setEvents((current) => [...current, ...page.events]);
+      setCursor(page.next_cursor);
+    } catch (err) {
+      console.error('Failed to fetch first-seen events:', err);
+      setError('Failed to load first-seen events');
+    } finally {
+      setLoadingMore(false);
+    }
+  };
+
+  // Initial load, then poll for new events every 10 seconds
+  useEffect(() => {
+    fetchLatest();
+    const interval = setInterval(fetchLatest, 10000);
+    return () => clearInterval(interval);
+  }, []);
+
+  return (
+    <div className="first-seen-section">
+      <div className="section-header">
+        <h2 className="section-title">First-Seen Domains</h2>
+      </div>
+
+      {loading && <div className="first-seen-empty">Loading first-seen events...</div>}
+
+      {error && !loading && <div className="first-seen-empty">⚠️ {error}</div>}
+
+      {!loading && !error && events.length === 0 && (
+        <div className="first-seen-empty">No first-seen domains yet.</div>
+      )}
+
+      {!loading && events.length > 0 && (
+        <div className="first-seen-list">
+          {events.map((event) => (
+            <div key={event.id} className="first-seen-row">
+              <span className="first-seen-time">
+                {new Date(event.detected_at).toLocaleString()}
+              </span>
+              <span className="first-seen-domain" title={event.query.domain}>
+                {event.query.unicode_domain || event.query.domain}
+              </span>
+              <span className="first-seen-type">{event.query.query_type}</span>
+              <span className="first-seen-client">
+                {event.query.client_name || event.query.client_id}
+              </span>
+              <span className="first-seen-flag">
+                {event.query.off_hours && '🌙 Off-hours '}
+                {!event.analyzed && <span title="No verdict yet">⏳ Not analyzed</span>}
+              </span>
+            </div>
+          ))}
+        </div>
+      )}
+
+      {cursor && !loading && (
+        <button className="filter-btn first-seen-more" onClick={loadMore} disabled={loadingMore}>
+          {loadingMore ? 'Loading...' : 'Load older events'}
+        </button>
+      )}
+    </div>
+  );
+}
@@ -73,6 +73,21 @@ export function StatsPanel({ stats }: StatsPanelProps) {
           <div className="stat-label">Success Rate</div>
           <div className="stat-value">{successRate}%</div>
         </div>
+
+        <div className="stat-card">
+          <div className="stat-label">First-Seen Domains</div>
+          <div className="stat-value">{stats.first_seen_events.toLocaleString()}</div>
+        </div>
+
+        <div className="stat-card">
+          <div className="stat-label">LLM Calls Saved</div>
+          <div className="stat-value">{stats.llm_calls_saved.toLocaleString()}</div>
+        </div>
+
+        <div className="stat-card">
+          <div className="stat-label">Cached Verdicts</div>
+          <div className="stat-value">{stats.llm_cache_hits.toLocaleString()}</div>
+        </div>
       </div>
     </div>
   );
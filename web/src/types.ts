@@ -2,6 +2,7 @@
 
 export interface Anomaly {
   id: string;
+  type: string; // llm, beaconing, volume, new_domain, typosquat, rule, threat_intel
   domain: string;
   client_id: string;
   client_name: string;
@@ -12,6 +13,76 @@ export interface Anomaly {
   suggested_action: "Investigate" | "Block";
   detected_at: string;
   status: "pending" | "approved" | "blocked";
+  off_hours: boolean;
+  domain_created_at?: string;
+  domain_age_days?: number;
+  details?: Record<string, string>;
+  affected_clients: AffectedClient[];
+  client_count: number;
+}
+
+export interface AffectedClient {
+  client_id: string;
+  client_name: string;
+  first_seen: string;
+}
+
+export interface IncidentEvent {
+  time: string;
+  anomaly_id: string;
+  type: string;
+  domain: string;
+  classification: string;
+  risk_score: number;
+  explanation: string;
+}
+
+export interface Incident {
+  id: string;
+  client_id: string;
+  client_name: string;
+  severity: "low" | "medium" | "high" | "critical";
+  max_risk_score: number;
+  status: "open" | "investigating" | "resolved" | "dismissed";
+  started_at: string;
+  last_seen_at: string;
+  anomaly_ids: string[];
+  timeline: IncidentEvent[];
+  created_at: string;
+  updated_at: string;
+  anomalies?: Anomaly[];
+}
+
+export interface DNSQuery {
+  client_id: string;
+  client_name: string;
+  domain: string;
+  timestamp: string;
+  unicode_domain?: string;
+  query_type: string;
+  answer?: string;
+  reason?: string;
+  response?: string;
+  upstream?: string;
+  off_hours?: boolean;
+}
+
+export interface FirstSeenEvent {
+  id: string;
+  query: DNSQuery;
+  detected_at: string;
+  analyzed: boolean;
+}
+
+export interface EventPage {
+  events: FirstSeenEvent[];
+  next_cursor?: string;
+}
+
+export interface BackfillResult {
+  queued: number;
+  from?: string;
+  to?: string;
 }
 
 export interface Stats {
@@ -26,6 +97,10 @@ export interface Stats {
   llm_analyses_total: number;
   llm_analyses_success: number;
   llm_analyses_failed: number;
+  llm_calls_saved: number;
+  llm_cache_hits: number;
+  llm_cache_misses: number;
+  first_seen_events: number;
 }
 
 export interface Settings {